@@ -4,18 +4,10 @@ go 1.24
 
 require (
 	github.com/google/uuid v1.6.0
-	github.com/gorilla/mux v1.8.1
-	github.com/jackc/pgx/v5 v5.7.5
+	github.com/gorilla/websocket v1.5.3
+	imara-shared v0.0.0-00010101000000-000000000000
 )
 
-require (
-	github.com/gorilla/websocket v1.5.3 // indirect
-	github.com/jackc/pgpassfile v1.0.0 // indirect
-	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
-	github.com/jackc/puddle/v2 v2.2.2 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/rs/cors v1.11.1 // indirect
-	golang.org/x/crypto v0.37.0 // indirect
-	golang.org/x/sync v0.13.0 // indirect
-	golang.org/x/text v0.24.0 // indirect
-)
+require github.com/BurntSushi/toml v1.6.0 // indirect
+
+replace imara-shared => ../shared
@@ -1,22 +1,27 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
-	"os"
 	"bytes"
 	"encoding/json"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"imara-shared/apierror"
+	"imara-shared/config"
 )
 
-// WebSocket upgrader config
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow only the frontend origin in development. Change for production!
-		origin := r.Header.Get("Origin")
-		return origin == "*"
-	},
+// newUpgrader accepts WebSocket connections from the configured origins
+func newUpgrader(cfg config.Config) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return cfg.AllowOrigin(r.Header.Get("Origin"))
+		},
+	}
 }
 
 // Message is the structure sent/received via WebSocket
@@ -56,15 +61,29 @@ func (c *Client) readPump() {
 	}
 }
 
-// Write messages to client
+// writeWait is how long a write to a client may take
+const writeWait = 10 * time.Second
+
+// Write messages to client, and say goodbye once the hub closes send
 func (c *Client) writePump() {
+	defer c.hub.writers.Done()
 	for msg := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 		if err := c.conn.WriteJSON(msg); err != nil {
 			log.Println("write error:", err)
-			break
+			c.conn.Close()
+			return
 		}
 	}
-	c.conn.Close()
+	sayGoodbye(c.conn)
+}
+
+// sayGoodbye closes conn with a going-away frame
+func sayGoodbye(conn *websocket.Conn) {
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, "server closing"),
+		time.Now().Add(writeWait))
+	conn.Close()
 }
 
 // Hub keeps track of all clients
@@ -73,6 +92,13 @@ type Hub struct {
 	broadcast  chan Message
 	register   chan *Client
 	unregister chan *Client
+
+	// quit is closed by Shutdown; writers tracks the clients' write pumps.
+	// mu guards closed, so that no writer is added once Shutdown waits
+	quit    chan struct{}
+	mu      sync.Mutex
+	closed  bool
+	writers sync.WaitGroup
 }
 
 // NewHub creates a Hub
@@ -82,14 +108,30 @@ func NewHub() *Hub {
 		broadcast:  make(chan Message),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		quit:       make(chan struct{}),
 	}
 }
 
 // Run the Hub
 func (h *Hub) Run() {
+	quit := h.quit
+	closing := false
 	for {
 		select {
+		case <-quit:
+			// Close every client; their write pumps flush what is queued
+			// and send a close frame
+			quit = nil
+			closing = true
+			for client := range h.clients {
+				close(client.send)
+				delete(h.clients, client)
+			}
 		case client := <-h.register:
+			if closing {
+				close(client.send)
+				continue
+			}
 			h.clients[client] = true
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
@@ -109,13 +151,51 @@ func (h *Hub) Run() {
 	}
 }
 
+// Shutdown closes every client connection with a going-away frame and
+// waits until their queued messages are written or ctx is done
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.quit)
+	}
+	h.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// addWriter counts a new client's write pump, or reports false once the
+// hub is shutting down
+func (h *Hub) addWriter() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.writers.Add(1)
+	return true
+}
+
 // ServeWs upgrades HTTP to WebSocket and registers the client
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+func ServeWs(hub *Hub, upgrader *websocket.Upgrader, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("WebSocket upgrade error:", err)
 		return
 	}
+	if !hub.addWriter() {
+		sayGoodbye(conn)
+		return
+	}
 	client := &Client{hub: hub, conn: conn, send: make(chan Message)}
 	hub.register <- client
 
@@ -135,46 +215,38 @@ type ChatMessagePayload struct {
 }
 
 // Handler for POST /api/chat/message
-func HandleChatMessage(hub *Hub) http.HandlerFunc {
+func HandleChatMessage(hub *Hub, supabase config.Supabase) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			apierror.Write(w, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed"))
 			return
 		}
 
 		var payload ChatMessagePayload
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "invalid JSON"}`))
-			return
-		}
-
-		supabaseURL := os.Getenv("SUPABASE_URL")
-		supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
-		if supabaseURL == "" || supabaseKey == "" {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(`{"error": "Supabase credentials not set"}`))
+			apierror.Write(w, apierror.New(http.StatusBadRequest, apierror.CodeInvalidBody, "Invalid request body: "+err.Error()))
 			return
 		}
 
 		// Prepare the request to Supabase REST API
-		apiURL := supabaseURL + "/rest/v1/chat_messages"
+		apiURL := supabase.URL + "/rest/v1/chat_messages"
 		jsonBody, _ := json.Marshal(payload)
-		req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
+		// The request's context stops the insert if the client goes away
+		req, err := http.NewRequestWithContext(r.Context(), "POST", apiURL, bytes.NewBuffer(jsonBody))
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(`{"error": "failed to create request"}`))
+			log.Println("create Supabase request error:", err)
+			apierror.Write(w, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "Internal server error"))
 			return
 		}
-		req.Header.Set("apikey", supabaseKey)
-		req.Header.Set("Authorization", "Bearer "+supabaseKey)
+		req.Header.Set("apikey", supabase.ServiceKey)
+		req.Header.Set("Authorization", "Bearer "+supabase.ServiceKey)
 		req.Header.Set("Content-Type", "application/json")
 
 		client := &http.Client{}
 		resp, err := client.Do(req)
 		if err != nil {
-			w.WriteHeader(http.StatusBadGateway)
-			w.Write([]byte(`{"error": "failed to contact Supabase"}`))
+			log.Println("contact Supabase error:", err)
+			apierror.Write(w, apierror.Upstream(err))
 			return
 		}
 		defer resp.Body.Close()
@@ -191,8 +263,23 @@ func HandleChatMessage(hub *Hub) http.HandlerFunc {
 			w.WriteHeader(http.StatusCreated)
 			w.Write([]byte(`{"success": true}`))
 		} else {
-			w.WriteHeader(http.StatusBadGateway)
-			w.Write([]byte(`{"error": "Supabase error"}`))
+			apierror.Write(w, supabaseError(resp))
 		}
 	}
 }
+
+// supabaseError maps a failed PostgREST response to an API error, logging
+// the database's message rather than passing it on.
+func supabaseError(resp *http.Response) *apierror.Error {
+	var body struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err := json.Unmarshal(raw, &body); err != nil || body.Code == "" {
+		log.Printf("Supabase error: %s: %s", resp.Status, raw)
+		return apierror.New(http.StatusBadGateway, apierror.CodeUpstreamError, "Chat storage returned an error")
+	}
+	log.Printf("Supabase error: %s: (%s) %s", resp.Status, body.Code, body.Message)
+	return apierror.PostgREST(body.Code)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"imara-shared/config"
+)
+
+// startHub serves a running hub's WebSocket endpoint and returns its URL.
+func startHub(t *testing.T) (*Hub, string) {
+	t.Helper()
+	hub := NewHub()
+	go hub.Run()
+	upgrader := newUpgrader(config.Default())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, upgrader, w, r)
+	}))
+	t.Cleanup(server.Close)
+	return hub, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	header := http.Header{"Origin": {"https://imarahub.xyz"}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// expectGoingAway reads from conn until the server closes it, and fails
+// unless it said goodbye with a going-away frame.
+func expectGoingAway(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+			t.Fatalf("connection ended with %v, want a going-away close frame", err)
+		}
+		return
+	}
+}
+
+func TestHubShutdownSaysGoodbye(t *testing.T) {
+	hub, url := startHub(t)
+	conns := []*websocket.Conn{dial(t, url), dial(t, url)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown = %v after %v", err, time.Since(start))
+	}
+	for _, conn := range conns {
+		expectGoingAway(t, conn)
+	}
+
+	// Shutdown returned once every write pump had finished
+	done := make(chan struct{})
+	go func() {
+		hub.writers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("write pumps still running after Shutdown")
+	}
+
+	// Clients arriving late are turned away, and a second Shutdown
+	// returns at once
+	expectGoingAway(t, dial(t, url))
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Errorf("second Shutdown = %v", err)
+	}
+}
+
+func TestHubShutdownStopsWaitingAtDeadline(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	// A write pump that never finishes
+	if !hub.addWriter() {
+		t.Fatal("new hub refused a writer")
+	}
+	defer hub.writers.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := hub.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want the deadline", err)
+	}
+	if hub.addWriter() {
+		t.Error("writer added after Shutdown")
+	}
+}
@@ -1,16 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/uuid"
+	"imara-shared/apierror"
+	"imara-shared/config"
 )
 
-// CORS middleware to allow requests from http://localhost:3000
-func corsMiddleware(next http.Handler) http.Handler {
+// CORS middleware to allow requests from the configured origins
+func corsMiddleware(cfg config.Config, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); cfg.AllowOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -19,22 +32,107 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// requestIDMiddleware tags each request with an ID, taken from a
+// well-formed X-Request-ID header or generated, and echoes it back so
+// error responses can carry it.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(apierror.RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(apierror.RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// loadConfig reads the chat server settings from the command line, the
+// environment and an optional config file. Messages are stored with the
+// Supabase service key, so it is required.
+func loadConfig() (config.Config, error) {
+	defaults := config.Default()
+	defaults.Addr = ":8080"
+	cfg, err := config.Load("chat-server", defaults, os.Args[1:])
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Supabase.URL == "" || cfg.Supabase.ServiceKey == "" {
+		return cfg, errors.New("set SUPABASE_URL and SUPABASE_SERVICE_KEY, or supabase.url and supabase.service_key in the config file")
+	}
+	return cfg, nil
+}
+
 func main() {
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+	upgrader := newUpgrader(cfg)
+
 	hub := NewHub()
 	go hub.Run()
 
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		ServeWs(hub, w, r)
+		ServeWs(hub, upgrader, w, r)
 	})
 
-	http.HandleFunc("/api/chat/message", HandleChatMessage(hub))
+	http.HandleFunc("/api/chat/message", HandleChatMessage(hub, cfg.Supabase))
 
 	fs := http.FileServer(http.Dir("./static"))
 	http.Handle("/", fs)
 
-	// Wrap the default mux with the CORS middleware
-	log.Println("Server started at http://localhost:8080")
-	if err := http.ListenAndServe(":8080", corsMiddleware(http.DefaultServeMux)); err != nil {
-		log.Fatal("ListenAndServe:", err)
+	// Wrap the default mux with the CORS and request ID middleware
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           corsMiddleware(cfg, requestIDMiddleware(http.DefaultServeMux)),
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+	}
+
+	signalled, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server started on %s (%s)", cfg.Addr, cfg.Environment)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.Fatal("ListenAndServe: ", err)
+	case <-signalled.Done():
+		// A second signal kills the process without waiting
+		stopSignals()
+	}
+
+	// Finish in-flight requests first so no new WebSocket clients join,
+	// then say goodbye to the connected ones
+	log.Printf("Shutting down; draining for up to %s", cfg.Timeouts.Shutdown)
+	drain, cancelDrain := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancelDrain()
+	if err := server.Shutdown(drain); err != nil {
+		log.Println("requests still running at shutdown deadline:", err)
+		server.Close()
+	}
+	if err := hub.Shutdown(drain); err != nil {
+		log.Println("WebSocket clients still connected at shutdown deadline:", err)
 	}
+	log.Println("Server stopped")
 }
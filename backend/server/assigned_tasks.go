@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDueSoonDays is how far ahead a task counts as due soon.
+const defaultDueSoonDays = 7
+
+// taskBucket places an assigned task relative to now.
+func taskBucket(task Task, now time.Time, dueSoon time.Duration) string {
+	status := normalizeTaskStatus(task.Status)
+	switch {
+	case status == TaskApproved || status == TaskClosed:
+		return BucketDone
+	case task.DueDate.IsZero():
+		return BucketUnscheduled
+	case task.DueDate.end().Before(now):
+		return BucketOverdue
+	case task.DueDate.start().Before(now.Add(dueSoon)):
+		return BucketDueSoon
+	default:
+		return BucketLater
+	}
+}
+
+// groupAssignedTasks buckets tasks and groups them by project and
+// milestone, keeping the order the tasks came in.
+func groupAssignedTasks(assigneeID string, tasks []AssignedTask, now time.Time, dueSoon time.Duration, includeDone bool) AssignedTasksResponse {
+	resp := AssignedTasksResponse{
+		AssigneeID: assigneeID,
+		Overdue:    []AssignedTask{},
+		DueSoon:    []AssignedTask{},
+		Projects:   []AssignedProjectTasks{},
+	}
+	projectIndex := map[string]int{}
+	milestoneIndex := map[string]int{}
+
+	for _, task := range tasks {
+		task.Bucket = taskBucket(task.Task, now, dueSoon)
+		if task.Bucket == BucketDone && !includeDone {
+			continue
+		}
+		resp.Total++
+		switch task.Bucket {
+		case BucketOverdue:
+			resp.Overdue = append(resp.Overdue, task)
+		case BucketDueSoon:
+			resp.DueSoon = append(resp.DueSoon, task)
+		}
+
+		p, ok := projectIndex[task.ProjectID]
+		if !ok {
+			p = len(resp.Projects)
+			projectIndex[task.ProjectID] = p
+			resp.Projects = append(resp.Projects, AssignedProjectTasks{ProjectID: task.ProjectID})
+		}
+		project := &resp.Projects[p]
+
+		m, ok := milestoneIndex[task.MilestoneID]
+		if !ok {
+			m = len(project.Milestones)
+			milestoneIndex[task.MilestoneID] = m
+			project.Milestones = append(project.Milestones, AssignedMilestoneTasks{
+				MilestoneID: task.MilestoneID,
+				Title:       task.MilestoneTitle,
+				DueDate:     task.MilestoneDueDate,
+			})
+		}
+		project.Milestones[m].Tasks = append(project.Milestones[m].Tasks, task)
+	}
+	return resp
+}
+
+// My tasks endpoint
+func (s *server) handleMyTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.writeAssignedTasks(w, r, currentUserID(r), anyMember)
+}
+
+// User tasks endpoint. Leads see the user's tasks in the projects they
+// lead; everyone may list their own.
+func (s *server) handleUserTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userId := mux.Vars(r)["userId"]
+
+	roles := leadOnly
+	if userId == currentUserID(r) {
+		roles = anyMember
+	}
+	s.writeAssignedTasks(w, r, userId, roles)
+}
+
+// writeAssignedTasks lists a user's tasks in the projects where the
+// caller holds one of roles.
+func (s *server) writeAssignedTasks(w http.ResponseWriter, r *http.Request, assigneeID string, roles []Role) {
+	days := defaultDueSoonDays
+	if raw := r.URL.Query().Get("due_soon_days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 90 {
+			writeError(w, "due_soon_days must be between 1 and 90", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	includeDone := false
+	if raw := r.URL.Query().Get("include_done"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, "include_done must be true or false", http.StatusBadRequest)
+			return
+		}
+		includeDone = b
+	}
+
+	tasks, err := s.tasks.ListByAssignee(r.Context(), assigneeID)
+	if err != nil {
+		fmt.Printf("Error fetching assigned tasks: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	// Tasks in projects the caller cannot see are left out
+	visible := map[string]bool{}
+	allowed := tasks[:0]
+	for _, task := range tasks {
+		ok, seen := visible[task.ProjectID]
+		if !seen {
+			access, err := s.projectAccess(r.Context(), task.ProjectID)
+			switch {
+			case err == nil:
+				ok = access.allows(roles)
+			case errors.Is(err, ErrNotFound), errors.Is(err, errForbidden):
+				ok = false
+			default:
+				fmt.Printf("Error resolving project role: %v\n", err)
+				writeServerError(w, err)
+				return
+			}
+			visible[task.ProjectID] = ok
+		}
+		if ok {
+			allowed = append(allowed, task)
+		}
+	}
+
+	dueSoon := time.Duration(days) * 24 * time.Hour
+	writeJSON(w, groupAssignedTasks(assigneeID, allowed, time.Now(), dueSoon, includeDone))
+}
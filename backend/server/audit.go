@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Audit actions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// auditIgnoredFields are left out of diffs: timestamps the database
+// maintains, derived fields, and webhook signing secrets.
+var auditIgnoredFields = []string{"created_at", "updated_at", "tasks", "progress", "secret"}
+
+const requestIDKey contextKey = "request_id"
+
+// requestIDMiddleware tags each request with an ID, taken from a
+// well-formed X-Request-ID header or generated, and echoes it back.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// auditRecorder collects the changes a request makes so the audit
+// middleware can write them once the handler has succeeded.
+type auditRecorder struct {
+	projectID string
+	entries   []AuditEntry
+}
+
+const auditRecorderKey contextKey = "audit"
+
+func auditFromContext(ctx context.Context) *auditRecorder {
+	rec, _ := ctx.Value(auditRecorderKey).(*auditRecorder)
+	return rec
+}
+
+// setAuditProject notes the project a request acts on. The first project
+// authorized wins.
+func setAuditProject(ctx context.Context, projectID string) {
+	if rec := auditFromContext(ctx); rec != nil && rec.projectID == "" {
+		rec.projectID = projectID
+	}
+}
+
+// recordAudit records a change to an entity made by the current request.
+// before is nil for a created entity and after for a deleted one. It does
+// nothing outside an audited request.
+func recordAudit(ctx context.Context, entityType, entityID string, before, after interface{}) {
+	rec := auditFromContext(ctx)
+	if rec == nil {
+		return
+	}
+
+	action := AuditUpdate
+	switch {
+	case before == nil:
+		action = AuditCreate
+	case after == nil:
+		action = AuditDelete
+	}
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		fmt.Printf("Error computing audit diff for %s %s: %v\n", entityType, entityID, err)
+	}
+	// Updates that changed nothing are not worth an entry
+	if action == AuditUpdate && len(changes) == 0 {
+		return
+	}
+	rec.entries = append(rec.entries, AuditEntry{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Changes:    changes,
+	})
+}
+
+// auditDiff compares the JSON forms of before and after field by field.
+func auditDiff(before, after interface{}) (map[string]AuditChange, error) {
+	from, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]AuditChange{}
+	for field, value := range from {
+		if !reflect.DeepEqual(value, to[field]) {
+			changes[field] = AuditChange{From: value, To: to[field]}
+		}
+	}
+	for field, value := range to {
+		if _, ok := from[field]; !ok && value != nil {
+			changes[field] = AuditChange{From: nil, To: value}
+		}
+	}
+	for _, field := range auditIgnoredFields {
+		delete(changes, field)
+	}
+	return changes, nil
+}
+
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// statusRecorder remembers the status a handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// auditMiddleware writes an audit entry for every successful mutating
+// request. Handlers record the entities they change with recordAudit;
+// requests that record nothing get one entry for the route's entity.
+func (s *server) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &auditRecorder{}
+		sw := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), auditRecorderKey, rec)))
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		if sw.status >= 400 {
+			return
+		}
+
+		endpoint := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				endpoint = tmpl
+			}
+		}
+		entries := rec.entries
+		if len(entries) == 0 {
+			entityType, entityID := routeEntity(endpoint, mux.Vars(r))
+			entries = []AuditEntry{{EntityType: entityType, EntityID: entityID, Action: AuditUpdate}}
+			if r.Method == http.MethodDelete {
+				entries[0].Action = AuditDelete
+			}
+		}
+
+		for i := range entries {
+			entries[i].RequestID = requestID(r.Context())
+			entries[i].ActorID = currentUserID(r)
+			entries[i].Method = r.Method
+			entries[i].Endpoint = endpoint
+			entries[i].Path = r.URL.Path
+			entries[i].Status = sw.status
+			if entries[i].ProjectID == "" {
+				entries[i].ProjectID = rec.projectID
+			}
+		}
+		if err := s.audit.Append(r.Context(), entries); err != nil {
+			fmt.Printf("Error writing audit log for request %s: %v\n", requestID(r.Context()), err)
+		}
+	})
+}
+
+// routeEntity names the entity a route acts on: the last path variable,
+// such as {taskId}, or else the first path segment after /api.
+func routeEntity(endpoint string, vars map[string]string) (string, string) {
+	segments := strings.Split(strings.TrimPrefix(endpoint, "/api/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		if !strings.HasPrefix(seg, "{") {
+			continue
+		}
+		name := strings.Trim(seg, "{}")
+		entity := strings.TrimSuffix(name, "Id")
+		if entity == "id" && i > 0 {
+			entity = segments[i-1]
+		}
+		return entity, vars[name]
+	}
+	return strings.TrimSuffix(segments[0], "s"), ""
+}
+
+// auditCursor continues an audit listing after the entry it names.
+type auditCursor struct {
+	CreatedAt string `json:"c"`
+	ID        string `json:"id"`
+}
+
+func (c auditCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeAuditCursor(s string) (*auditCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c auditCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" || c.CreatedAt == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// Project audit log endpoint
+func (s *server) handleProjectAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+	if _, ok := s.authorizeProject(w, r, projectId, auditors); !ok {
+		return
+	}
+
+	values := r.URL.Query()
+	q := AuditQuery{
+		ProjectID:  projectId,
+		EntityType: values.Get("entity_type"),
+		EntityID:   values.Get("entity_id"),
+		ActorID:    values.Get("actor"),
+		Limit:      defaultAuditLimit,
+	}
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if raw := values.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeError(w, fmt.Sprintf("%s must be an RFC 3339 timestamp", name), http.StatusBadRequest)
+				return
+			}
+			*dst = t
+		}
+	}
+	if raw := values.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			writeError(w, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit), http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := decodeAuditCursor(raw)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Before = cursor
+	}
+
+	pageSize := q.Limit
+	q.Limit++
+	entries, err := s.audit.List(r.Context(), q)
+	if err != nil {
+		fmt.Printf("Error fetching audit log: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	resp := Page[AuditEntry]{Items: entries}
+	if len(entries) > pageSize {
+		resp.Items = entries[:pageSize]
+		last := resp.Items[pageSize-1]
+		resp.NextCursor = auditCursor{CreatedAt: last.CreatedAt, ID: last.ID}.encode()
+	}
+	if resp.Items == nil {
+		resp.Items = []AuditEntry{}
+	}
+	writeJSON(w, resp)
+}
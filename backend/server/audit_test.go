@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// auditTrail returns the project's audit entries, newest first.
+func (api *testAPI) auditTrail(t *testing.T, projectID string) []AuditEntry {
+	t.Helper()
+	entries, err := api.repos.Audit.List(context.Background(), AuditQuery{ProjectID: projectID, Limit: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestAuditRecordsMutations(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor", "p1:backer:investor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	before := len(api.auditTrail(t, "p1"))
+
+	req, _ := http.NewRequest("PUT", api.URL+"/api/tasks/"+task.ID, bytes.NewReader([]byte(`{"title":"Full spec","effort":3}`)))
+	req.Header.Set("Authorization", "Bearer "+testToken("lead"))
+	req.Header.Set("X-Request-ID", "req-42")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("updating task: status %d", resp.StatusCode)
+	}
+
+	entries := api.auditTrail(t, "p1")
+	if len(entries) != before+1 {
+		t.Fatalf("%d entries written, want 1", len(entries)-before)
+	}
+	entry := entries[0]
+	want := AuditEntry{
+		ID:         entry.ID,
+		RequestID:  "req-42",
+		ActorID:    "lead",
+		Method:     "PUT",
+		Endpoint:   "/api/tasks/{taskId}",
+		Path:       "/api/tasks/" + task.ID,
+		Status:     http.StatusOK,
+		ProjectID:  "p1",
+		EntityType: "task",
+		EntityID:   task.ID,
+		Action:     AuditUpdate,
+		Changes: map[string]AuditChange{
+			"title":  {From: "Spec", To: "Full spec"},
+			"effort": {From: float64(1), To: float64(3)},
+		},
+		CreatedAt: entry.CreatedAt,
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("entry = %+v\nwant %+v", entry, want)
+	}
+
+	// Creating and deleting record the whole entity on one side
+	api.do(t, "lead", "DELETE", "/api/tasks/"+task.ID, nil, nil)
+	entries = api.auditTrail(t, "p1")
+	if deleted := entries[0]; deleted.Action != AuditDelete || deleted.EntityID != task.ID ||
+		deleted.Changes["title"] != (AuditChange{From: "Full spec", To: nil}) {
+		t.Errorf("delete entry = %+v", deleted)
+	}
+	var created []AuditEntry
+	for _, e := range entries {
+		if e.EntityID == task.ID && e.Action == AuditCreate {
+			created = append(created, e)
+		}
+	}
+	if len(created) != 1 || created[0].Changes["title"] != (AuditChange{From: nil, To: "Spec"}) {
+		t.Errorf("create entries = %+v", created)
+	}
+}
+
+func TestAuditSkipsReadsAndFailures(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor", "p1:backer:investor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	before := len(api.auditTrail(t, "p1"))
+
+	for _, path := range []string{
+		"/api/projects/p1/milestones",
+		"/api/milestones/" + milestone.ID + "/tasks",
+		"/api/tasks/" + task.ID + "/history",
+		"/api/projects/p1/audit",
+	} {
+		if resp := api.do(t, "backer", "GET", path, nil, nil); resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status %d", path, resp.StatusCode)
+		}
+	}
+	api.expectError(t, "dev", "PUT", "/api/tasks/"+task.ID, map[string]interface{}{"effort": 5}, http.StatusForbidden, "forbidden")
+	api.expectError(t, "lead", "PUT", "/api/tasks/"+task.ID, map[string]interface{}{"title": ""}, http.StatusBadRequest, "validation_failed")
+
+	if n := len(api.auditTrail(t, "p1")) - before; n != 0 {
+		t.Errorf("%d entries written for reads and failed requests", n)
+	}
+}
+
+func TestAuditTrailIsReadOnly(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:backer:investor", "p1:dev:contributor")
+	api.createMilestone(t, "lead", "p1", "Alpha")
+	before := api.auditTrail(t, "p1")
+
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		if resp := api.do(t, "lead", method, "/api/projects/p1/audit", map[string]interface{}{}, nil); resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s audit: status %d", method, resp.StatusCode)
+		}
+	}
+	api.expectError(t, "dev", "GET", "/api/projects/p1/audit", nil, http.StatusForbidden, "forbidden")
+
+	var page Page[AuditEntry]
+	if resp := api.do(t, "backer", "GET", "/api/projects/p1/audit", nil, &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("listing audit: status %d", resp.StatusCode)
+	}
+	if after := api.auditTrail(t, "p1"); !reflect.DeepEqual(after, before) || len(page.Items) != len(before) {
+		t.Errorf("audit trail changed: %d entries, listed %d, had %d", len(after), len(page.Items), len(before))
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := Webhook{ID: "w1", URL: "https://a.example", Secret: "old", Active: true, UpdatedAt: "then"}
+	after := Webhook{ID: "w1", URL: "https://b.example", Secret: "new", Active: true, UpdatedAt: "now"}
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]AuditChange{"url": {From: "https://a.example", To: "https://b.example"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"imara-shared/config"
+)
+
+// AuthUser is the caller identified by a verified Supabase access token.
+type AuthUser struct {
+	ID    string
+	Email string
+	Role  string
+}
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// withUser returns a copy of ctx carrying the authenticated user.
+func withUser(ctx context.Context, user AuthUser) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// userFromContext returns the authenticated user stored by authMiddleware.
+func userFromContext(ctx context.Context) (AuthUser, bool) {
+	user, ok := ctx.Value(userContextKey).(AuthUser)
+	return user, ok
+}
+
+// currentUserID returns the authenticated user's ID, or "" if the request
+// was not authenticated.
+func currentUserID(r *http.Request) string {
+	user, _ := userFromContext(r.Context())
+	return user.ID
+}
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid token")
+	errExpiredToken = errors.New("token has expired")
+)
+
+// jwtClaims are the Supabase access token claims the server relies on.
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Email     string          `json:"email"`
+	Role      string          `json:"role"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+}
+
+// hasAudience reports whether the aud claim, a string or an array of
+// strings, contains want.
+func (c jwtClaims) hasAudience(want string) bool {
+	var single string
+	if err := json.Unmarshal(c.Audience, &single); err == nil {
+		return single == want
+	}
+	var many []string
+	if err := json.Unmarshal(c.Audience, &many); err == nil {
+		for _, aud := range many {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// JWTVerifier validates Supabase-issued access tokens signed either with
+// the project's HS256 JWT secret or with asymmetric keys from a JWKS file.
+type JWTVerifier struct {
+	secret   []byte
+	keys     map[string]crypto.PublicKey
+	audience string
+	issuer   string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewHS256Verifier returns a verifier for tokens signed with secret.
+func NewHS256Verifier(secret string) *JWTVerifier {
+	return &JWTVerifier{
+		secret: []byte(secret),
+		leeway: 30 * time.Second,
+		now:    time.Now,
+	}
+}
+
+// jwk is a single JSON Web Key. Only RSA and P-256 EC public keys are used.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKSVerifier returns a verifier for RS256/ES256 tokens whose public
+// keys are read from a JWKS document on disk.
+func LoadJWKSVerifier(path string) (*JWTVerifier, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS file: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS file: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s contains no keys", path)
+	}
+
+	return &JWTVerifier{
+		keys:   keys,
+		leeway: 30 * time.Second,
+		now:    time.Now,
+	}, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding y: %w", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// WithAudience requires tokens to carry aud in their audience claim.
+func (v *JWTVerifier) WithAudience(aud string) *JWTVerifier {
+	v.audience = aud
+	return v
+}
+
+// WithIssuer requires tokens to be issued by iss.
+func (v *JWTVerifier) WithIssuer(iss string) *JWTVerifier {
+	v.issuer = iss
+	return v
+}
+
+// Verify checks the token's signature and claims and returns its user.
+func (v *JWTVerifier) Verify(token string) (AuthUser, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return AuthUser{}, errInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return AuthUser{}, errInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return AuthUser{}, errInvalidToken
+	}
+	if err := v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return AuthUser{}, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return AuthUser{}, errInvalidToken
+	}
+	now := v.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.leeway)) {
+		return AuthUser{}, errExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Add(v.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return AuthUser{}, errInvalidToken
+	}
+	if v.audience != "" && !claims.hasAudience(v.audience) {
+		return AuthUser{}, errInvalidToken
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return AuthUser{}, errInvalidToken
+	}
+	if claims.Subject == "" {
+		return AuthUser{}, errInvalidToken
+	}
+
+	return AuthUser{ID: claims.Subject, Email: claims.Email, Role: claims.Role}, nil
+}
+
+func (v *JWTVerifier) verifySignature(alg, kid, signingInput string, signature []byte) error {
+	switch alg {
+	case "HS256":
+		if v.secret == nil {
+			return errInvalidToken
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signingInput))
+		if subtle.ConstantTimeCompare(mac.Sum(nil), signature) != 1 {
+			return errInvalidToken
+		}
+		return nil
+	case "RS256", "ES256":
+		key := v.lookupKey(kid)
+		digest := sha256.Sum256([]byte(signingInput))
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			if alg != "RS256" || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) != nil {
+				return errInvalidToken
+			}
+			return nil
+		case *ecdsa.PublicKey:
+			if alg != "ES256" || len(signature) != 64 {
+				return errInvalidToken
+			}
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if !ecdsa.Verify(k, digest[:], r, s) {
+				return errInvalidToken
+			}
+			return nil
+		}
+		return errInvalidToken
+	default:
+		return errInvalidToken
+	}
+}
+
+// lookupKey finds the JWKS key for kid, falling back to the only key when
+// the token does not name one.
+func (v *JWTVerifier) lookupKey(kid string) crypto.PublicKey {
+	if key, ok := v.keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func decodeSegment(segment string, dst interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst)
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", errMissingToken
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", errMissingToken
+	}
+	return token, nil
+}
+
+// authMiddleware rejects requests without a valid access token and stores
+// the authenticated user in the request context.
+func authMiddleware(verifier *JWTVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := bearerToken(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="imara"`)
+				writeError(w, "Missing bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			user, err := verifier.Verify(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="imara", error="invalid_token"`)
+				writeError(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+		})
+	}
+}
+
+// verifierFromConfig builds the token verifier from the JWT secret or
+// the JWKS file, plus the audience and optional issuer checks.
+func verifierFromConfig(cfg config.Auth) (*JWTVerifier, error) {
+	var verifier *JWTVerifier
+	if cfg.JWTSecret != "" {
+		verifier = NewHS256Verifier(cfg.JWTSecret)
+	} else if cfg.JWKSFile != "" {
+		v, err := LoadJWKSVerifier(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier = v
+	} else {
+		return nil, errors.New("set SUPABASE_JWT_SECRET or SUPABASE_JWKS_FILE to verify access tokens")
+	}
+	return verifier.WithAudience(cfg.Audience).WithIssuer(cfg.Issuer), nil
+}
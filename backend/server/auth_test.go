@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	jwtNow     = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	b64        = base64.RawURLEncoding.EncodeToString
+	testRSAKey *rsa.PrivateKey
+	testECKey  *ecdsa.PrivateKey
+)
+
+func init() {
+	var err error
+	if testRSAKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if testECKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+}
+
+// signJWT encodes header and claims and appends the signature sign makes
+// over them.
+func signJWT(header, claims map[string]interface{}, sign func(input []byte) []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := b64(h) + "." + b64(c)
+	return input + "." + b64(sign([]byte(input)))
+}
+
+func signHS256(secret string) func([]byte) []byte {
+	return func(input []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+}
+
+func signRS256(input []byte) []byte {
+	digest := sha256.Sum256(input)
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, testRSAKey, crypto.SHA256, digest[:])
+	return sig
+}
+
+// signES256 signs with the fixed-width r||s encoding JWS uses.
+func signES256(input []byte) []byte {
+	digest := sha256.Sum256(input)
+	r, s, _ := ecdsa.Sign(rand.Reader, testECKey, digest[:])
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig
+}
+
+// signES256DER signs with the ASN.1 encoding, which JWS does not allow.
+func signES256DER(input []byte) []byte {
+	digest := sha256.Sum256(input)
+	sig, _ := ecdsa.SignASN1(rand.Reader, testECKey, digest[:])
+	return sig
+}
+
+func corrupt(sign func([]byte) []byte) func([]byte) []byte {
+	return func(input []byte) []byte {
+		sig := sign(input)
+		sig[len(sig)-1] ^= 1
+		return sig
+	}
+}
+
+// writeTestJWKS writes the public halves of the test keys to a JWKS file.
+func writeTestJWKS(t *testing.T) string {
+	t.Helper()
+	coord := func(n *big.Int) string { return b64(n.FillBytes(make([]byte, 32))) }
+	set := map[string]interface{}{"keys": []map[string]string{
+		{"kid": "rsa-1", "kty": "RSA", "n": b64(testRSAKey.N.Bytes()), "e": b64(big.NewInt(int64(testRSAKey.E)).Bytes())},
+		{"kid": "ec-1", "kty": "EC", "crv": "P-256", "x": coord(testECKey.X), "y": coord(testECKey.Y)},
+	}}
+	raw, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTVerifier(t *testing.T) {
+	hs := NewHS256Verifier("secret").WithAudience("authenticated").WithIssuer("https://auth.example.com")
+	hs.now = func() time.Time { return jwtNow }
+	jwks, err := LoadJWKSVerifier(writeTestJWKS(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks = jwks.WithAudience("authenticated")
+	jwks.now = hs.now
+
+	claims := func(edit func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":   "user-1",
+			"email": "ada@example.com",
+			"role":  "authenticated",
+			"aud":   "authenticated",
+			"iss":   "https://auth.example.com",
+			"exp":   jwtNow.Add(time.Hour).Unix(),
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+	hsHeader := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+	rsHeader := map[string]interface{}{"alg": "RS256", "kid": "rsa-1"}
+	esHeader := map[string]interface{}{"alg": "ES256", "kid": "ec-1"}
+
+	cases := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		want     error
+	}{
+		{"hs256", hs, signJWT(hsHeader, claims(nil), signHS256("secret")), nil},
+		{"wrong secret", hs, signJWT(hsHeader, claims(nil), signHS256("guess")), errInvalidToken},
+		{"alg none", hs, signJWT(map[string]interface{}{"alg": "none"}, claims(nil), func([]byte) []byte { return nil }), errInvalidToken},
+		{"hs256 against jwks", jwks, signJWT(hsHeader, claims(nil), signHS256("")), errInvalidToken},
+		{"rs256 against secret", hs, signJWT(rsHeader, claims(nil), signRS256), errInvalidToken},
+		{"not a jwt", hs, "abc.def", errInvalidToken},
+
+		{"rs256", jwks, signJWT(rsHeader, claims(nil), signRS256), nil},
+		{"rs256 bad signature", jwks, signJWT(rsHeader, claims(nil), corrupt(signRS256)), errInvalidToken},
+		{"es256", jwks, signJWT(esHeader, claims(nil), signES256), nil},
+		{"es256 bad signature", jwks, signJWT(esHeader, claims(nil), corrupt(signES256)), errInvalidToken},
+		{"es256 asn1 signature", jwks, signJWT(esHeader, claims(nil), signES256DER), errInvalidToken},
+		{"rs256 naming ec key", jwks, signJWT(map[string]interface{}{"alg": "RS256", "kid": "ec-1"}, claims(nil), signRS256), errInvalidToken},
+		{"es256 naming rsa key", jwks, signJWT(map[string]interface{}{"alg": "ES256", "kid": "rsa-1"}, claims(nil), signES256), errInvalidToken},
+		{"unknown kid", jwks, signJWT(map[string]interface{}{"alg": "RS256", "kid": "rsa-2"}, claims(nil), signRS256), errInvalidToken},
+		{"no kid with several keys", jwks, signJWT(map[string]interface{}{"alg": "RS256"}, claims(nil), signRS256), errInvalidToken},
+
+		{"expired", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { c["exp"] = jwtNow.Add(-time.Minute).Unix() }), signHS256("secret")), errExpiredToken},
+		{"expired within leeway", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { c["exp"] = jwtNow.Add(-10 * time.Second).Unix() }), signHS256("secret")), nil},
+		{"no exp", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { delete(c, "exp") }), signHS256("secret")), errExpiredToken},
+		{"not yet valid", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { c["nbf"] = jwtNow.Add(time.Minute).Unix() }), signHS256("secret")), errInvalidToken},
+		{"audience array", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { c["aud"] = []string{"other", "authenticated"} }), signHS256("secret")), nil},
+		{"wrong audience", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { c["aud"] = "anon" }), signHS256("secret")), errInvalidToken},
+		{"wrong audience array", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { c["aud"] = []string{"anon"} }), signHS256("secret")), errInvalidToken},
+		{"wrong issuer", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }), signHS256("secret")), errInvalidToken},
+		{"no subject", hs, signJWT(hsHeader, claims(func(c map[string]interface{}) { delete(c, "sub") }), signHS256("secret")), errInvalidToken},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user, err := c.verifier.Verify(c.token)
+			if !errors.Is(err, c.want) || (c.want != nil && err == nil) {
+				t.Fatalf("Verify = %v, want %v", err, c.want)
+			}
+			if c.want == nil && (user.ID != "user-1" || user.Email != "ada@example.com" || user.Role != "authenticated") {
+				t.Errorf("user = %+v", user)
+			}
+		})
+	}
+}
+
+func TestLoadJWKSVerifierRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"empty":     `{"keys":[]}`,
+		"malformed": `{"keys":`,
+		"curve":     `{"keys":[{"kid":"k","kty":"EC","crv":"P-384","x":"AA","y":"AA"}]}`,
+		"type":      `{"keys":[{"kid":"k","kty":"oct"}]}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name+".json")
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := LoadJWKSVerifier(path); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+	if _, err := LoadJWKSVerifier(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing file: loaded")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	cases := map[string]string{
+		"":                   "",
+		"Bearer":             "",
+		"Bearer ":            "",
+		"Basic dXNlcjpwdw==": "",
+		"Token abc":          "",
+		"Bearer abc":         "abc",
+		"bearer abc":         "abc",
+	}
+	for header, want := range cases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		token, err := bearerToken(r)
+		if token != want || (want == "") != errors.Is(err, errMissingToken) {
+			t.Errorf("bearerToken(%q) = %q, %v", header, token, err)
+		}
+	}
+}
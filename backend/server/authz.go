@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role is a user's relationship to a project.
+type Role string
+
+const (
+	// RoleLead is the ideator who created the project (ideas.uid).
+	RoleLead Role = "lead"
+	// RoleContributor is an approved idea_contributors member.
+	RoleContributor Role = "contributor"
+	// RoleInvestor is an approved member who joined with the Investor role.
+	RoleInvestor Role = "investor"
+	// RoleViewer is a member whose application is still pending.
+	RoleViewer Role = "viewer"
+	// RoleNone means the user has no relationship to the project.
+	RoleNone Role = ""
+)
+
+// Contribution is the part of an idea_contributors row used to derive roles.
+type Contribution struct {
+	Role           string `json:"role"`
+	ApprovedStatus string `json:"approved_status"`
+}
+
+// idea_contributors.approved_status values that grant a role. Any other
+// status, such as rejected or withdrawn, grants none.
+const (
+	contributionApproved = "approved"
+	contributionPending  = "pending"
+)
+
+// roleFromContributions picks the strongest role granted by a user's
+// idea_contributors rows for one project. Approved rows make the user a
+// contributor or, for the Investor role, an investor; a pending
+// application only lets them view the project.
+func roleFromContributions(contributions []Contribution) Role {
+	role := RoleNone
+	for _, c := range contributions {
+		switch c.ApprovedStatus {
+		case contributionApproved:
+			if !strings.EqualFold(c.Role, "investor") {
+				return RoleContributor
+			}
+			role = RoleInvestor
+		case contributionPending:
+			if role == RoleNone {
+				role = RoleViewer
+			}
+		}
+	}
+	return role
+}
+
+// Endpoint policies: which project roles may perform an action.
+var (
+	// anyMember may read project plans, tasks, history and reviews.
+	anyMember = []Role{RoleLead, RoleContributor, RoleInvestor, RoleViewer}
+	// leadOnly may change the plan and review work.
+	leadOnly = []Role{RoleLead}
+	// workers may act on tasks; contributors only on tasks assigned to them.
+	workers = []Role{RoleLead, RoleContributor}
+	// auditors may read the project's audit log, which funding decisions
+	// rely on.
+	auditors = []Role{RoleLead, RoleInvestor}
+)
+
+// ProjectAccess is the caller's resolved role in a project.
+type ProjectAccess struct {
+	ProjectID string
+	UserID    string
+	Role      Role
+}
+
+// allows reports whether the caller holds one of roles.
+func (a ProjectAccess) allows(roles []Role) bool {
+	for _, role := range roles {
+		if a.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// isAssignee reports whether the caller is assigned to task.
+func (a ProjectAccess) isAssignee(task Task) bool {
+	return task.AssigneeID != "" && task.AssigneeID == a.UserID
+}
+
+// errForbidden is returned when the caller's role does not permit an action.
+var errForbidden = errors.New("forbidden")
+
+// projectAccess resolves the authenticated caller's role in projectID.
+func (s *server) projectAccess(ctx context.Context, projectID string) (ProjectAccess, error) {
+	user, ok := userFromContext(ctx)
+	if !ok {
+		return ProjectAccess{}, errForbidden
+	}
+	role, err := s.members.ProjectRole(ctx, projectID, user.ID)
+	if err != nil {
+		return ProjectAccess{}, err
+	}
+	return ProjectAccess{ProjectID: projectID, UserID: user.ID, Role: role}, nil
+}
+
+// writeAccessDenied reports why the caller may not perform an action.
+func writeAccessDenied(w http.ResponseWriter, access ProjectAccess, roles []Role) {
+	if access.Role == RoleNone {
+		writeError(w, "You are not a member of this project", http.StatusForbidden)
+		return
+	}
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	writeError(w, fmt.Sprintf("This action requires one of the roles: %s", strings.Join(names, ", ")), http.StatusForbidden)
+}
+
+// authorizeProject checks that the caller holds one of roles in projectID,
+// writing the error response and returning false otherwise.
+func (s *server) authorizeProject(w http.ResponseWriter, r *http.Request, projectID string, roles []Role) (ProjectAccess, bool) {
+	access, err := s.projectAccess(r.Context(), projectID)
+	if err != nil {
+		if errors.Is(err, errForbidden) {
+			writeError(w, "You are not a member of this project", http.StatusForbidden)
+			return access, false
+		}
+		fmt.Printf("Error resolving project role: %v\n", err)
+		writeRepoError(w, err, "Project not found")
+		return access, false
+	}
+	if !access.allows(roles) {
+		writeAccessDenied(w, access, roles)
+		return access, false
+	}
+	setAuditProject(r.Context(), projectID)
+	return access, true
+}
+
+// authorizeMilestone loads a milestone and checks the caller's role in its project.
+func (s *server) authorizeMilestone(w http.ResponseWriter, r *http.Request, milestoneID string, roles []Role) (Milestone, ProjectAccess, bool) {
+	milestone, err := s.milestones.Get(r.Context(), milestoneID)
+	if err != nil {
+		fmt.Printf("Error fetching milestone: %v\n", err)
+		writeRepoError(w, err, "Milestone not found")
+		return Milestone{}, ProjectAccess{}, false
+	}
+	access, ok := s.authorizeProject(w, r, milestone.ProjectID, roles)
+	return milestone, access, ok
+}
+
+// authorizeTask loads a task and checks the caller's role in the project
+// its milestone belongs to.
+func (s *server) authorizeTask(w http.ResponseWriter, r *http.Request, taskID string, roles []Role) (Task, ProjectAccess, bool) {
+	task, err := s.tasks.Get(r.Context(), taskID)
+	if err != nil {
+		fmt.Printf("Error fetching task: %v\n", err)
+		writeRepoError(w, err, "Task not found")
+		return Task{}, ProjectAccess{}, false
+	}
+	_, access, ok := s.authorizeMilestone(w, r, task.MilestoneID, roles)
+	return task, access, ok
+}
+
+// authorizeTaskWork allows the project lead or the task's assignee.
+func (s *server) authorizeTaskWork(w http.ResponseWriter, r *http.Request, taskID string) (Task, ProjectAccess, bool) {
+	task, access, ok := s.authorizeTask(w, r, taskID, workers)
+	if !ok {
+		return task, access, false
+	}
+	if access.Role != RoleLead && !access.isAssignee(task) {
+		writeError(w, "Only the project lead or the task's assignee can do this", http.StatusForbidden)
+		return task, access, false
+	}
+	return task, access, true
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestRoleFromContributions(t *testing.T) {
+	tests := []struct {
+		name          string
+		contributions []Contribution
+		want          Role
+	}{
+		{"none", nil, RoleNone},
+		{"approved contributor", []Contribution{{"Developer", "approved"}}, RoleContributor},
+		{"approved investor", []Contribution{{"Investor", "approved"}}, RoleInvestor},
+		{"pending application", []Contribution{{"Developer", "pending"}}, RoleViewer},
+		{"pending investor", []Contribution{{"Investor", "pending"}}, RoleViewer},
+		{"rejected", []Contribution{{"Developer", "rejected"}}, RoleNone},
+		{"withdrawn investor", []Contribution{{"Investor", "withdrawn"}}, RoleNone},
+		{"missing status", []Contribution{{"Investor", ""}}, RoleNone},
+		{"strongest wins", []Contribution{{"Developer", "pending"}, {"Investor", "approved"}, {"Designer", "approved"}}, RoleContributor},
+		{"investor over viewer", []Contribution{{"Developer", "pending"}, {"Investor", "approved"}}, RoleInvestor},
+	}
+	for _, tt := range tests {
+		if got := roleFromContributions(tt.contributions); got != tt.want {
+			t.Errorf("%s: role = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
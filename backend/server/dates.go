@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Date is a schedule date: either a calendar date ("2026-03-01") or an
+// instant with an explicit time zone (RFC 3339). It encodes in the form it
+// was parsed from, but a calendar date stored in a timestamptz column
+// reads back as a timestamp at midnight UTC. The zero Date is an unset
+// date and encodes as null.
+type Date struct {
+	t        time.Time
+	dateOnly bool
+}
+
+// dateError reports a value that is neither a calendar date nor a zoned
+// timestamp.
+type dateError struct {
+	value string
+}
+
+func (e *dateError) Error() string {
+	return fmt.Sprintf("invalid date %q: use YYYY-MM-DD or an RFC 3339 timestamp with a time zone", e.value)
+}
+
+// ParseDate parses a calendar date or an RFC 3339 timestamp. Timestamps
+// without a zone are rejected because their instant is ambiguous.
+func ParseDate(s string) (Date, error) {
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return Date{t: t, dateOnly: true}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return Date{t: t}, nil
+	}
+	return Date{}, &dateError{value: s}
+}
+
+func (d Date) IsZero() bool {
+	return d.t.IsZero()
+}
+
+func (d Date) String() string {
+	switch {
+	case d.IsZero():
+		return ""
+	case d.dateOnly:
+		return d.t.Format(dateLayout)
+	default:
+		return d.t.Format(time.RFC3339)
+	}
+}
+
+// start is the first instant the date covers. Calendar dates are taken
+// in UTC.
+func (d Date) start() time.Time {
+	return d.t
+}
+
+// end is the last instant the date covers: the end of the day for a
+// calendar date, so a milestone due on a timeline's end date is inside it.
+func (d Date) end() time.Time {
+	if d.dateOnly {
+		return d.t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return d.t
+}
+
+// After reports whether d ends after other does.
+func (d Date) After(other Date) bool {
+	return d.end().After(other.end())
+}
+
+// Before reports whether d starts before other does.
+func (d Date) Before(other Date) bool {
+	return d.start().Before(other.start())
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts null and "" as an unset date, as stored by
+// clients that predate date validation. Handlers check that required
+// dates are set.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Date{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid date %s: must be a string", data)
+	}
+	if s == "" {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// validateDateRange checks that a phase has both dates and does not end
+// before it starts.
+func validateDateRange(start, end Date) error {
+	if start.IsZero() {
+		return fmt.Errorf("Start date is required")
+	}
+	if end.IsZero() {
+		return fmt.Errorf("End date is required")
+	}
+	if end.end().Before(start.start()) {
+		return fmt.Errorf("End date %s is before start date %s", end, start)
+	}
+	return nil
+}
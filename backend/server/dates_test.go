@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	cases := []struct {
+		in    string
+		out   string
+		start time.Time
+		end   time.Time
+	}{
+		{"2030-01-31", "2030-01-31",
+			time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)},
+		{"2030-01-31T09:30:00+02:00", "2030-01-31T09:30:00+02:00",
+			time.Date(2030, 1, 31, 7, 30, 0, 0, time.UTC),
+			time.Date(2030, 1, 31, 7, 30, 0, 0, time.UTC)},
+		// A calendar date as a timestamptz column returns it
+		{"2030-01-31T00:00:00+00:00", "2030-01-31T00:00:00Z",
+			time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		d, err := ParseDate(c.in)
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", c.in, err)
+			continue
+		}
+		if d.String() != c.out || !d.start().Equal(c.start) || !d.end().Equal(c.end) {
+			t.Errorf("ParseDate(%q) = %s, from %s to %s", c.in, d, d.start(), d.end())
+		}
+	}
+
+	for _, bad := range []string{"", "next week", "31/01/2030", "2030-02-30", "2030-01-31T09:30:00", "2030-01-31 09:30:00Z"} {
+		var dateErr *dateError
+		if _, err := ParseDate(bad); !errors.As(err, &dateErr) {
+			t.Errorf("ParseDate(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestDateComparesWholeDays(t *testing.T) {
+	day, _ := ParseDate("2030-01-31")
+	evening, _ := ParseDate("2030-01-31T18:00:00Z")
+	next, _ := ParseDate("2030-02-01")
+
+	// An instant during a day is neither after nor before it
+	if evening.After(day) || evening.Before(day) {
+		t.Error("evening falls outside its day")
+	}
+	if !next.After(day) || !day.Before(next) || next.Before(day) {
+		t.Error("days out of order")
+	}
+	if err := validateDateRange(day, day); err != nil {
+		t.Errorf("one-day range: %v", err)
+	}
+	if err := validateDateRange(next, day); err == nil {
+		t.Error("range ending before it starts")
+	}
+	if err := validateDateRange(Date{}, day); err == nil {
+		t.Error("range without a start")
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	var v struct {
+		Due Date `json:"due"`
+	}
+	for _, raw := range []string{`{"due":null}`, `{"due":""}`} {
+		v.Due, _ = ParseDate("2030-01-31")
+		if err := json.Unmarshal([]byte(raw), &v); err != nil || !v.Due.IsZero() {
+			t.Errorf("%s: %v, %v", raw, v.Due, err)
+		}
+	}
+	out, _ := json.Marshal(v)
+	if string(out) != `{"due":null}` {
+		t.Errorf("unset date encodes as %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"due":"2030-01-31"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := json.Marshal(v); string(out) != `{"due":"2030-01-31"}` {
+		t.Errorf("date encodes as %s", out)
+	}
+
+	var dateErr *dateError
+	if err := json.Unmarshal([]byte(`{"due":"soon"}`), &v); !errors.As(err, &dateErr) {
+		t.Errorf("malformed date: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"due":20300131}`), &v); err == nil {
+		t.Error("numeric date accepted")
+	}
+}
+
+func TestClearMalformedDates(t *testing.T) {
+	var rows interface{}
+	json.Unmarshal([]byte(`[{"id":"p1","start_date":"2030-01-01","end_date":"someday","name":"not a date",
+		"milestones":[{"id":"m1","due_date":"2030-13-01"}]}]`), &rows)
+	clearMalformedDates(rows)
+
+	row := rows.([]interface{})[0].(map[string]interface{})
+	if row["start_date"] != "2030-01-01" || row["end_date"] != nil || row["name"] != "not a date" {
+		t.Errorf("row = %v", row)
+	}
+	if nested := row["milestones"].([]interface{})[0].(map[string]interface{}); nested["due_date"] != nil {
+		t.Errorf("joined row = %v", nested)
+	}
+}
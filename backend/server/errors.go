@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"imara-shared/apierror"
+)
+
+// writeError responds with an error envelope whose code follows from the
+// status, as http.Error would with plain text.
+func writeError(w http.ResponseWriter, message string, status int) {
+	apierror.Write(w, apierror.New(status, apierror.CodeForStatus(status), message))
+}
+
+// writeBodyError reports a request body that could not be decoded.
+func writeBodyError(w http.ResponseWriter, err error) {
+	apierror.Write(w, apierror.New(http.StatusBadRequest, apierror.CodeInvalidBody, fmt.Sprintf("Invalid request body: %v", err)))
+}
+
+// writeRepoError reports a repository failure, mapping ErrNotFound to 404
+// with the given message.
+func writeRepoError(w http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, notFound, http.StatusNotFound)
+		return
+	}
+	writeServerError(w, err)
+}
+
+// writeServerError reports an unexpected failure without passing on its
+// message, which may come from the database.
+func writeServerError(w http.ResponseWriter, err error) {
+	apierror.Write(w, serverError(err))
+}
+
+// postgrestError matches the "(code) message" errors of postgrest-go.
+var postgrestError = regexp.MustCompile(`^\(([0-9A-Z]{5}|PGRST\d+)\) `)
+
+// serverError maps err to an API error: repository sentinels to 404 and
+// 409, PostgREST errors by their code, and failures to reach Supabase to
+// 503.
+func serverError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, ErrNotFound):
+		return apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Not found")
+	case errors.Is(err, ErrConflict):
+		return apierror.New(http.StatusConflict, apierror.CodeConflict, "The record was modified concurrently; reload and retry")
+	}
+
+	// Repositories may wrap postgrest-go errors, so look at the innermost one
+	inner := err
+	for errors.Unwrap(inner) != nil {
+		inner = errors.Unwrap(inner)
+	}
+	if m := postgrestError.FindStringSubmatch(inner.Error()); m != nil {
+		return apierror.PostgREST(m[1])
+	}
+	return apierror.Upstream(err)
+}
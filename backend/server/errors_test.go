@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteServerErrorHidesDatabaseMessages(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("creating task: %w", errors.New(`(23505) duplicate key value violates unique constraint "milestone_tasks_pkey"`)), http.StatusConflict},
+		{errors.New(`(PGRST116) JSON object requested, multiple (or no) rows returned from milestone_tasks`), http.StatusNotFound},
+		{errors.New(`(42P01) relation "milestone_tasks" does not exist`), http.StatusInternalServerError},
+		{fmt.Errorf("task t1: %w", ErrNotFound), http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		writeServerError(w, c.err)
+		if w.Code != c.status {
+			t.Errorf("%v: status %d, want %d", c.err, w.Code, c.status)
+		}
+		if body := w.Body.String(); strings.Contains(body, "milestone_tasks") || strings.Contains(body, "t1") {
+			t.Errorf("%v: envelope %s passes the message on", c.err, body)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Domain event types.
+const (
+	EventMilestoneCreated  = "milestone.created"
+	EventTaskAssigned      = "task.assigned"
+	EventTaskStatusChanged = "task.status_changed"
+	EventTaskReviewed      = "task.reviewed"
+	EventEvidenceUploaded  = "evidence.uploaded"
+	EventTaskOverdue       = "task.overdue"
+	EventMilestoneOverdue  = "milestone.overdue"
+)
+
+// DomainEvent records something that happened to a project's plan. Other
+// features subscribe to them instead of being called from each handler.
+type DomainEvent struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	ProjectID   string                 `json:"project_id"`
+	MilestoneID string                 `json:"milestone_id,omitempty"`
+	TaskID      string                 `json:"task_id,omitempty"`
+	ActorID     string                 `json:"actor_id,omitempty"`
+	OccurredAt  string                 `json:"occurred_at"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventHandler consumes domain events. Handlers run synchronously on the
+// publishing goroutine, so slow work should be handed off.
+type EventHandler func(ctx context.Context, event DomainEvent)
+
+// EventBus delivers domain events to in-process subscribers.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers []subscription
+}
+
+type subscription struct {
+	types   []string
+	handler EventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers handler for the given event types, or for every
+// event when no types are given.
+func (b *EventBus) Subscribe(handler EventHandler, types ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, subscription{types: types, handler: handler})
+}
+
+// Publish fills in the event's ID and time and delivers it. A panicking
+// handler is logged and does not stop delivery to the others.
+func (b *EventBus) Publish(ctx context.Context, event DomainEvent) {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.OccurredAt == "" {
+		event.OccurredAt = now()
+	}
+
+	b.mu.RLock()
+	handlers := append([]subscription(nil), b.handlers...)
+	b.mu.RUnlock()
+
+	for _, sub := range handlers {
+		if len(sub.types) > 0 && !contains(sub.types, event.Type) {
+			continue
+		}
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					fmt.Printf("Error handling %s event: %v\n", event.Type, p)
+				}
+			}()
+			sub.handler(ctx, event)
+		}()
+	}
+}
+
+// publishTaskEvent publishes an event about task, looking up the project
+// its milestone belongs to. The task's title and assignee are added to
+// data.
+func (s *server) publishTaskEvent(ctx context.Context, eventType string, task Task, actorID string, data map[string]interface{}) {
+	milestone, err := s.milestones.Get(ctx, task.MilestoneID)
+	if err != nil {
+		fmt.Printf("Error resolving project for %s event: %v\n", eventType, err)
+		return
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["title"] = task.Title
+	data["assignee_id"] = task.AssigneeID
+
+	s.events.Publish(ctx, DomainEvent{
+		Type:        eventType,
+		ProjectID:   milestone.ProjectID,
+		MilestoneID: task.MilestoneID,
+		TaskID:      task.ID,
+		ActorID:     actorID,
+		Data:        data,
+	})
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxEvidenceText caps the length of a text note.
+const maxEvidenceText = 20000
+
+// evidenceOpenStatuses are the task statuses in which the assignee may add
+// or remove evidence for the next submission. Once a round is submitted
+// its evidence is frozen for the reviewer.
+var evidenceOpenStatuses = []string{TaskPending, TaskInProgress, TaskChangesRequested}
+
+// Add task evidence endpoint
+func (s *server) handleAddEvidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	// Only the assignee submits evidence for their task
+	task, access, ok := s.authorizeTask(w, r, taskId, workers)
+	if !ok {
+		return
+	}
+	if !access.isAssignee(task) {
+		writeError(w, "Only the task's assignee can add evidence", http.StatusForbidden)
+		return
+	}
+	if !contains(evidenceOpenStatuses, normalizeTaskStatus(task.Status)) {
+		writeError(w, "Evidence can only be added while the task is pending, in progress or has changes requested", http.StatusConflict)
+		return
+	}
+
+	// Evidence added now is part of the next submission
+	item := Evidence{
+		TaskID:          task.ID,
+		ProjectID:       access.ProjectID,
+		SubmissionRound: task.SubmissionRound + 1,
+		UploadedBy:      access.UserID,
+	}
+
+	// Files count against the project's quota; notes do not
+	var quota int64
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		s.allowTransfer(w, true)
+		policy, err := s.uploadPolicy(r, item.ProjectID)
+		if err != nil {
+			fmt.Printf("Error fetching upload policy: %v\n", err)
+			writeServerError(w, err)
+			return
+		}
+		if !s.storeEvidenceFile(w, r, &item, policy) {
+			return
+		}
+		quota = policy.QuotaBytes
+	} else if !readEvidenceNote(w, r, &item) {
+		return
+	}
+
+	created, err := s.evidence.Create(r.Context(), item, quota)
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		writeError(w, fmt.Sprintf("Project storage quota of %d bytes exceeded", quota), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, ErrConflict):
+		writeError(w, evidenceFileBusy, http.StatusConflict)
+		return
+	case err != nil:
+		fmt.Printf("Error saving evidence: %v\n", err)
+		writeRepoError(w, err, "Task not found")
+		return
+	}
+	recordAudit(r.Context(), "evidence", created.ID, nil, created)
+
+	s.publishTaskEvent(r.Context(), EventEvidenceUploaded, task, access.UserID, map[string]interface{}{
+		"evidence_id":      created.ID,
+		"kind":             created.Kind,
+		"file_name":        created.FileName,
+		"submission_round": created.SubmissionRound,
+	})
+
+	writeJSONStatus(w, http.StatusCreated, created)
+}
+
+// evidenceFileBusy reports an upload of content the evidence collector is
+// deleting at that moment.
+const evidenceFileBusy = "An identical file is being removed from storage; upload it again shortly"
+
+// storeEvidenceFile checks the uploaded "file" form field against the
+// project's upload policy and the virus scanner, saves it in the evidence
+// store and fills in the item's file metadata.
+func (s *server) storeEvidenceFile(w http.ResponseWriter, r *http.Request, item *Evidence, policy UploadPolicy) bool {
+	// Leave room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, policy.MaxFileBytes+1<<20)
+	if err := r.ParseMultipartForm(s.uploadLimits.memoryBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, fmt.Sprintf("File exceeds the %d byte limit", policy.MaxFileBytes), http.StatusRequestEntityTooLarge)
+			return false
+		}
+		writeError(w, "Error parsing form", http.StatusBadRequest)
+		return false
+	}
+
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, "Error retrieving file", http.StatusBadRequest)
+		return false
+	}
+	defer file.Close()
+
+	if handler.Size > policy.MaxFileBytes {
+		writeError(w, fmt.Sprintf("File exceeds the %d byte limit", policy.MaxFileBytes), http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	// The stored type comes from the content, not the client's claim
+	fileName := sanitizeFileName(handler.Filename)
+	mimeType, err := sniffMimeType(file, fileName)
+	if err != nil {
+		writeError(w, "Error reading file", http.StatusBadRequest)
+		return false
+	}
+	if !mimeTypeAllowed(policy.AllowedMimeTypes, mediaTypeOf(mimeType)) {
+		writeError(w, fmt.Sprintf("Files of type %s are not accepted for this project", mediaTypeOf(mimeType)), http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	// Turn away uploads that cannot fit before scanning and storing them;
+	// the quota itself is enforced when the item is recorded
+	used, err := s.evidence.ProjectUsage(r.Context(), item.ProjectID)
+	if err != nil {
+		fmt.Printf("Error fetching evidence usage: %v\n", err)
+		writeServerError(w, err)
+		return false
+	}
+	if used+handler.Size > policy.QuotaBytes {
+		writeError(w, fmt.Sprintf("Project storage quota exceeded (%d of %d bytes used)", used, policy.QuotaBytes), http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	// Files are rejected if they cannot be scanned
+	result, err := s.scanner.Scan(r.Context(), file)
+	if err != nil {
+		fmt.Printf("Error scanning evidence: %v\n", err)
+		writeError(w, "Virus scanning is unavailable, try again later", http.StatusServiceUnavailable)
+		return false
+	}
+	if !result.Clean {
+		fmt.Printf("Rejected evidence upload for task %s: %s\n", item.TaskID, result.Signature)
+		writeError(w, fmt.Sprintf("File rejected by virus scan: %s", result.Signature), http.StatusUnprocessableEntity)
+		return false
+	}
+
+	// Files are stored under their SHA-256 so identical uploads are kept
+	// once. Claiming the file first keeps the evidence collector from
+	// deleting a copy that is already stored while this upload reuses it.
+	digest := sha256.New()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeError(w, "Error reading file", http.StatusInternalServerError)
+		return false
+	}
+	if _, err := io.Copy(digest, file); err != nil {
+		writeError(w, "Error reading file", http.StatusInternalServerError)
+		return false
+	}
+	if err := s.evidence.ClaimFile(r.Context(), hex.EncodeToString(digest.Sum(nil))); err != nil {
+		if errors.Is(err, ErrConflict) {
+			writeError(w, evidenceFileBusy, http.StatusConflict)
+			return false
+		}
+		fmt.Printf("Error claiming evidence file: %v\n", err)
+		writeServerError(w, err)
+		return false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeError(w, "Error reading file", http.StatusInternalServerError)
+		return false
+	}
+
+	obj, err := s.files.Put(r.Context(), file)
+	if err != nil {
+		fmt.Printf("Error storing evidence: %v\n", err)
+		writeError(w, "Error saving file", http.StatusInternalServerError)
+		return false
+	}
+
+	item.Kind = EvidenceFile
+	item.FileName = fileName
+	item.MimeType = mimeType
+	item.Size = obj.Size
+	item.SHA256 = obj.Key
+	return true
+}
+
+// readEvidenceNote decodes a link or text note from a JSON body.
+func readEvidenceNote(w http.ResponseWriter, r *http.Request, item *Evidence) bool {
+	var req CreateEvidenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Printf("Error decoding evidence request: %v\n", err)
+		writeBodyError(w, err)
+		return false
+	}
+
+	var content string
+	switch req.Kind {
+	case EvidenceLink:
+		link, err := url.Parse(strings.TrimSpace(req.URL))
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+			writeError(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+			return false
+		}
+		item.URL = link.String()
+		item.MimeType = "text/uri-list"
+		content = item.URL
+	case EvidenceText:
+		body := strings.TrimSpace(req.Body)
+		if body == "" {
+			writeError(w, "body is required for text evidence", http.StatusBadRequest)
+			return false
+		}
+		if len(body) > maxEvidenceText {
+			writeError(w, fmt.Sprintf("body must be at most %d characters", maxEvidenceText), http.StatusBadRequest)
+			return false
+		}
+		item.Body = body
+		item.MimeType = "text/plain"
+		content = item.Body
+	default:
+		writeError(w, "kind must be link or text; upload files as multipart form data", http.StatusBadRequest)
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	item.Kind = req.Kind
+	item.Size = int64(len(content))
+	item.SHA256 = hex.EncodeToString(sum[:])
+	return true
+}
+
+// List task evidence endpoint
+func (s *server) handleListEvidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	round := 0
+	if raw := r.URL.Query().Get("submission_round"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, "submission_round must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		round = n
+	}
+
+	if _, _, ok := s.authorizeTask(w, r, taskId, anyMember); !ok {
+		return
+	}
+
+	items, err := s.evidence.ListByTask(r.Context(), taskId)
+	if err != nil {
+		fmt.Printf("Error fetching evidence: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	if round != 0 {
+		filtered := []Evidence{}
+		for _, item := range items {
+			if item.SubmissionRound == round {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	writeJSON(w, items)
+}
+
+// Delete task evidence endpoint
+func (s *server) handleDeleteEvidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	evidenceId := mux.Vars(r)["evidenceId"]
+
+	item, err := s.evidence.Get(r.Context(), evidenceId)
+	if err != nil {
+		fmt.Printf("Error fetching evidence: %v\n", err)
+		writeRepoError(w, err, "Evidence not found")
+		return
+	}
+
+	task, access, ok := s.authorizeTask(w, r, item.TaskID, workers)
+	if !ok {
+		return
+	}
+	if access.Role != RoleLead && item.UploadedBy != access.UserID {
+		writeError(w, "Only the project lead or the uploader can delete evidence", http.StatusForbidden)
+		return
+	}
+	// Submitted evidence is part of the review record
+	if item.SubmissionRound <= task.SubmissionRound || !contains(evidenceOpenStatuses, normalizeTaskStatus(task.Status)) {
+		writeError(w, "Evidence that has been submitted for review cannot be deleted", http.StatusConflict)
+		return
+	}
+
+	if err := s.evidence.Delete(r.Context(), item.ID); err != nil {
+		fmt.Printf("Error deleting evidence: %v\n", err)
+		writeRepoError(w, err, "Evidence not found")
+		return
+	}
+	recordAudit(r.Context(), "evidence", item.ID, item, nil)
+
+	// The stored file may be shared with other items; the evidence
+	// collector deletes it once nothing refers to it
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Signed download links last five minutes unless a shorter or longer
+// lifetime, up to an hour, is requested.
+const (
+	defaultDownloadURLTTL = 5 * time.Minute
+	maxDownloadURLTTL     = time.Hour
+)
+
+// evidenceDownloadPath is the download route for an evidence item.
+func evidenceDownloadPath(evidenceId string) string {
+	return "/api/evidence/" + evidenceId + "/download"
+}
+
+// Download task evidence endpoint
+func (s *server) handleDownloadEvidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	evidenceId := mux.Vars(r)["evidenceId"]
+
+	item, err := s.evidence.Get(r.Context(), evidenceId)
+	if err != nil {
+		fmt.Printf("Error fetching evidence: %v\n", err)
+		writeRepoError(w, err, "Evidence not found")
+		return
+	}
+
+	if _, _, ok := s.authorizeTask(w, r, item.TaskID, anyMember); !ok {
+		return
+	}
+
+	s.serveEvidence(w, r, item)
+}
+
+// Signed evidence download endpoint, reached without an access token
+func (s *server) handleSignedDownloadEvidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	evidenceId := mux.Vars(r)["evidenceId"]
+	query := r.URL.Query()
+
+	if !s.signer.verify(evidenceDownloadPath(evidenceId), query.Get("expires"), query.Get("signature")) {
+		writeError(w, "Download link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	item, err := s.evidence.Get(r.Context(), evidenceId)
+	if err != nil {
+		fmt.Printf("Error fetching evidence: %v\n", err)
+		writeRepoError(w, err, "Evidence not found")
+		return
+	}
+
+	s.serveEvidence(w, r, item)
+}
+
+// Create signed evidence download link endpoint
+func (s *server) handleEvidenceDownloadURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	evidenceId := mux.Vars(r)["evidenceId"]
+
+	ttl := defaultDownloadURLTTL
+	if raw := r.URL.Query().Get("expires_in"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > maxDownloadURLTTL {
+			writeError(w, fmt.Sprintf("expires_in must be between 1 and %d seconds", int(maxDownloadURLTTL.Seconds())), http.StatusBadRequest)
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	item, err := s.evidence.Get(r.Context(), evidenceId)
+	if err != nil {
+		fmt.Printf("Error fetching evidence: %v\n", err)
+		writeRepoError(w, err, "Evidence not found")
+		return
+	}
+
+	if _, _, ok := s.authorizeTask(w, r, item.TaskID, anyMember); !ok {
+		return
+	}
+
+	signedURL, expiresAt := s.signer.sign(evidenceDownloadPath(item.ID), ttl)
+	writeJSON(w, map[string]string{
+		"url":        signedURL,
+		"expires_at": expiresAt.Format(time.RFC3339),
+	})
+}
+
+// serveEvidence writes an evidence item's content, honouring Range and
+// conditional requests. Content is always sent as an attachment so
+// uploaded HTML or SVG is never rendered in the app's origin.
+func (s *server) serveEvidence(w http.ResponseWriter, r *http.Request, item Evidence) {
+	s.allowTransfer(w, false)
+
+	var content io.ReadSeeker
+	fileName := item.FileName
+	switch item.Kind {
+	case EvidenceFile:
+		file, err := s.files.Open(r.Context(), item.SHA256)
+		if err != nil {
+			fmt.Printf("Error opening evidence file %s: %v\n", item.SHA256, err)
+			writeRepoError(w, err, "Evidence file not found")
+			return
+		}
+		defer file.Close()
+		content = &digestCheckedReader{ReadSeeker: file, item: item}
+	case EvidenceLink:
+		content = strings.NewReader(item.URL)
+		fileName = "evidence-" + item.ID + ".uri"
+	default:
+		content = strings.NewReader(item.Body)
+		fileName = "evidence-" + item.ID + ".txt"
+	}
+
+	mimeType := item.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	modified, _ := time.Parse(time.RFC3339Nano, item.CreatedAt)
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if item.SHA256 != "" {
+		w.Header().Set("ETag", `"`+item.SHA256+`"`)
+	}
+
+	http.ServeContent(w, r, "", modified, content)
+}
+
+// allowTransfer gives a request moving an evidence file the transfer
+// timeout in place of the server's write timeout and, for uploads, its
+// read timeout. Those are sized for API calls and would cut large files
+// off part way.
+func (s *server) allowTransfer(w http.ResponseWriter, upload bool) {
+	var deadline time.Time
+	if timeout := s.config.Timeouts.Transfer; timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	rc := http.NewResponseController(w)
+	if upload {
+		if err := rc.SetReadDeadline(deadline); err != nil {
+			fmt.Printf("Error extending upload read deadline: %v\n", err)
+		}
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		fmt.Printf("Error extending transfer write deadline: %v\n", err)
+	}
+}
+
+// digestCheckedReader logs evidence whose stored bytes no longer match the
+// recorded SHA-256. The response is already under way by then, so the
+// client sees a truncated body.
+type digestCheckedReader struct {
+	io.ReadSeeker
+	item Evidence
+}
+
+func (d *digestCheckedReader) Read(p []byte) (int, error) {
+	n, err := d.ReadSeeker.Read(p)
+	if errors.Is(err, errDigestMismatch) {
+		fmt.Printf("Error serving evidence %s: %v\n", d.item.ID, err)
+	}
+	return n, err
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// evidenceFileGrace is how long a stored file must go unused before the
+// collector may delete it. It outlasts any upload between claiming a file
+// and recording its evidence item.
+const evidenceFileGrace = time.Hour
+
+// collectEvidenceFiles deletes stored files that no evidence item refers
+// to. This is the only place stored evidence is deleted: files are shared
+// by identical uploads, so removing an item leaves its file here.
+func (s *server) collectEvidenceFiles(ctx context.Context) error {
+	hashes, err := s.evidence.CollectFiles(ctx, evidenceFileGrace)
+	if err != nil {
+		return fmt.Errorf("listing unused evidence files: %w", err)
+	}
+	for _, sha256 := range hashes {
+		// A file left marked is retried on the next run
+		if err := s.files.Delete(ctx, sha256); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("deleting evidence file %s: %w", sha256, err)
+		}
+		if err := s.evidence.ForgetFile(ctx, sha256); err != nil {
+			return fmt.Errorf("forgetting evidence file %s: %w", sha256, err)
+		}
+	}
+	return nil
+}
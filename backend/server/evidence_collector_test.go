@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// uploadEvidence posts content as a file for task as user.
+func (api *testAPI) uploadEvidence(t *testing.T, user, task, content string) (*http.Response, Evidence) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "notes.txt")
+	part.Write([]byte(content))
+	form.Close()
+
+	req, _ := http.NewRequest("POST", api.URL+"/api/tasks/"+task+"/evidence", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+testToken(user))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var item Evidence
+	if resp.StatusCode == http.StatusCreated {
+		json.NewDecoder(resp.Body).Decode(&item)
+	}
+	return resp, item
+}
+
+// ageEvidenceFiles makes every tracked file look unused for longer than
+// the collector's grace period.
+func ageEvidenceFiles(repos Repositories) {
+	store := repos.Evidence.(*memoryEvidenceRepository).store
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for sha256, file := range store.files {
+		file.lastUsed = file.lastUsed.Add(-2 * evidenceFileGrace)
+		store.files[sha256] = file
+	}
+}
+
+func TestEvidenceCollectorKeepsSharedFiles(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	first := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	second := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Build", "assignee_id": "dev"})
+	ctx := context.Background()
+
+	_, a := api.uploadEvidence(t, "dev", first.ID, "shared evidence")
+	_, b := api.uploadEvidence(t, "dev", second.ID, "shared evidence")
+	if a.SHA256 == "" || a.SHA256 != b.SHA256 {
+		t.Fatalf("uploads stored as %q and %q", a.SHA256, b.SHA256)
+	}
+
+	// Deleting one item leaves the file to the collector, which keeps it
+	// while the other item refers to it
+	if resp := api.do(t, "dev", "DELETE", "/api/evidence/"+a.ID, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("deleting evidence: status %d", resp.StatusCode)
+	}
+	ageEvidenceFiles(api.repos)
+	if err := api.server.collectEvidenceFiles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if resp := api.do(t, "dev", "GET", "/api/evidence/"+b.ID+"/download", nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("downloading shared evidence: status %d", resp.StatusCode)
+	}
+
+	api.do(t, "dev", "DELETE", "/api/evidence/"+b.ID, nil, nil)
+	if err := api.server.collectEvidenceFiles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.server.files.Open(ctx, b.SHA256); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("opening collected file: %v", err)
+	}
+
+	// The same content can be uploaded again afterwards, and is kept for
+	// the grace period after it was last used
+	resp, c := api.uploadEvidence(t, "dev", first.ID, "shared evidence")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("uploading again: status %d", resp.StatusCode)
+	}
+	api.do(t, "dev", "DELETE", "/api/evidence/"+c.ID, nil, nil)
+	if err := api.server.collectEvidenceFiles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.server.files.Open(ctx, c.SHA256); err != nil {
+		t.Fatalf("file collected within its grace period: %v", err)
+	}
+}
+
+func TestEvidenceFilesBeingCollectedCannotBeReused(t *testing.T) {
+	repos := NewMemoryRepositories()
+	evidence := repos.Evidence
+	ctx := context.Background()
+	const sha = "9af4c73b2a919f220f4b008e466b52808a1987122d95ff0f2dde00968e36e844"
+
+	if err := evidence.ClaimFile(ctx, sha); err != nil {
+		t.Fatal(err)
+	}
+	ageEvidenceFiles(repos)
+	collected, err := evidence.CollectFiles(ctx, time.Hour)
+	if err != nil || len(collected) != 1 || collected[0] != sha {
+		t.Fatalf("collected %v, %v", collected, err)
+	}
+	if err := evidence.ClaimFile(ctx, sha); !errors.Is(err, ErrConflict) {
+		t.Errorf("claiming a file being collected: %v", err)
+	}
+
+	// An unfinished collection is picked up again
+	if collected, _ := evidence.CollectFiles(ctx, time.Hour); len(collected) != 1 {
+		t.Errorf("collected %v on the second run", collected)
+	}
+	if err := evidence.ForgetFile(ctx, sha); err != nil {
+		t.Fatal(err)
+	}
+	if err := evidence.ClaimFile(ctx, sha); err != nil {
+		t.Errorf("claiming a collected file: %v", err)
+	}
+}
+
+func TestEvidenceQuotaIsEnforcedOnCreate(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	ctx := context.Background()
+
+	const sha = "9af4c73b2a919f220f4b008e466b52808a1987122d95ff0f2dde00968e36e844"
+	if err := api.repos.Evidence.ClaimFile(ctx, sha); err != nil {
+		t.Fatal(err)
+	}
+	item := Evidence{TaskID: task.ID, ProjectID: "p1", Kind: EvidenceFile, Size: 600, SHA256: sha, SubmissionRound: 1}
+	if _, err := api.repos.Evidence.Create(ctx, item, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.repos.Evidence.Create(ctx, item, 1000); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("second file past the quota: %v", err)
+	}
+
+	// Notes are not counted
+	note := Evidence{TaskID: task.ID, ProjectID: "p1", Kind: EvidenceText, Body: "Done", Size: 4, SubmissionRound: 1}
+	if _, err := api.repos.Evidence.Create(ctx, note, 0); err != nil {
+		t.Errorf("adding a note: %v", err)
+	}
+
+	// Files must have been claimed
+	unclaimed := item
+	unclaimed.SHA256 = "0000000000000000000000000000000000000000000000000000000000000000"
+	unclaimed.Size = 1
+	if _, err := api.repos.Evidence.Create(ctx, unclaimed, 1000); !errors.Is(err, ErrConflict) {
+		t.Errorf("unclaimed file: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+
+	"imara-shared/config"
+)
+
+// EvidenceStore keeps uploaded evidence files addressed by the SHA-256 of
+// their content, so identical uploads are stored once and any change to a
+// stored file is detectable.
+type EvidenceStore interface {
+	// Put stores the content of r and returns its address. Storing content
+	// that is already present is a no-op.
+	Put(ctx context.Context, r io.Reader) (StoredObject, error)
+	// Open returns the content stored under key. It can seek, so ranges
+	// can be served; reading the whole content from the start fails with
+	// errDigestMismatch if the stored bytes no longer hash to key.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the content stored under key.
+	Delete(ctx context.Context, key string) error
+}
+
+// StoredObject describes content held by an EvidenceStore.
+type StoredObject struct {
+	// Key is the lowercase hex SHA-256 of the content.
+	Key  string `json:"sha256"`
+	Size int64  `json:"size"`
+	// Location is the backend-specific address, e.g. a file path,
+	// s3://bucket/key or ipfs://cid.
+	Location string `json:"location"`
+}
+
+var errDigestMismatch = errors.New("stored evidence does not match its SHA-256")
+
+var evidenceKeyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// validEvidenceKey reports whether key is a hex SHA-256 digest. Keys are
+// used in paths and URLs, so anything else is rejected outright.
+func validEvidenceKey(key string) bool {
+	return evidenceKeyPattern.MatchString(key)
+}
+
+// spoolFile is content copied to a temporary file while it was hashed.
+type spoolFile struct {
+	*os.File
+	key  string
+	size int64
+}
+
+// spool copies r into a temporary file in dir, hashing it on the way, and
+// leaves the file positioned at its start. The caller must call cleanup.
+func spool(r io.Reader, dir string) (*spoolFile, error) {
+	tmp, err := os.CreateTemp(dir, "evidence-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp file: %w", err)
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("spooling evidence: %w", err)
+	}
+
+	return &spoolFile{File: tmp, key: hex.EncodeToString(h.Sum(nil)), size: size}, nil
+}
+
+// cleanup closes and removes the temporary file.
+func (f *spoolFile) cleanup() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// verifyingReader hashes content as it is read from the start and, if
+// the complete content does not match the expected key, withholds the
+// final chunk and reports errDigestMismatch. Seeking back to the start restarts the check;
+// ranged reads elsewhere are passed through unverified.
+type verifyingReader struct {
+	src    io.ReadSeekCloser
+	h      hash.Hash
+	want   string
+	size   int64
+	pos    int64
+	hashed int64
+}
+
+func newVerifyingReader(src io.ReadSeekCloser, key string, size int64) *verifyingReader {
+	return &verifyingReader{src: src, h: sha256.New(), want: key, size: size}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.src.Read(p)
+	if v.pos == v.hashed {
+		v.h.Write(p[:n])
+		v.hashed += int64(n)
+		if v.hashed == v.size && hex.EncodeToString(v.h.Sum(nil)) != v.want {
+			v.pos += int64(n)
+			return 0, errDigestMismatch
+		}
+	}
+	v.pos += int64(n)
+	return n, err
+}
+
+func (v *verifyingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := v.src.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	if pos == 0 {
+		v.h.Reset()
+		v.hashed = 0
+	}
+	v.pos = pos
+	return pos, nil
+}
+
+func (v *verifyingReader) Close() error {
+	return v.src.Close()
+}
+
+// rangedReader gives a remote object of known size a seekable reader by
+// requesting the content from the current offset on the first read after
+// each seek.
+type rangedReader struct {
+	size int64
+	pos  int64
+	body io.ReadCloser
+	open func(offset int64) (io.ReadCloser, error)
+}
+
+func (r *rangedReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.open(r.pos)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *rangedReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	if pos != r.pos && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *rangedReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// evidenceServices bundles what the evidence endpoints use besides the
+// repositories.
+type evidenceServices struct {
+	store   EvidenceStore
+	signer  *urlSigner
+	scanner VirusScanner
+	limits  uploadLimits
+}
+
+// newEvidenceServices configures evidence storage, download links, virus
+// scanning and upload limits from the upload settings.
+func newEvidenceServices(uploads config.Uploads) (evidenceServices, error) {
+	store, err := newEvidenceStore(uploads)
+	if err != nil {
+		return evidenceServices{}, err
+	}
+	signer, err := newURLSigner(uploads.URLSecret)
+	if err != nil {
+		return evidenceServices{}, err
+	}
+	return evidenceServices{store: store, signer: signer, scanner: newScanner(uploads.Scanner), limits: newUploadLimits(uploads)}, nil
+}
+
+// newEvidenceStore builds the store selected by uploads.Store. The local
+// store keeps files in uploads.Dir.
+func newEvidenceStore(uploads config.Uploads) (EvidenceStore, error) {
+	switch uploads.Store {
+	case config.StoreS3:
+		return NewS3EvidenceStore(S3Config(uploads.S3))
+	case config.StoreIPFS:
+		return NewIPFSEvidenceStore(uploads.IPFSAPIURL)
+	default:
+		return NewLocalEvidenceStore(uploads.Dir)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowStore serves stored files a few bytes at a time, like a distant
+// backend.
+type slowStore struct {
+	EvidenceStore
+}
+
+func (s slowStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	file, err := s.EvidenceStore.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &slowReader{ReadSeekCloser: file}, nil
+}
+
+type slowReader struct {
+	io.ReadSeekCloser
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(40 * time.Millisecond)
+	if len(p) > 4 {
+		p = p[:4]
+	}
+	return r.ReadSeekCloser.Read(p)
+}
+
+func TestEvidenceTransfersOutlastServerTimeouts(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	_, item := api.uploadEvidence(t, "dev", task.ID, "evidence that takes a while")
+
+	// Serve the same API with timeouts far shorter than the transfers
+	api.server.files = slowStore{api.server.files}
+	api.server.config.Timeouts.Transfer = time.Minute
+	srv := httptest.NewUnstartedServer(api.server.routes())
+	srv.Config.ReadTimeout = 150 * time.Millisecond
+	srv.Config.WriteTimeout = 150 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/evidence/"+item.ID+"/download", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken("dev"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "evidence that takes a while" {
+		t.Fatalf("download = %q, %v", body, err)
+	}
+
+	// An upload whose body arrives slowly
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		part, _ := form.CreateFormFile("file", "slow.txt")
+		for i := 0; i < 6; i++ {
+			time.Sleep(50 * time.Millisecond)
+			part.Write([]byte(strings.Repeat("x", 10)))
+		}
+		form.Close()
+		pw.Close()
+	}()
+	req, _ = http.NewRequest("POST", srv.URL+"/api/tasks/"+task.ID+"/evidence", pr)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+testToken("dev"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("slow upload: status %d", resp.StatusCode)
+	}
+}
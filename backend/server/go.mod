@@ -3,16 +3,12 @@ module server
 go 1.23.4
 
 require (
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
-	github.com/joho/godotenv v1.5.1
-	github.com/supabase-community/supabase-go v0.0.4
+	github.com/supabase-community/postgrest-go v0.0.11
+	imara-shared v0.0.0-00010101000000-000000000000
 )
 
-require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/supabase-community/functions-go v0.0.0-20220927045802-22373e6cb51d // indirect
-	github.com/supabase-community/gotrue-go v1.2.0 // indirect
-	github.com/supabase-community/postgrest-go v0.0.11 // indirect
-	github.com/supabase-community/storage-go v0.7.0 // indirect
-	github.com/tomnomnom/linkheader v0.0.0-20180905144013-02ca5825eb80 // indirect
-)
+require github.com/BurntSushi/toml v1.6.0 // indirect
+
+replace imara-shared => ../shared
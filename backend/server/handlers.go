@@ -0,0 +1,984 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"imara-shared/config"
+)
+
+// server holds the dependencies shared by the API handlers.
+type server struct {
+	config config.Config
+
+	timelines  TimelineRepository
+	milestones MilestoneRepository
+	tasks      TaskRepository
+	history    TaskHistoryRepository
+	reviews    ReviewRepository
+	members    MembershipRepository
+	evidence   EvidenceRepository
+	policies   UploadPolicyRepository
+	verifier   *JWTVerifier
+
+	files        EvidenceStore
+	signer       *urlSigner
+	scanner      VirusScanner
+	uploadLimits uploadLimits
+
+	events *EventBus
+
+	notifications     NotificationRepository
+	notificationPrefs NotificationPreferenceRepository
+	notifier          *Notifier
+
+	webhooks          WebhookRepository
+	webhookDeliveries WebhookDeliveryRepository
+	webhookDispatcher *WebhookDispatcher
+
+	audit AuditRepository
+}
+
+func newServer(cfg config.Config, repos Repositories, evidence evidenceServices, notifier *Notifier, dispatcher *WebhookDispatcher, verifier *JWTVerifier) *server {
+	s := &server{
+		config: cfg,
+
+		timelines:  repos.Timelines,
+		milestones: repos.Milestones,
+		tasks:      repos.Tasks,
+		history:    repos.History,
+		reviews:    repos.Reviews,
+		members:    repos.Members,
+		evidence:   repos.Evidence,
+		policies:   repos.Policies,
+		verifier:   verifier,
+
+		files:        evidence.store,
+		signer:       evidence.signer,
+		scanner:      evidence.scanner,
+		uploadLimits: evidence.limits,
+
+		events: NewEventBus(),
+
+		notifications:     repos.Notifications,
+		notificationPrefs: repos.NotificationPrefs,
+		notifier:          notifier,
+
+		webhooks:          repos.Webhooks,
+		webhookDeliveries: repos.WebhookDeliveries,
+		webhookDispatcher: dispatcher,
+
+		audit: repos.Audit,
+	}
+	s.events.Subscribe(notifier.Enqueue, notifiableEvents...)
+	s.events.Subscribe(dispatcher.Enqueue, webhookEvents...)
+	return s
+}
+
+// routes builds the API router.
+func (s *server) routes() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(requestIDMiddleware)
+
+	// Add CORS middleware for the configured origins
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); s.config.AllowOrigin(origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range, X-Request-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, Content-Range, Accept-Ranges, ETag, X-Request-ID")
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	// Signed download links stand in for an access token
+	r.HandleFunc("/api/evidence/{evidenceId}/download", s.handleSignedDownloadEvidence).
+		Methods("GET", "HEAD").
+		Queries("expires", "{expires}", "signature", "{signature}")
+
+	// The API description is public
+	r.HandleFunc("/api/openapi.json", handleOpenAPI).Methods("GET")
+	r.HandleFunc("/api/docs", handleAPIDocs).Methods("GET")
+
+	// Every other API route requires a Supabase access token
+	api := r.NewRoute().Subrouter()
+	api.Use(authMiddleware(s.verifier))
+	api.Use(s.auditMiddleware)
+	api.Use(validateRequests)
+
+	api.HandleFunc("/api/timeline", s.handleCreateTimeline).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/milestones", s.handleListMilestones).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/milestones", s.handleCreateMilestone).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/milestones/{milestoneId}", s.handleUpdateMilestone).Methods("PUT", "PATCH", "OPTIONS")
+	api.HandleFunc("/api/milestones/{milestoneId}", s.handleDeleteMilestone).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/timeline", s.handleGetTimeline).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/timeline/{id}", s.handleUpdateTimeline).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/phases", s.handleListPhases).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/phases", s.handleCreatePhase).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/phases/order", s.handleReorderPhases).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/api/phases/{phaseId}", s.handleDeletePhase).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/schedule/validate", s.handleValidateSchedule).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/milestones/{milestoneId}/tasks", s.handleMilestoneTasks).Methods("GET", "POST", "OPTIONS")
+	api.HandleFunc("/api/me/tasks", s.handleMyTasks).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/users/{userId}/tasks", s.handleUserTasks).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}", s.handleUpdateTask).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}", s.handleDeleteTask).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/reassign", s.handleReassignTask).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/move", s.handleMoveTask).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/status", s.handleTransitionTask).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/history", s.handleTaskHistory).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/review", s.handleReviewTask).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/reviews", s.handleListReviews).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/evidence", s.handleListEvidence).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/tasks/{taskId}/evidence", s.handleAddEvidence).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/evidence/{evidenceId}", s.handleDeleteEvidence).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/api/evidence/{evidenceId}/download", s.handleDownloadEvidence).Methods("GET", "HEAD", "OPTIONS")
+	api.HandleFunc("/api/evidence/{evidenceId}/download-url", s.handleEvidenceDownloadURL).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/upload-policy", s.handleGetUploadPolicy).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/upload-policy", s.handleUpdateUploadPolicy).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/audit", s.handleProjectAudit).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/webhooks", s.handleListWebhooks).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/projects/{projectId}/webhooks", s.handleCreateWebhook).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/webhooks/{webhookId}", s.handleUpdateWebhook).Methods("PUT", "PATCH", "OPTIONS")
+	api.HandleFunc("/api/webhooks/{webhookId}", s.handleDeleteWebhook).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/api/webhooks/{webhookId}/deliveries", s.handleListWebhookDeliveries).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/webhooks/{webhookId}/deliveries/{deliveryId}/replay", s.handleReplayWebhookDelivery).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/notifications", s.handleListNotifications).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/notifications/read-all", s.handleMarkAllNotificationsRead).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/notifications/{notificationId}/read", s.handleMarkNotificationRead).Methods("POST", "OPTIONS")
+	api.HandleFunc("/api/me/notification-preferences", s.handleGetNotificationPreferences).Methods("GET", "OPTIONS")
+	api.HandleFunc("/api/me/notification-preferences", s.handleUpdateNotificationPreferences).Methods("PUT", "OPTIONS")
+
+	r.NotFoundHandler = requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "No such endpoint", http.StatusNotFound)
+	}))
+	r.MethodNotAllowedHandler = requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	return r
+}
+
+// milestoneStatuses lists the values accepted for Milestone.Status.
+var milestoneStatuses = []string{"pending", "in_progress", "completed"}
+
+// Milestone deletion modes for the on_tasks query parameter.
+const (
+	onTasksBlock   = "block"
+	onTasksCascade = "cascade"
+	onTasksMove    = "move"
+)
+
+// contains reports whether v is in list.
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON encodes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus encodes v as the JSON response body with the given status.
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Timeline endpoint
+func (s *server) handleCreateTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var timeline Timeline
+	if err := json.NewDecoder(r.Body).Decode(&timeline); err != nil {
+		fmt.Printf("Error decoding timeline request: %v\n", err)
+		writeBodyError(w, err)
+		return
+	}
+
+	// Validate required fields
+	if timeline.ProjectID == "" {
+		writeError(w, "Project ID is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateDateRange(timeline.StartDate, timeline.EndDate); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := s.authorizeProject(w, r, timeline.ProjectID, leadOnly); !ok {
+		return
+	}
+
+	created, err := s.createPhase(r.Context(), timeline)
+	if err != nil {
+		fmt.Printf("Error inserting timeline: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, created)
+}
+
+// Get milestones endpoint
+func (s *server) handleListMilestones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	query, paged, err := parseListQuery(r.URL.Query(), milestoneListFilters)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := s.authorizeProject(w, r, projectId, anyMember); !ok {
+		return
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	pageSize := query.Limit
+	if paged {
+		query.Limit++
+	}
+
+	// Fetch milestones with their tasks
+	milestones, err := s.milestones.List(r.Context(), projectId, query)
+	if err != nil {
+		fmt.Printf("Error fetching milestones: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	for i := range milestones {
+		milestones[i] = withProgress(milestones[i])
+	}
+
+	if !paged {
+		writeJSON(w, milestones)
+		return
+	}
+	writeJSON(w, page(milestones, pageSize, query, milestoneSortKey(query.Sort)))
+}
+
+// Create milestone endpoint
+func (s *server) handleCreateMilestone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var milestoneReq CreateMilestoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&milestoneReq); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if milestoneReq.ProjectID == "" {
+		writeError(w, "Project ID is required", http.StatusBadRequest)
+		return
+	}
+	if milestoneReq.DueDate.IsZero() {
+		writeError(w, "Due date is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := s.authorizeProject(w, r, milestoneReq.ProjectID, leadOnly); !ok {
+		return
+	}
+	if milestoneReq.PhaseID != "" && !s.validatePhase(w, r, milestoneReq.ProjectID, milestoneReq.PhaseID) {
+		return
+	}
+
+	milestone, err := s.milestones.Create(r.Context(), Milestone{
+		ProjectID:   milestoneReq.ProjectID,
+		PhaseID:     milestoneReq.PhaseID,
+		Title:       milestoneReq.Title,
+		Description: milestoneReq.Description,
+		DueDate:     milestoneReq.DueDate,
+		Status:      "pending",
+		CreatedBy:   currentUserID(r),
+	})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	recordAudit(r.Context(), "milestone", milestone.ID, nil, milestone)
+
+	s.events.Publish(r.Context(), DomainEvent{
+		Type:        EventMilestoneCreated,
+		ProjectID:   milestone.ProjectID,
+		MilestoneID: milestone.ID,
+		ActorID:     milestone.CreatedBy,
+		Data: map[string]interface{}{
+			"title":    milestone.Title,
+			"phase_id": milestone.PhaseID,
+			"due_date": milestone.DueDate,
+		},
+	})
+
+	// Return the created milestone
+	writeJSON(w, withProgress(milestone))
+}
+
+// Update milestone endpoint. PUT replaces the plan fields (title,
+// description, due date); PATCH only touches the fields present.
+func (s *server) handleUpdateMilestone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	milestoneId := mux.Vars(r)["milestoneId"]
+
+	var req UpdateMilestoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	existing, _, ok := s.authorizeMilestone(w, r, milestoneId, leadOnly)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		if req.Title == nil || req.DueDate == nil {
+			writeError(w, "PUT requires title and due_date; use PATCH for partial updates", http.StatusBadRequest)
+			return
+		}
+		if req.Description == nil {
+			empty := ""
+			req.Description = &empty
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.Title != nil {
+		if *req.Title == "" {
+			writeError(w, "Title cannot be empty", http.StatusBadRequest)
+			return
+		}
+		updates["title"] = *req.Title
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.DueDate != nil {
+		if req.DueDate.IsZero() {
+			writeError(w, "Due date cannot be empty", http.StatusBadRequest)
+			return
+		}
+		updates["due_date"] = *req.DueDate
+	}
+	if req.Status != nil {
+		if !contains(milestoneStatuses, *req.Status) {
+			writeError(w, "Invalid status value", http.StatusBadRequest)
+			return
+		}
+		// Once a milestone has tasks its status follows them
+		if derived := derivedMilestoneStatus(existing.Tasks); derived != "" && derived != *req.Status {
+			writeError(w, fmt.Sprintf("Milestone status is derived from its tasks (currently %s)", derived), http.StatusConflict)
+			return
+		}
+		updates["status"] = *req.Status
+	}
+	if req.PhaseID != nil {
+		if *req.PhaseID != "" && !s.validatePhase(w, r, existing.ProjectID, *req.PhaseID) {
+			return
+		}
+		updates["phase_id"] = nullable(*req.PhaseID)
+	}
+	if len(updates) == 0 {
+		writeError(w, "No fields to update", http.StatusBadRequest)
+		return
+	}
+
+	milestone, err := s.milestones.Update(r.Context(), milestoneId, updates)
+	if err != nil {
+		fmt.Printf("Error updating milestone: %v\n", err)
+		writeRepoError(w, err, "Milestone not found")
+		return
+	}
+	recordAudit(r.Context(), "milestone", milestoneId, existing, milestone)
+
+	writeJSON(w, withProgress(milestone))
+}
+
+// Delete milestone endpoint. The on_tasks query parameter decides what
+// happens to the milestone's tasks: "block" (default) refuses to delete a
+// milestone that still has tasks, "cascade" deletes them, and "move"
+// transfers them to target_milestone_id in the same project.
+func (s *server) handleDeleteMilestone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	milestoneId := mux.Vars(r)["milestoneId"]
+	onTasks := r.URL.Query().Get("on_tasks")
+	if onTasks == "" {
+		onTasks = onTasksBlock
+	}
+
+	milestone, _, ok := s.authorizeMilestone(w, r, milestoneId, leadOnly)
+	if !ok {
+		return
+	}
+
+	// The checks below give clear errors for the common cases; the
+	// repository repeats them while it deletes, so tasks added or a target
+	// removed meanwhile cannot leave the deletion half done.
+	targetId := r.URL.Query().Get("target_milestone_id")
+	switch onTasks {
+	case onTasksBlock:
+		if len(milestone.Tasks) > 0 {
+			writeError(w, fmt.Sprintf("Milestone has %d tasks; use on_tasks=cascade or on_tasks=move", len(milestone.Tasks)), http.StatusConflict)
+			return
+		}
+	case onTasksCascade:
+	case onTasksMove:
+		if targetId == "" {
+			writeError(w, "target_milestone_id is required when on_tasks=move", http.StatusBadRequest)
+			return
+		}
+		if targetId == milestoneId {
+			writeError(w, "Cannot move tasks to the milestone being deleted", http.StatusBadRequest)
+			return
+		}
+		target, err := s.milestones.Get(r.Context(), targetId)
+		if err != nil {
+			fmt.Printf("Error fetching target milestone: %v\n", err)
+			writeRepoError(w, err, "Target milestone not found")
+			return
+		}
+		if target.ProjectID != milestone.ProjectID {
+			writeError(w, "Target milestone belongs to a different project", http.StatusBadRequest)
+			return
+		}
+	default:
+		writeError(w, "on_tasks must be one of block, cascade, move", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.milestones.Delete(r.Context(), milestoneId, onTasks, targetId); err != nil {
+		fmt.Printf("Error deleting milestone: %v\n", err)
+		writeRepoError(w, err, "Milestone not found")
+		return
+	}
+
+	for _, task := range milestone.Tasks {
+		switch onTasks {
+		case onTasksCascade:
+			recordAudit(r.Context(), "task", task.ID, task, nil)
+		case onTasksMove:
+			moved := task
+			moved.MilestoneID = targetId
+			recordAudit(r.Context(), "task", task.ID, task, moved)
+		}
+	}
+	if onTasks == onTasksMove {
+		s.syncMilestoneStatus(r.Context(), targetId)
+	}
+	recordAudit(r.Context(), "milestone", milestoneId, milestone, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Get project timeline endpoint
+func (s *server) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	if _, ok := s.authorizeProject(w, r, projectId, anyMember); !ok {
+		return
+	}
+
+	timelineData, err := s.timelines.ListByProject(r.Context(), projectId)
+	if err != nil {
+		fmt.Printf("Error fetching timeline: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	// Check if we got any data
+	if len(timelineData) == 0 {
+		writeJSON(w, map[string]interface{}{}) // Return empty object if no data
+		return
+	}
+
+	// The first phase keeps its place at the top level; phases lists all
+	writeJSON(w, TimelineResponse{Timeline: timelineData[0], Phases: timelineData})
+}
+
+// Update timeline endpoint
+func (s *server) handleUpdateTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	timelineId := mux.Vars(r)["id"]
+
+	var timeline Timeline
+	if err := json.NewDecoder(r.Body).Decode(&timeline); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if err := validateDateRange(timeline.StartDate, timeline.EndDate); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.timelines.Get(r.Context(), timelineId)
+	if err != nil {
+		fmt.Printf("Error fetching timeline: %v\n", err)
+		writeRepoError(w, err, "Timeline not found")
+		return
+	}
+	if _, ok := s.authorizeProject(w, r, existing.ProjectID, leadOnly); !ok {
+		return
+	}
+
+	// Clients written before phases had names don't send one
+	if timeline.Name == "" {
+		timeline.Name = existing.Name
+	}
+
+	updated, err := s.timelines.Update(r.Context(), timelineId, timeline)
+	if err != nil {
+		fmt.Printf("Error updating timeline: %v\n", err)
+		writeRepoError(w, err, "Timeline not found")
+		return
+	}
+	recordAudit(r.Context(), "timeline", timelineId, existing, updated)
+
+	writeJSON(w, updated)
+}
+
+// List and create tasks endpoint
+func (s *server) handleMilestoneTasks(w http.ResponseWriter, r *http.Request) {
+	milestoneId := mux.Vars(r)["milestoneId"]
+
+	if r.Method == http.MethodGet {
+		query, paged, err := parseListQuery(r.URL.Query(), taskListFilters)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Tasks submitted before the review workflow still say "completed"
+		if contains(query.Statuses, TaskSubmitted) {
+			query.Statuses = append(query.Statuses, taskLegacyCompleted)
+		}
+
+		if _, _, ok := s.authorizeMilestone(w, r, milestoneId, anyMember); !ok {
+			return
+		}
+
+		pageSize := query.Limit
+		if paged {
+			query.Limit++
+		}
+
+		tasks, err := s.tasks.List(r.Context(), milestoneId, query)
+		if err != nil {
+			fmt.Printf("Error fetching tasks: %v\n", err)
+			writeServerError(w, err)
+			return
+		}
+
+		if !paged {
+			writeJSON(w, tasks)
+			return
+		}
+		writeJSON(w, page(tasks, pageSize, query, taskSortKey(query.Sort)))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var taskReq CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&taskReq); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	taskReq.Title = strings.TrimSpace(taskReq.Title)
+	if taskReq.Title == "" {
+		writeError(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+	if taskReq.Effort < 0 {
+		writeError(w, "Effort must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	if taskReq.Effort == 0 {
+		taskReq.Effort = 1
+	}
+
+	_, access, ok := s.authorizeMilestone(w, r, milestoneId, leadOnly)
+	if !ok {
+		return
+	}
+	if taskReq.AssigneeID != "" && !s.checkAssignee(w, r, access.ProjectID, taskReq.AssigneeID) {
+		return
+	}
+
+	task, err := s.tasks.Create(r.Context(), Task{
+		MilestoneID: milestoneId,
+		Title:       taskReq.Title,
+		Description: taskReq.Description,
+		AssigneeID:  taskReq.AssigneeID,
+		DueDate:     taskReq.DueDate,
+		Status:      TaskPending,
+		Reviewed:    false,
+		CreatedBy:   currentUserID(r),
+		Effort:      taskReq.Effort,
+	})
+	if err != nil {
+		fmt.Printf("Error creating task: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	recordAudit(r.Context(), "task", task.ID, nil, task)
+
+	if _, err := s.history.Append(r.Context(), TaskTransition{
+		TaskID:    task.ID,
+		ToStatus:  task.Status,
+		ChangedBy: task.CreatedBy,
+		Note:      "Task created",
+	}); err != nil {
+		fmt.Printf("Error recording task transition: %v\n", err)
+	}
+
+	// A new task can reopen a completed milestone
+	s.syncMilestoneStatus(r.Context(), milestoneId)
+
+	if task.AssigneeID != "" {
+		s.publishTaskEvent(r.Context(), EventTaskAssigned, task, task.CreatedBy, nil)
+	}
+
+	writeJSON(w, task)
+}
+
+// Update task endpoint
+func (s *server) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		fmt.Printf("Error decoding task updates: %v\n", err)
+		writeBodyError(w, err)
+		return
+	}
+
+	// Assignment and milestone changes have dedicated endpoints so they can
+	// be recorded and validated.
+	if _, ok := updates["assignee_id"]; ok {
+		writeError(w, "Use POST /api/tasks/{taskId}/reassign to change the assignee", http.StatusBadRequest)
+		return
+	}
+	if _, ok := updates["milestone_id"]; ok {
+		writeError(w, "Use POST /api/tasks/{taskId}/move to change the milestone", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := updates["evidence"]; ok {
+		writeError(w, "Use POST /api/tasks/{taskId}/evidence to submit evidence", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := updates["reviewed"]; ok {
+		writeError(w, "reviewed is set by the review workflow", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := updates["overdue_since"]; ok {
+		writeError(w, "overdue_since is set by the overdue sweep", http.StatusBadRequest)
+		return
+	}
+
+	// Everything else is written as given, so only editable fields pass
+	for field, value := range updates {
+		switch field {
+		case "title", "description":
+			text, ok := value.(string)
+			if !ok {
+				writeError(w, fmt.Sprintf("%s must be a string", field), http.StatusBadRequest)
+				return
+			}
+			if field == "title" && text == "" {
+				writeError(w, "Title cannot be empty", http.StatusBadRequest)
+				return
+			}
+		case "status", "effort", "due_date":
+		default:
+			writeError(w, fmt.Sprintf("Field %q cannot be updated", field), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if rawEffort, ok := updates["effort"]; ok {
+		effort, ok := rawEffort.(float64)
+		if !ok || effort < 1 || effort != math.Trunc(effort) {
+			writeError(w, "Effort must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if rawDue, ok := updates["due_date"]; ok && rawDue != nil {
+		due, ok := rawDue.(string)
+		if !ok {
+			writeError(w, "due_date must be a string", http.StatusBadRequest)
+			return
+		}
+		var dueDate Date
+		if due != "" {
+			parsed, err := ParseDate(due)
+			if err != nil {
+				writeError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			dueDate = parsed
+		}
+		updates["due_date"] = dueDate
+	}
+
+	task, access, ok := s.authorizeTaskWork(w, r, taskId)
+	if !ok {
+		return
+	}
+
+	// The plan is the lead's: assignees may only describe and progress
+	// their work
+	if !access.allows(leadOnly) {
+		for _, field := range []string{"title", "effort", "due_date"} {
+			if _, ok := updates[field]; ok {
+				writeError(w, "Only the project lead can change a task's title, effort or due date", http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	// Status changes go through the task lifecycle
+	if rawStatus, ok := updates["status"]; ok {
+		status, ok := rawStatus.(string)
+		if !ok || !validTaskStatus(normalizeTaskStatus(status)) {
+			writeError(w, "Invalid status value", http.StatusBadRequest)
+			return
+		}
+		delete(updates, "status")
+
+		updated, err := s.manualTransition(r.Context(), task, status, currentUserID(r), "", updates)
+		if err != nil {
+			fmt.Printf("Error updating task: %v\n", err)
+			writeTransitionError(w, err)
+			return
+		}
+
+		writeJSON(w, updated)
+		return
+	}
+
+	updated, err := s.tasks.Update(r.Context(), task.ID, updates)
+	if err != nil {
+		fmt.Printf("Error updating task: %v\n", err)
+		writeRepoError(w, err, "Task not found")
+		return
+	}
+	recordAudit(r.Context(), "task", task.ID, task, updated)
+
+	writeJSON(w, updated)
+}
+
+// Delete task endpoint
+func (s *server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	task, _, ok := s.authorizeTask(w, r, taskId, leadOnly)
+	if !ok {
+		return
+	}
+
+	if err := s.tasks.Delete(r.Context(), taskId); err != nil {
+		fmt.Printf("Error deleting task: %v\n", err)
+		writeRepoError(w, err, "Task not found")
+		return
+	}
+	recordAudit(r.Context(), "task", taskId, task, nil)
+
+	s.syncMilestoneStatus(r.Context(), task.MilestoneID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reassign task endpoint. The outgoing assignee is kept in
+// previous_assignee_id.
+func (s *server) handleReassignTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	var req ReassignTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.AssigneeID == "" {
+		writeError(w, "Assignee ID is required", http.StatusBadRequest)
+		return
+	}
+
+	task, access, ok := s.authorizeTask(w, r, taskId, leadOnly)
+	if !ok {
+		return
+	}
+	if task.AssigneeID == req.AssigneeID {
+		writeError(w, "Task is already assigned to this user", http.StatusBadRequest)
+		return
+	}
+
+	if !s.checkAssignee(w, r, access.ProjectID, req.AssigneeID) {
+		return
+	}
+
+	var previous interface{}
+	if task.AssigneeID != "" {
+		previous = task.AssigneeID
+	}
+
+	updated, err := s.tasks.Update(r.Context(), taskId, map[string]interface{}{
+		"assignee_id":          req.AssigneeID,
+		"previous_assignee_id": previous,
+		"reassigned_at":        now(),
+	})
+	if err != nil {
+		fmt.Printf("Error reassigning task: %v\n", err)
+		writeRepoError(w, err, "Task not found")
+		return
+	}
+	recordAudit(r.Context(), "task", taskId, task, updated)
+
+	s.publishTaskEvent(r.Context(), EventTaskAssigned, updated, access.UserID, map[string]interface{}{
+		"previous_assignee_id": task.AssigneeID,
+	})
+
+	writeJSON(w, updated)
+}
+
+// checkAssignee reports whether userID may be assigned tasks in project,
+// writing the error response when not. Only people who can work on the
+// project can be assigned to it.
+func (s *server) checkAssignee(w http.ResponseWriter, r *http.Request, projectID, userID string) bool {
+	role, err := s.members.ProjectRole(r.Context(), projectID, userID)
+	if err != nil {
+		fmt.Printf("Error resolving assignee role: %v\n", err)
+		writeRepoError(w, err, "Project not found")
+		return false
+	}
+	if role != RoleLead && role != RoleContributor {
+		writeError(w, "Assignee must be the project lead or an approved contributor", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Move task endpoint. The target milestone must belong to the same
+// project as the task's current milestone.
+func (s *server) handleMoveTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	var req MoveTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.MilestoneID == "" {
+		writeError(w, "Milestone ID is required", http.StatusBadRequest)
+		return
+	}
+
+	task, access, ok := s.authorizeTask(w, r, taskId, leadOnly)
+	if !ok {
+		return
+	}
+	if task.MilestoneID == req.MilestoneID {
+		writeError(w, "Task is already in this milestone", http.StatusBadRequest)
+		return
+	}
+
+	target, err := s.milestones.Get(r.Context(), req.MilestoneID)
+	if err != nil {
+		fmt.Printf("Error fetching target milestone: %v\n", err)
+		writeRepoError(w, err, "Target milestone not found")
+		return
+	}
+	if target.ProjectID != access.ProjectID {
+		writeError(w, "Target milestone belongs to a different project", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.tasks.Update(r.Context(), taskId, map[string]interface{}{
+		"milestone_id": target.ID,
+	})
+	if err != nil {
+		fmt.Printf("Error moving task: %v\n", err)
+		writeRepoError(w, err, "Task not found")
+		return
+	}
+	recordAudit(r.Context(), "task", taskId, task, updated)
+
+	s.syncMilestoneStatus(r.Context(), task.MilestoneID)
+	s.syncMilestoneStatus(r.Context(), target.ID)
+
+	writeJSON(w, updated)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"imara-shared/config"
+)
+
+const testJWTSecret = "test-secret"
+
+// testAPI is the API router on an httptest server, backed by the memory
+// repositories.
+type testAPI struct {
+	*httptest.Server
+	server *server
+	repos  Repositories
+}
+
+// newTestAPI starts the API with the given "project:user:role" memberships.
+func newTestAPI(t *testing.T, memberships ...string) *testAPI {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Addr = ":0"
+	cfg.Storage = config.StorageMemory
+	cfg.Environment = config.Development
+	cfg.Uploads.Dir = t.TempDir()
+	cfg.Uploads.URLSecret = "test-url-secret"
+
+	repos := NewMemoryRepositories()
+	seedMemberships(repos.Members.(*MemoryMembershipRepository), memberships)
+	evidence, err := newEvidenceServices(cfg.Uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := NewNotifier(repos, map[string]NotificationChannel{})
+	dispatcher := NewWebhookDispatcher(repos, false)
+	s := newServer(cfg, repos, evidence, notifier, dispatcher, NewHS256Verifier(testJWTSecret).WithAudience("authenticated"))
+
+	api := &testAPI{Server: httptest.NewServer(s.routes()), server: s, repos: repos}
+	t.Cleanup(api.Close)
+	return api
+}
+
+// testToken signs an access token for user.
+func testToken(user string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	header := enc([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]interface{}{
+		"sub": user,
+		"aud": "authenticated",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	payload := enc(claims)
+	mac := hmac.New(sha256.New, []byte(testJWTSecret))
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + enc(mac.Sum(nil))
+}
+
+// do sends a request as user, or without a token when user is empty, and
+// decodes a JSON response into out when out is not nil.
+func (api *testAPI) do(t *testing.T, user, method, path string, body interface{}, out interface{}) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, api.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+testToken(user))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+// expectError checks the status and code of an error envelope.
+func (api *testAPI) expectError(t *testing.T, user, method, path string, body interface{}, status int, code string) {
+	t.Helper()
+	var envelope struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+	resp := api.do(t, user, method, path, body, &envelope)
+	if resp.StatusCode != status || envelope.Code != code {
+		t.Fatalf("%s %s = %d %q (%s), want %d %q", method, path, resp.StatusCode, envelope.Code, envelope.Message, status, code)
+	}
+	if envelope.RequestID == "" || envelope.RequestID != resp.Header.Get("X-Request-ID") {
+		t.Errorf("%s %s: request_id %q does not match header %q", method, path, envelope.RequestID, resp.Header.Get("X-Request-ID"))
+	}
+}
+
+// createMilestone creates a milestone in project as lead.
+func (api *testAPI) createMilestone(t *testing.T, lead, project, title string) Milestone {
+	t.Helper()
+	var milestone Milestone
+	resp := api.do(t, lead, "POST", "/api/milestones", map[string]interface{}{
+		"project_id": project,
+		"title":      title,
+		"due_date":   "2030-01-31",
+	}, &milestone)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		t.Fatalf("creating milestone: status %d", resp.StatusCode)
+	}
+	return milestone
+}
+
+// createTask creates a task in milestone as lead.
+func (api *testAPI) createTask(t *testing.T, lead, milestone string, body map[string]interface{}) Task {
+	t.Helper()
+	var task Task
+	resp := api.do(t, lead, "POST", "/api/milestones/"+milestone+"/tasks", body, &task)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		t.Fatalf("creating task: status %d", resp.StatusCode)
+	}
+	return task
+}
+
+func TestRequiresAccessToken(t *testing.T) {
+	api := newTestAPI(t)
+	api.expectError(t, "", "GET", "/api/me/tasks", nil, http.StatusUnauthorized, "unauthorized")
+
+	req, _ := http.NewRequest("GET", api.URL+"/api/me/tasks", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken("alice")+"x")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("tampered token: status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestUnknownEndpoint(t *testing.T) {
+	api := newTestAPI(t)
+	api.expectError(t, "", "GET", "/api/nope", nil, http.StatusNotFound, "not_found")
+}
+
+func TestCORSAllowsConfiguredOrigins(t *testing.T) {
+	api := newTestAPI(t)
+	for origin, allowed := range map[string]bool{
+		"http://localhost:3000":    true,
+		"https://evil.example.com": false,
+	} {
+		req, _ := http.NewRequest("OPTIONS", api.URL+"/api/milestones", nil)
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		got := resp.Header.Get("Access-Control-Allow-Origin")
+		if allowed && got != origin || !allowed && got != "" {
+			t.Errorf("origin %s: Access-Control-Allow-Origin = %q", origin, got)
+		}
+		if !strings.Contains(resp.Header.Get("Vary"), "Origin") {
+			t.Errorf("origin %s: missing Vary: Origin", origin)
+		}
+	}
+}
+
+func TestCreateTimelineValidation(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:viewer:viewer")
+
+	api.expectError(t, "lead", "POST", "/api/timeline", map[string]interface{}{"name": "Build"}, http.StatusBadRequest, "validation_failed")
+	api.expectError(t, "lead", "POST", "/api/timeline", map[string]interface{}{
+		"project_id": "p1",
+		"name":       "Build",
+		"start_date": "2030-02-01",
+		"end_date":   "2030-01-01",
+	}, http.StatusBadRequest, "validation_failed")
+	api.expectError(t, "viewer", "POST", "/api/timeline", map[string]interface{}{
+		"project_id": "p1",
+		"name":       "Build",
+		"start_date": "2030-01-01",
+		"end_date":   "2030-02-01",
+	}, http.StatusForbidden, "forbidden")
+
+	var timeline Timeline
+	resp := api.do(t, "lead", "POST", "/api/timeline", map[string]interface{}{
+		"project_id": "p1",
+		"name":       "Build",
+		"start_date": "2030-01-01",
+		"end_date":   "2030-02-01",
+	}, &timeline)
+	if resp.StatusCode >= 300 || timeline.ID == "" || timeline.ProjectID != "p1" {
+		t.Fatalf("creating timeline: status %d, %+v", resp.StatusCode, timeline)
+	}
+
+	var got TimelineResponse
+	if resp := api.do(t, "viewer", "GET", "/api/projects/p1/timeline", nil, &got); resp.StatusCode != http.StatusOK {
+		t.Fatalf("getting timeline: status %d", resp.StatusCode)
+	}
+	if got.ID != timeline.ID {
+		t.Errorf("timeline id = %q, want %q", got.ID, timeline.ID)
+	}
+}
+
+func TestCreateMilestoneRequiresDueDate(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead")
+
+	for _, due := range []interface{}{nil, "", "next week"} {
+		body := map[string]interface{}{"project_id": "p1", "title": "Alpha"}
+		if due != nil {
+			body["due_date"] = due
+		}
+		api.expectError(t, "lead", "POST", "/api/milestones", body, http.StatusBadRequest, "validation_failed")
+	}
+	if milestone := api.createMilestone(t, "lead", "p1", "Alpha"); milestone.DueDate.String() != "2030-01-31" {
+		t.Errorf("due date = %q", milestone.DueDate)
+	}
+}
+
+func TestMilestonesRequireMembership(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+
+	api.expectError(t, "dev", "POST", "/api/milestones", map[string]interface{}{
+		"project_id": "p1",
+		"title":      "Alpha",
+		"due_date":   "2030-01-31",
+	}, http.StatusForbidden, "forbidden")
+	api.expectError(t, "stranger", "GET", "/api/projects/p1/milestones", nil, http.StatusForbidden, "forbidden")
+
+	created := api.createMilestone(t, "lead", "p1", "Alpha")
+	if created.Status != "pending" || created.CreatedBy != "lead" {
+		t.Errorf("created milestone = %+v", created)
+	}
+
+	var milestones []Milestone
+	if resp := api.do(t, "dev", "GET", "/api/projects/p1/milestones", nil, &milestones); resp.StatusCode != http.StatusOK {
+		t.Fatalf("listing milestones: status %d", resp.StatusCode)
+	}
+	if len(milestones) != 1 || milestones[0].ID != created.ID {
+		t.Fatalf("milestones = %+v", milestones)
+	}
+
+	api.expectError(t, "lead", "DELETE", "/api/milestones/missing", nil, http.StatusNotFound, "not_found")
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{
+		"title":       "Write the spec",
+		"assignee_id": "dev",
+	})
+	if task.Status != TaskPending || task.Effort != 1 {
+		t.Fatalf("created task = %+v", task)
+	}
+
+	path := fmt.Sprintf("/api/tasks/%s/status", task.ID)
+	api.expectError(t, "dev", "POST", path, map[string]interface{}{"status": TaskApproved}, http.StatusConflict, "conflict")
+
+	for _, status := range []string{TaskInProgress, TaskSubmitted} {
+		var moved Task
+		if resp := api.do(t, "dev", "POST", path, map[string]interface{}{"status": status}, &moved); resp.StatusCode != http.StatusOK {
+			t.Fatalf("moving task to %s: status %d", status, resp.StatusCode)
+		}
+		if moved.Status != status {
+			t.Fatalf("task status = %q, want %q", moved.Status, status)
+		}
+	}
+
+	var history []TaskTransition
+	api.do(t, "lead", "GET", fmt.Sprintf("/api/tasks/%s/history", task.ID), nil, &history)
+	if len(history) != 3 {
+		t.Fatalf("history has %d entries, want 3: %+v", len(history), history)
+	}
+
+	var mine AssignedTasksResponse
+	api.do(t, "dev", "GET", "/api/me/tasks", nil, &mine)
+	if mine.Total != 1 || len(mine.Projects) != 1 || mine.Projects[0].ProjectID != "p1" {
+		t.Errorf("assigned tasks = %+v", mine)
+	}
+}
+
+func TestAssigneeCannotChangeTaskPlan(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	path := "/api/tasks/" + task.ID
+
+	for _, update := range []map[string]interface{}{
+		{"effort": 8},
+		{"due_date": nil},
+		{"due_date": "2031-01-01"},
+		{"title": "Easier spec"},
+		{"description": "Notes", "effort": 3},
+	} {
+		api.expectError(t, "dev", "PUT", path, update, http.StatusForbidden, "forbidden")
+	}
+
+	var updated Task
+	if resp := api.do(t, "dev", "PUT", path, map[string]interface{}{"description": "Notes", "status": TaskInProgress}, &updated); resp.StatusCode != http.StatusOK {
+		t.Fatalf("assignee update: status %d", resp.StatusCode)
+	}
+	if updated.Description != "Notes" || updated.Status != TaskInProgress || updated.Effort != 1 {
+		t.Errorf("updated task = %+v", updated)
+	}
+	if resp := api.do(t, "lead", "PUT", path, map[string]interface{}{"effort": 3, "title": "Full spec"}, &updated); resp.StatusCode != http.StatusOK {
+		t.Fatalf("lead update: status %d", resp.StatusCode)
+	}
+	if updated.Effort != 3 || updated.Title != "Full spec" {
+		t.Errorf("updated task = %+v", updated)
+	}
+}
+
+func TestCreateTaskChecksTitleAndAssignee(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor", "p1:watcher:viewer")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	path := "/api/milestones/" + milestone.ID + "/tasks"
+
+	api.expectError(t, "lead", "POST", path, map[string]interface{}{"title": "   "}, http.StatusBadRequest, "validation_failed")
+	api.expectError(t, "lead", "POST", path, map[string]interface{}{"title": "Spec", "assignee_id": "watcher"}, http.StatusBadRequest, "validation_failed")
+	api.expectError(t, "lead", "POST", path, map[string]interface{}{"title": "Spec", "assignee_id": "stranger"}, http.StatusBadRequest, "validation_failed")
+
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": " Spec ", "assignee_id": "dev"})
+	if task.Title != "Spec" || task.AssigneeID != "dev" {
+		t.Errorf("created task = %+v", task)
+	}
+}
+
+func TestDeleteMilestoneTaskModes(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p2:lead:lead")
+	from := api.createMilestone(t, "lead", "p1", "From")
+	to := api.createMilestone(t, "lead", "p1", "To")
+	other := api.createMilestone(t, "lead", "p2", "Elsewhere")
+	task := api.createTask(t, "lead", from.ID, map[string]interface{}{"title": "Spec"})
+
+	path := "/api/milestones/" + from.ID
+	api.expectError(t, "lead", "DELETE", path, nil, http.StatusConflict, "conflict")
+	api.expectError(t, "lead", "DELETE", path+"?on_tasks=move", nil, http.StatusBadRequest, "validation_failed")
+	api.expectError(t, "lead", "DELETE", path+"?on_tasks=move&target_milestone_id="+other.ID, nil, http.StatusBadRequest, "validation_failed")
+
+	if resp := api.do(t, "lead", "DELETE", path+"?on_tasks=move&target_milestone_id="+to.ID, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("moving tasks: status %d", resp.StatusCode)
+	}
+	moved, err := api.repos.Tasks.Get(context.Background(), task.ID)
+	if err != nil || moved.MilestoneID != to.ID {
+		t.Fatalf("task after move = %+v, %v", moved, err)
+	}
+
+	if resp := api.do(t, "lead", "DELETE", "/api/milestones/"+to.ID+"?on_tasks=cascade", nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("cascading: status %d", resp.StatusCode)
+	}
+	if _, err := api.repos.Tasks.Get(context.Background(), task.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("task after cascade: %v, want ErrNotFound", err)
+	}
+}
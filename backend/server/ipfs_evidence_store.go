@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ipfsEvidenceDir is the IPFS mutable filesystem directory holding evidence.
+const ipfsEvidenceDir = "/imara-evidence"
+
+// IPFSEvidenceStore keeps evidence on an IPFS node through its HTTP RPC
+// API. Files are written into the node's mutable filesystem under their
+// SHA-256, which keeps them pinned and lets the node report their CID.
+type IPFSEvidenceStore struct {
+	api    string
+	client *http.Client
+}
+
+// NewIPFSEvidenceStore returns a store talking to the node at apiURL
+// (default http://127.0.0.1:5001).
+func NewIPFSEvidenceStore(apiURL string) (*IPFSEvidenceStore, error) {
+	if apiURL == "" {
+		apiURL = "http://127.0.0.1:5001"
+	}
+	if _, err := url.Parse(apiURL); err != nil {
+		return nil, fmt.Errorf("invalid IPFS_API_URL %q", apiURL)
+	}
+	return &IPFSEvidenceStore{
+		api:    strings.TrimRight(apiURL, "/"),
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *IPFSEvidenceStore) Put(ctx context.Context, r io.Reader) (StoredObject, error) {
+	tmp, err := spool(r, os.TempDir())
+	if err != nil {
+		return StoredObject{}, err
+	}
+	defer tmp.cleanup()
+
+	obj := StoredObject{Key: tmp.key, Size: tmp.size}
+	if stat, err := s.stat(ctx, tmp.key); err == nil {
+		obj.Location = "ipfs://" + stat.Hash
+		return obj, nil
+	} else if err != ErrNotFound {
+		return StoredObject{}, err
+	}
+
+	body, contentType := multipartBody(tmp.File)
+	resp, err := s.call(ctx, "files/write", url.Values{
+		"arg":     {ipfsEvidenceDir + "/" + tmp.key},
+		"create":  {"true"},
+		"parents": {"true"},
+	}, body, contentType)
+	if err != nil {
+		return StoredObject{}, err
+	}
+	resp.Body.Close()
+
+	stat, err := s.stat(ctx, tmp.key)
+	if err != nil {
+		return StoredObject{}, err
+	}
+	obj.Location = "ipfs://" + stat.Hash
+	return obj, nil
+}
+
+func (s *IPFSEvidenceStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !validEvidenceKey(key) {
+		return nil, ErrNotFound
+	}
+	stat, err := s.stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &rangedReader{
+		size: stat.Size,
+		open: func(offset int64) (io.ReadCloser, error) {
+			resp, err := s.call(ctx, "files/read", url.Values{
+				"arg":    {ipfsEvidenceDir + "/" + key},
+				"offset": {strconv.FormatInt(offset, 10)},
+			}, nil, "")
+			if err != nil {
+				return nil, err
+			}
+			return resp.Body, nil
+		},
+	}
+	return newVerifyingReader(body, key, stat.Size), nil
+}
+
+func (s *IPFSEvidenceStore) Delete(ctx context.Context, key string) error {
+	if !validEvidenceKey(key) {
+		return ErrNotFound
+	}
+	resp, err := s.call(ctx, "files/rm", url.Values{"arg": {ipfsEvidenceDir + "/" + key}}, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ipfsStat is the part of a files/stat response the store uses.
+type ipfsStat struct {
+	Hash string `json:"Hash"`
+	Size int64  `json:"Size"`
+}
+
+// stat describes the evidence stored under key.
+func (s *IPFSEvidenceStore) stat(ctx context.Context, key string) (ipfsStat, error) {
+	resp, err := s.call(ctx, "files/stat", url.Values{"arg": {ipfsEvidenceDir + "/" + key}}, nil, "")
+	if err != nil {
+		return ipfsStat{}, err
+	}
+	defer resp.Body.Close()
+
+	var stat ipfsStat
+	if err := json.NewDecoder(resp.Body).Decode(&stat); err != nil {
+		return ipfsStat{}, fmt.Errorf("decoding IPFS stat: %w", err)
+	}
+	return stat, nil
+}
+
+// call invokes an RPC command. The node answers every failure with a 500
+// and a JSON message; a missing MFS path is reported as ErrNotFound.
+func (s *IPFSEvidenceStore) call(ctx context.Context, command string, args url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	endpoint := s.api + "/api/v0/" + command + "?" + args.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("IPFS %s: %w", command, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var failure struct {
+		Message string `json:"Message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+	if strings.Contains(failure.Message, "does not exist") {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("IPFS %s returned %s: %s", command, resp.Status, failure.Message)
+}
+
+// multipartBody streams r as the single file part the RPC API expects.
+func multipartBody(r io.Reader) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", "evidence")
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, mw.FormDataContentType()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeIPFS answers the MFS commands of the RPC API from memory the way a
+// Kubo node does, failing every error with a 500 and a JSON message.
+type fakeIPFS struct {
+	t      *testing.T
+	mu     sync.Mutex
+	files  map[string][]byte
+	writes int
+}
+
+func (f *fakeIPFS) fail(w http.ResponseWriter, message string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"Message": message, "Type": "error"})
+}
+
+func (f *fakeIPFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		f.t.Errorf("%s %s: the RPC API only accepts POST", r.Method, r.URL.Path)
+	}
+	query := r.URL.Query()
+	path := query.Get("arg")
+	if !strings.HasPrefix(path, ipfsEvidenceDir+"/") {
+		f.fail(w, "unexpected path "+path)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, exists := f.files[path]
+	switch r.URL.Path {
+	case "/api/v0/files/stat":
+		if !exists {
+			f.fail(w, "file does not exist")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Hash": "bafy" + path[len(path)-8:], "Size": len(data)})
+	case "/api/v0/files/write":
+		if query.Get("create") != "true" || query.Get("parents") != "true" {
+			f.fail(w, "file does not exist")
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			f.fail(w, err.Error())
+			return
+		}
+		f.files[path], _ = io.ReadAll(file)
+		f.writes++
+	case "/api/v0/files/read":
+		if !exists {
+			f.fail(w, "file does not exist")
+			return
+		}
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		w.Write(data[offset:])
+	case "/api/v0/files/rm":
+		if !exists {
+			f.fail(w, "file does not exist")
+			return
+		}
+		delete(f.files, path)
+	default:
+		f.fail(w, "unknown command "+r.URL.Path)
+	}
+}
+
+func TestIPFSEvidenceStore(t *testing.T) {
+	node := &fakeIPFS{t: t, files: map[string][]byte{}}
+	srv := httptest.NewServer(node)
+	defer srv.Close()
+
+	store, err := NewIPFSEvidenceStore(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	content := "hello evidence"
+	obj, err := store.Put(ctx, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Size != int64(len(content)) || obj.Location != "ipfs://bafy"+obj.Key[56:] {
+		t.Errorf("stored object = %+v", obj)
+	}
+	if string(node.files[ipfsEvidenceDir+"/"+obj.Key]) != content {
+		t.Errorf("node holds %q", node.files[ipfsEvidenceDir+"/"+obj.Key])
+	}
+	if _, err := store.Put(ctx, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if node.writes != 1 {
+		t.Errorf("wrote the same content %d times", node.writes)
+	}
+
+	body, err := store.Open(ctx, obj.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := body.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(body); err != nil || string(data) != "evidence" {
+		t.Errorf("ranged read %q, %v", data, err)
+	}
+	body.Seek(0, io.SeekStart)
+	if data, err := io.ReadAll(body); err != nil || string(data) != content {
+		t.Errorf("read %q, %v", data, err)
+	}
+	body.Close()
+
+	node.files[ipfsEvidenceDir+"/"+obj.Key] = []byte("hello tampered")
+	body, err = store.Open(ctx, obj.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(body); !errors.Is(err, errDigestMismatch) {
+		t.Errorf("reading tampered evidence: %v", err)
+	}
+	body.Close()
+
+	if err := store.Delete(ctx, obj.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(ctx, obj.Key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("open after delete: %v", err)
+	}
+	if err := store.Delete(ctx, obj.Key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete missing evidence: %v", err)
+	}
+}
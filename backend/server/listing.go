@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Listing page sizes.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// ListQuery filters, sorts and pages a milestone or task listing. Rows
+// are ordered by Sort and then by id, so the order is stable; rows with
+// no value for Sort come last in either direction.
+type ListQuery struct {
+	Statuses   []string
+	AssigneeID string
+	DueBefore  Date
+	DueAfter   Date
+	Reviewed   *bool
+	// Search matches title or description, case-insensitively.
+	Search string
+
+	Sort string
+	Desc bool
+	// After resumes the listing after the row the cursor points at.
+	After *listCursor
+	// Limit caps the number of rows returned; 0 returns them all.
+	Limit int
+}
+
+// listCursor is the position of the last row of a page. It is handed to
+// clients as an opaque string.
+type listCursor struct {
+	Sort  string  `json:"s"`
+	Desc  bool    `json:"d,omitempty"`
+	Value *string `json:"v"`
+	ID    string  `json:"id"`
+}
+
+func (c listCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c listCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// listFilters names the filters a listing accepts besides paging.
+type listFilters struct {
+	statuses []string
+	assignee bool
+	reviewed bool
+	sorts    []string
+}
+
+var (
+	milestoneListFilters = listFilters{
+		statuses: milestoneStatuses,
+		sorts:    []string{"created_at", "updated_at", "due_date", "title", "status"},
+	}
+	taskListFilters = listFilters{
+		statuses: []string{TaskPending, TaskInProgress, TaskSubmitted, TaskChangesRequested, TaskApproved, TaskClosed},
+		assignee: true,
+		reviewed: true,
+		sorts:    []string{"created_at", "updated_at", "due_date", "title", "status"},
+	}
+)
+
+// parseListQuery reads status (repeatable or comma separated), assignee,
+// due_before, due_after, reviewed, q, sort, order, limit and cursor.
+// paged is false when neither limit nor cursor was given, in which case
+// the whole listing is returned as before.
+func parseListQuery(values url.Values, allowed listFilters) (q ListQuery, paged bool, err error) {
+	for _, raw := range values["status"] {
+		for _, status := range strings.Split(raw, ",") {
+			if status = strings.TrimSpace(status); status == "" {
+				continue
+			}
+			if !contains(allowed.statuses, status) {
+				return q, false, fmt.Errorf("Invalid status filter %q", status)
+			}
+			q.Statuses = append(q.Statuses, status)
+		}
+	}
+
+	if assignee := values.Get("assignee"); assignee != "" {
+		if !allowed.assignee {
+			return q, false, fmt.Errorf("assignee filter is not supported here")
+		}
+		q.AssigneeID = assignee
+	}
+
+	for name, dst := range map[string]*Date{"due_before": &q.DueBefore, "due_after": &q.DueAfter} {
+		if raw := values.Get(name); raw != "" {
+			d, err := ParseDate(raw)
+			if err != nil {
+				return q, false, fmt.Errorf("%s: %v", name, err)
+			}
+			*dst = d
+		}
+	}
+
+	if raw := values.Get("reviewed"); raw != "" {
+		if !allowed.reviewed {
+			return q, false, fmt.Errorf("reviewed filter is not supported here")
+		}
+		reviewed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return q, false, fmt.Errorf("reviewed must be true or false")
+		}
+		q.Reviewed = &reviewed
+	}
+
+	q.Search = strings.TrimSpace(values.Get("q"))
+
+	q.Sort = "created_at"
+	if sortBy := values.Get("sort"); sortBy != "" {
+		if !contains(allowed.sorts, sortBy) {
+			return q, false, fmt.Errorf("sort must be one of %s", strings.Join(allowed.sorts, ", "))
+		}
+		q.Sort = sortBy
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, false, fmt.Errorf("order must be asc or desc")
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil {
+			return q, false, err
+		}
+		if cursor.Sort != q.Sort || cursor.Desc != q.Desc {
+			return q, false, fmt.Errorf("cursor belongs to a listing with a different sort order")
+		}
+		q.After = cursor
+		paged = true
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return q, false, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		q.Limit = limit
+		paged = true
+	} else if paged {
+		q.Limit = defaultPageSize
+	}
+
+	return q, paged, nil
+}
+
+// page trims rows fetched with a limit one above pageSize and builds the
+// cursor for the next page, if there is one.
+func page[T any](rows []T, pageSize int, q ListQuery, key func(T) (*string, string)) Page[T] {
+	if len(rows) <= pageSize {
+		return Page[T]{Items: rows}
+	}
+	rows = rows[:pageSize]
+	value, id := key(rows[len(rows)-1])
+	next := listCursor{Sort: q.Sort, Desc: q.Desc, Value: value, ID: id}
+	return Page[T]{Items: rows, NextCursor: next.encode()}
+}
+
+// milestoneSortKey returns a milestone's value for the sort column.
+func milestoneSortKey(sortBy string) func(Milestone) (*string, string) {
+	return func(m Milestone) (*string, string) {
+		switch sortBy {
+		case "updated_at":
+			return &m.UpdatedAt, m.ID
+		case "due_date":
+			return dateKey(m.DueDate), m.ID
+		case "title":
+			return &m.Title, m.ID
+		case "status":
+			return &m.Status, m.ID
+		default:
+			return &m.CreatedAt, m.ID
+		}
+	}
+}
+
+// taskSortKey returns a task's value for the sort column.
+func taskSortKey(sortBy string) func(Task) (*string, string) {
+	return func(t Task) (*string, string) {
+		switch sortBy {
+		case "updated_at":
+			return &t.UpdatedAt, t.ID
+		case "due_date":
+			return dateKey(t.DueDate), t.ID
+		case "title":
+			return &t.Title, t.ID
+		case "status":
+			return &t.Status, t.ID
+		default:
+			return &t.CreatedAt, t.ID
+		}
+	}
+}
+
+func dateKey(d Date) *string {
+	if d.IsZero() {
+		return nil
+	}
+	s := d.String()
+	return &s
+}
+
+// compareValues orders two sort values, nil last, comparing due dates by
+// the instant they start.
+func (q ListQuery) compareValues(a, b *string) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	var c int
+	if q.Sort == "due_date" {
+		da, _ := ParseDate(*a)
+		db, _ := ParseDate(*b)
+		c = da.start().Compare(db.start())
+	} else {
+		c = strings.Compare(*a, *b)
+	}
+	if q.Desc {
+		c = -c
+	}
+	return c
+}
+
+// compareRows orders two rows by sort value and then by id.
+func (q ListQuery) compareRows(av *string, aid string, bv *string, bid string) int {
+	if c := q.compareValues(av, bv); c != 0 {
+		return c
+	}
+	c := strings.Compare(aid, bid)
+	if q.Desc {
+		c = -c
+	}
+	return c
+}
+
+// sortAndPage applies the query's order, cursor and limit to rows that
+// already passed its filters. It is how the memory repositories page.
+func sortAndPage[T any](q ListQuery, rows []T, key func(T) (*string, string)) []T {
+	sort.SliceStable(rows, func(i, j int) bool {
+		av, aid := key(rows[i])
+		bv, bid := key(rows[j])
+		return q.compareRows(av, aid, bv, bid) < 0
+	})
+	if q.After != nil {
+		start := len(rows)
+		for i, row := range rows {
+			v, id := key(row)
+			if q.compareRows(v, id, q.After.Value, q.After.ID) > 0 {
+				start = i
+				break
+			}
+		}
+		rows = rows[start:]
+	}
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+	return rows
+}
+
+// matchesDue applies the due date filters. Rows without a due date never
+// match a due date filter, as in SQL.
+func (q ListQuery) matchesDue(due Date) bool {
+	if q.DueBefore.IsZero() && q.DueAfter.IsZero() {
+		return true
+	}
+	if due.IsZero() {
+		return false
+	}
+	if !q.DueBefore.IsZero() && !due.start().Before(q.DueBefore.start()) {
+		return false
+	}
+	if !q.DueAfter.IsZero() && !due.start().After(q.DueAfter.start()) {
+		return false
+	}
+	return true
+}
+
+func (q ListQuery) matchesText(title, description string) bool {
+	if q.Search == "" {
+		return true
+	}
+	search := strings.ToLower(q.Search)
+	return strings.Contains(strings.ToLower(title), search) ||
+		strings.Contains(strings.ToLower(description), search)
+}
+
+func (q ListQuery) matchMilestone(m Milestone) bool {
+	return (len(q.Statuses) == 0 || contains(q.Statuses, m.Status)) &&
+		q.matchesDue(m.DueDate) &&
+		q.matchesText(m.Title, m.Description)
+}
+
+func (q ListQuery) matchTask(t Task) bool {
+	return (len(q.Statuses) == 0 || contains(q.Statuses, t.Status)) &&
+		(q.AssigneeID == "" || t.AssigneeID == q.AssigneeID) &&
+		(q.Reviewed == nil || t.Reviewed == *q.Reviewed) &&
+		q.matchesDue(t.DueDate) &&
+		q.matchesText(t.Title, t.Description)
+}
+
+// pgValue quotes a value for use inside a PostgREST logic tree.
+func pgValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	return `"` + v + `"`
+}
+
+// likePattern matches term anywhere in a value with the like and ilike
+// operators. The term's LIKE wildcards and escapes are escaped so they
+// match themselves. PostgREST turns every * into %, so a * in the term
+// matches any one character instead.
+func likePattern(term string) string {
+	term = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `_`).Replace(term)
+	return "*" + term + "*"
+}
+
+// apply adds the query's filters, order, cursor and limit to a PostgREST
+// request. The client keys filters by column, so every condition goes
+// into a single and=(...) tree.
+func (q ListQuery) apply(f *postgrest.FilterBuilder) *postgrest.FilterBuilder {
+	var conds []string
+	if len(q.Statuses) > 0 {
+		quoted := make([]string, len(q.Statuses))
+		for i, s := range q.Statuses {
+			quoted[i] = pgValue(s)
+		}
+		conds = append(conds, fmt.Sprintf("status.in.(%s)", strings.Join(quoted, ",")))
+	}
+	if q.AssigneeID != "" {
+		conds = append(conds, "assignee_id.eq."+pgValue(q.AssigneeID))
+	}
+	if q.Reviewed != nil {
+		conds = append(conds, fmt.Sprintf("reviewed.is.%t", *q.Reviewed))
+	}
+	if !q.DueBefore.IsZero() {
+		conds = append(conds, "due_date.lt."+pgValue(q.DueBefore.String()))
+	}
+	if !q.DueAfter.IsZero() {
+		conds = append(conds, "due_date.gt."+pgValue(q.DueAfter.String()))
+	}
+	if q.Search != "" {
+		pattern := pgValue(likePattern(q.Search))
+		conds = append(conds, fmt.Sprintf("or(title.ilike.%s,description.ilike.%s)", pattern, pattern))
+	}
+	if q.After != nil {
+		conds = append(conds, q.afterCondition())
+	}
+	if len(conds) > 0 {
+		f = f.And(strings.Join(conds, ","), "")
+	}
+
+	opts := &postgrest.OrderOpts{Ascending: !q.Desc}
+	f = f.Order(q.Sort, opts).Order("id", opts)
+	if q.Limit > 0 {
+		f = f.Limit(q.Limit, "")
+	}
+	return f
+}
+
+// afterCondition selects the rows that follow the cursor in sort order,
+// with nulls last.
+func (q ListQuery) afterCondition() string {
+	op := "gt"
+	if q.Desc {
+		op = "lt"
+	}
+	id := pgValue(q.After.ID)
+	if q.After.Value == nil {
+		return fmt.Sprintf("and(%s.is.null,id.%s.%s)", q.Sort, op, id)
+	}
+	value := pgValue(*q.After.Value)
+	return fmt.Sprintf("or(%s.%s.%s,and(%s.eq.%s,id.%s.%s),%s.is.null)",
+		q.Sort, op, value, q.Sort, value, op, id, q.Sort)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseListQuery(t *testing.T) {
+	q, paged, err := parseListQuery(url.Values{
+		"status":     {"pending,in_progress", "approved"},
+		"assignee":   {"dev"},
+		"due_before": {"2030-02-01"},
+		"reviewed":   {"false"},
+		"q":          {"  spec "},
+		"sort":       {"due_date"},
+		"order":      {"desc"},
+		"limit":      {"10"},
+	}, taskListFilters)
+	if err != nil || !paged {
+		t.Fatalf("parseListQuery: %v, paged %v", err, paged)
+	}
+	if !reflect.DeepEqual(q.Statuses, []string{TaskPending, TaskInProgress, TaskApproved}) ||
+		q.AssigneeID != "dev" || q.DueBefore.String() != "2030-02-01" || q.Reviewed == nil || *q.Reviewed ||
+		q.Search != "spec" || q.Sort != "due_date" || !q.Desc || q.Limit != 10 {
+		t.Errorf("query = %+v", q)
+	}
+
+	if _, paged, _ := parseListQuery(url.Values{}, taskListFilters); paged {
+		t.Error("a listing without limit or cursor is paged")
+	}
+	for name, values := range map[string]url.Values{
+		"status":            {"status": {"done"}},
+		"milestone reviews": {"reviewed": {"true"}},
+		"sort":              {"sort": {"effort"}},
+		"order":             {"order": {"up"}},
+		"limit":             {"limit": {"201"}},
+		"zero limit":        {"limit": {"0"}},
+		"due date":          {"due_after": {"tomorrow"}},
+		"cursor":            {"cursor": {"not a cursor"}},
+	} {
+		if _, _, err := parseListQuery(values, milestoneListFilters); err == nil {
+			t.Errorf("%s: accepted %v", name, values)
+		}
+	}
+}
+
+func TestListCursorRoundTrip(t *testing.T) {
+	due := "2030-01-31"
+	cursor := listCursor{Sort: "due_date", Desc: true, Value: &due, ID: "t2"}.encode()
+
+	q, paged, err := parseListQuery(url.Values{"sort": {"due_date"}, "order": {"desc"}, "cursor": {cursor}}, taskListFilters)
+	if err != nil || !paged || q.Limit != defaultPageSize {
+		t.Fatalf("parseListQuery: %v, paged %v, limit %d", err, paged, q.Limit)
+	}
+	if q.After == nil || *q.After.Value != due || q.After.ID != "t2" {
+		t.Errorf("cursor = %+v", q.After)
+	}
+
+	for _, values := range []url.Values{
+		{"sort": {"title"}, "order": {"desc"}},
+		{"sort": {"due_date"}},
+	} {
+		values.Set("cursor", cursor)
+		if _, _, err := parseListQuery(values, taskListFilters); err == nil {
+			t.Errorf("cursor accepted for %v", values)
+		}
+	}
+}
+
+// pageThrough lists tasks pageSize at a time the way the repositories do,
+// fetching one row more than a page, and returns the ids in order.
+func pageThrough(t *testing.T, tasks []Task, sortBy string, desc bool, pageSize int) []string {
+	t.Helper()
+	var ids []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(tasks) {
+			t.Fatal("paging does not end")
+		}
+		values := url.Values{"sort": {sortBy}}
+		if desc {
+			values.Set("order", "desc")
+		}
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		}
+		q, _, err := parseListQuery(values, taskListFilters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.Limit = pageSize + 1
+		rows := sortAndPage(q, append([]Task(nil), tasks...), taskSortKey(q.Sort))
+		p := page(rows, pageSize, q, taskSortKey(q.Sort))
+		for _, task := range p.Items {
+			ids = append(ids, task.ID)
+		}
+		if p.NextCursor == "" {
+			return ids
+		}
+		cursor = p.NextCursor
+	}
+}
+
+func TestSortAndPageNullsLast(t *testing.T) {
+	date := func(s string) Date {
+		d, _ := ParseDate(s)
+		return d
+	}
+	tasks := []Task{
+		{ID: "e"},
+		{ID: "c", DueDate: date("2030-01-02")},
+		{ID: "b", DueDate: date("2030-01-01")},
+		{ID: "d"},
+		{ID: "a", DueDate: date("2030-01-01")},
+	}
+	for _, pageSize := range []int{1, 2, 10} {
+		if got, want := pageThrough(t, tasks, "due_date", false, pageSize), []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("ascending by %d: %v, want %v", pageSize, got, want)
+		}
+		if got, want := pageThrough(t, tasks, "due_date", true, pageSize), []string{"c", "b", "a", "e", "d"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("descending by %d: %v, want %v", pageSize, got, want)
+		}
+	}
+
+	// Equal titles fall back to the id
+	same := []Task{{ID: "3", Title: "Spec"}, {ID: "1", Title: "Spec"}, {ID: "2", Title: "Spec"}}
+	if got := pageThrough(t, same, "title", false, 2); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("ties: %v", got)
+	}
+}
+
+func TestListQueryPostgRESTFilter(t *testing.T) {
+	var query url.Values
+	postgrest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte("[]"))
+	}))
+	defer postgrest.Close()
+	repo := &supabaseTaskRepository{db: newSupabaseDB(postgrest.URL, "service")}
+
+	reviewed := true
+	before, _ := ParseDate("2030-02-01")
+	after, _ := ParseDate("2030-01-01")
+	due := "2030-01-15"
+	q := ListQuery{
+		Statuses:   []string{TaskPending, TaskInProgress},
+		AssigneeID: `dev"1`,
+		Reviewed:   &reviewed,
+		DueBefore:  before,
+		DueAfter:   after,
+		Search:     `100%_a\b`,
+		Sort:       "due_date",
+		After:      &listCursor{Sort: "due_date", Value: &due, ID: "t1"},
+		Limit:      3,
+	}
+	if _, err := repo.List(context.Background(), "m1", q); err != nil {
+		t.Fatal(err)
+	}
+	want := `(status.in.("pending","in_progress"),assignee_id.eq."dev\"1",reviewed.is.true,` +
+		`due_date.lt."2030-02-01",due_date.gt."2030-01-01",` +
+		`or(title.ilike."*100\\%\\_a\\\\b*",description.ilike."*100\\%\\_a\\\\b*"),` +
+		`or(due_date.gt."2030-01-15",and(due_date.eq."2030-01-15",id.gt."t1"),due_date.is.null))`
+	if got := query.Get("and"); got != want {
+		t.Errorf("and = %s\nwant %s", got, want)
+	}
+	if got := query.Get("order"); got != "due_date.asc.nullslast,id.asc.nullslast" {
+		t.Errorf("order = %s", got)
+	}
+	if got := query.Get("limit"); got != "3" {
+		t.Errorf("limit = %s", got)
+	}
+
+	// After a row without a value only later ids among the nulls remain
+	q = ListQuery{Sort: "due_date", Desc: true, After: &listCursor{Sort: "due_date", Desc: true, ID: "t1"}}
+	if got, want := q.afterCondition(), `and(due_date.is.null,id.lt."t1")`; got != want {
+		t.Errorf("after a null = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalEvidenceStore keeps evidence on the local filesystem under
+// root/<first two hex digits>/<sha256>.
+type LocalEvidenceStore struct {
+	root string
+}
+
+// NewLocalEvidenceStore returns a store rooted at dir, creating it if needed.
+func NewLocalEvidenceStore(dir string) (*LocalEvidenceStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating evidence directory: %w", err)
+	}
+	return &LocalEvidenceStore{root: dir}, nil
+}
+
+func (s *LocalEvidenceStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key)
+}
+
+func (s *LocalEvidenceStore) Put(ctx context.Context, r io.Reader) (StoredObject, error) {
+	tmp, err := spool(r, s.root)
+	if err != nil {
+		return StoredObject{}, err
+	}
+	defer tmp.cleanup()
+
+	// Renaming over an existing copy also repairs one that was altered.
+	obj := StoredObject{Key: tmp.key, Size: tmp.size, Location: s.path(tmp.key)}
+	if err := os.MkdirAll(filepath.Dir(obj.Location), 0755); err != nil {
+		return StoredObject{}, fmt.Errorf("creating evidence directory: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return StoredObject{}, err
+	}
+	if err := os.Rename(tmp.Name(), obj.Location); err != nil {
+		return StoredObject{}, fmt.Errorf("storing evidence: %w", err)
+	}
+	// Stored evidence is never modified in place.
+	os.Chmod(obj.Location, 0444)
+	return obj, nil
+}
+
+func (s *LocalEvidenceStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !validEvidenceKey(key) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return newVerifyingReader(f, key, info.Size()), nil
+}
+
+func (s *LocalEvidenceStore) Delete(ctx context.Context, key string) error {
+	if !validEvidenceKey(key) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
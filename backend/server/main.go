@@ -1,23 +1,27 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 
-	"github.com/gorilla/mux"
-	// "github.com/joho/godotenv"
-	"github.com/supabase-community/supabase-go"
+	"imara-shared/config"
 )
 
+// Timeline is one phase of a project's timeline (discovery, build,
+// launch...). Phases are ordered by Position.
 type Timeline struct {
 	ID          string `json:"id"`
 	ProjectID   string `json:"project_id"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date"`
+	Name        string `json:"name"`
+	Position    int    `json:"position"`
+	StartDate   Date   `json:"start_date"`
+	EndDate     Date   `json:"end_date"`
 	Description string `json:"description"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
@@ -26,22 +30,63 @@ type Timeline struct {
 type Milestone struct {
 	ID          string `json:"id"`
 	ProjectID   string `json:"project_id"`
+	PhaseID     string `json:"phase_id,omitempty"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
-	DueDate     string `json:"due_date"`
+	DueDate     Date   `json:"due_date"`
 	Status      string `json:"status"`
 	CreatedBy   string `json:"created_by"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 	Tasks       []Task `json:"milestone_tasks"`
+
+	// OverdueSince is set by the overdue sweep while the milestone is past
+	// its due date and not completed.
+	OverdueSince string `json:"overdue_since,omitempty"`
+
+	// Progress is derived from Tasks when the milestone is returned.
+	Progress *MilestoneProgress `json:"progress,omitempty"`
+}
+
+// MilestoneProgress is how much of a milestone's work is done, weighted
+// by task effort. Rejected tasks are left out.
+type MilestoneProgress struct {
+	Percent     float64 `json:"percent"`
+	DoneTasks   int     `json:"done_tasks"`
+	TotalTasks  int     `json:"total_tasks"`
+	DoneEffort  int     `json:"done_effort"`
+	TotalEffort int     `json:"total_effort"`
 }
 
 type CreateMilestoneRequest struct {
 	ProjectID   string `json:"project_id"`
+	PhaseID     string `json:"phase_id"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
-	DueDate     string `json:"due_date"`
-	CreatedBy   string `json:"created_by"`
+	DueDate     Date   `json:"due_date"`
+}
+
+// TimelineResponse is the project timeline: the first phase's fields at
+// the top level, as the endpoint has always returned, plus every phase.
+type TimelineResponse struct {
+	Timeline
+	Phases []Timeline `json:"phases"`
+}
+
+// ReorderPhasesRequest lists every phase of a project in its new order.
+type ReorderPhasesRequest struct {
+	PhaseIDs []string `json:"phase_ids"`
+}
+
+// UpdateMilestoneRequest carries the editable milestone fields. Nil fields
+// are left unchanged by PATCH.
+type UpdateMilestoneRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	DueDate     *Date   `json:"due_date"`
+	Status      *string `json:"status"`
+	// PhaseID assigns the milestone to a timeline phase; "" unassigns it.
+	PhaseID *string `json:"phase_id"`
 }
 
 type Task struct {
@@ -50,12 +95,26 @@ type Task struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	AssigneeID  string `json:"assignee_id"`
-	DueDate     string `json:"due_date"`
+	DueDate     Date   `json:"due_date"`
 	Status      string `json:"status"`
 	Reviewed    bool   `json:"reviewed"`
 	CreatedBy   string `json:"created_by"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
+
+	PreviousAssigneeID string `json:"previous_assignee_id,omitempty"`
+	ReassignedAt       string `json:"reassigned_at,omitempty"`
+
+	// SubmissionRound counts how many times the task has been submitted
+	// for review.
+	SubmissionRound int `json:"submission_round"`
+
+	// Effort weights the task in its milestone's progress.
+	Effort int `json:"effort"`
+
+	// OverdueSince is set by the overdue sweep while the task is past its
+	// due date and still open.
+	OverdueSince string `json:"overdue_since,omitempty"`
 }
 
 type CreateTaskRequest struct {
@@ -63,534 +122,426 @@ type CreateTaskRequest struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	AssigneeID  string `json:"assignee_id"`
-	DueDate     string `json:"due_date"`
-	CreatedBy   string `json:"created_by"`
+	DueDate     Date   `json:"due_date"`
+	// Effort defaults to 1.
+	Effort int `json:"effort"`
 }
 
 func main() {
-	// Replace with your Supabase project URL and API key
-	// err := godotenv.Load()
-	// if err != nil {
-	// 	fmt.Println("Error loading .env file")
-	// 	return
-	// }
-	API_URL := os.Getenv("SUPABASE_URL")
-	API_KEY := os.Getenv("SUPABASE_PUBLIC_KEY")
-	if API_URL == "" || API_KEY == "" {
-		fmt.Println("Please set SUPABASE_URL and SUPABASE_KEY in your .env file")
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrHelp) {
 		return
 	}
-	// Initialize the Supabase client
-	client, err := supabase.NewClient(API_URL, API_KEY, &supabase.ClientOptions{})
 	if err != nil {
-		fmt.Println("cannot initialize client", err)
-		return
+		fmt.Println("invalid configuration:", err)
+		os.Exit(2)
 	}
 
-	// // Example: Fetch data from a table
-	// _, response, err := client.From("users").Select("*", "", false).Execute()
-	// if err != nil {
-	// 	fmt.Println("Error fetching data:", err)
-	// 	return
-	// }
-
-	// fmt.Println("Response:", response)
-
-	// convert the  byte to character?
-
-	r := mux.NewRouter()
-
-	// Add CORS middleware
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "https://www.imarahub.xyz")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
+	// The memory storage backend runs the API without a Supabase project.
+	var repos Repositories
+	if cfg.Storage == config.StorageMemory {
+		fmt.Println("Using in-memory storage; data is lost on restart")
+		repos = NewMemoryRepositories()
+		seedMemberships(repos.Members.(*MemoryMembershipRepository), cfg.MemoryMemberships)
+		if cfg.Uploads.URLSecret == "" {
+			if cfg.Uploads.URLSecret, err = randomURLSecret(); err != nil {
+				fmt.Println("cannot configure evidence uploads:", err)
 				return
 			}
-			next.ServeHTTP(w, r)
-		})
-	})
-
-	// Timeline endpoint
-	r.HandleFunc("/api/timeline", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var timeline Timeline
-		if err := json.NewDecoder(r.Body).Decode(&timeline); err != nil {
-			fmt.Printf("Error decoding timeline request: %v\n", err)
-			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
-			return
-		}
-
-		// Log the received timeline data
-		fmt.Printf("Received timeline data: %+v\n", timeline)
-
-		// Validate required fields
-		if timeline.ProjectID == "" {
-			http.Error(w, "Project ID is required", http.StatusBadRequest)
-			return
-		}
-		if timeline.StartDate == "" {
-			http.Error(w, "Start date is required", http.StatusBadRequest)
-			return
-		}
-		if timeline.EndDate == "" {
-			http.Error(w, "End date is required", http.StatusBadRequest)
-			return
-		}
-
-		// Insert into Supabase
-		timelineData := map[string]interface{}{
-			"project_id":  timeline.ProjectID,
-			"start_date":  timeline.StartDate,
-			"end_date":    timeline.EndDate,
-			"description": timeline.Description,
-		}
-
-		_, response, err := client.From("project_timelines").Insert(timelineData, false, "", "", "").Execute()
-		if err != nil {
-			fmt.Printf("Error inserting timeline into Supabase: %v\n", err)
-			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	}).Methods("POST", "OPTIONS")
-
-	// Get milestones endpoint
-	r.HandleFunc("/api/projects/{projectId}/milestones", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		vars := mux.Vars(r)
-		projectId := vars["projectId"]
-
-		// Fetch milestones with their tasks from Supabase using a join
-		data, _, err := client.From("milestones").
-			Select(`
-				*,
-				milestone_tasks (
-					id,
-					title,
-					description,
-					assignee_id,
-					due_date,
-					status,
-					reviewed,
-					created_by,
-					created_at,
-					updated_at
-				)
-			`, "", false).
-			Eq("project_id", projectId).
-			Execute()
-
-		if err != nil {
-			fmt.Printf("Error fetching milestones: %v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Parse the milestones data with their tasks
-		var milestones []Milestone
-		if err := json.Unmarshal(data, &milestones); err != nil {
-			fmt.Printf("Error unmarshaling milestones: %v\n", err)
-			http.Error(w, "Error processing milestones data", http.StatusInternalServerError)
-			return
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(milestones)
-	}).Methods("GET", "OPTIONS")
-
-	// Create milestone endpoint
-	r.HandleFunc("/api/milestones", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var milestoneReq CreateMilestoneRequest
-		if err := json.NewDecoder(r.Body).Decode(&milestoneReq); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// Create milestone in Supabase
-		milestone := map[string]interface{}{
-			"project_id":  milestoneReq.ProjectID,
-			"title":       milestoneReq.Title,
-			"description": milestoneReq.Description,
-			"due_date":    milestoneReq.DueDate,
-			"status":      "pending",
-			"created_by":  milestoneReq.CreatedBy,
-		}
-
-		_, _, err := client.From("milestones").Insert(milestone, false, "", "", "").Execute()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Return the created milestone
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(milestone)
-	}).Methods("POST", "OPTIONS")
-
-	// Get project timeline endpoint
-	r.HandleFunc("/api/projects/{projectId}/timeline", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		vars := mux.Vars(r)
-		projectId := vars["projectId"]
-
-		// Fetch timeline from Supabase
-		data, _, err := client.From("project_timelines").
-			Select("*", "", false).
-			Eq("project_id", projectId).
-			Execute()
-
-		if err != nil {
-			fmt.Printf("Error fetching timeline: %v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Debug logging
-		fmt.Printf("Project ID: %s\n", projectId)
-		fmt.Printf("Raw timeline data: %s\n", string(data))
-
-		// Decode the byte array into a string
-		var timelineData []Timeline
-		if err := json.Unmarshal(data, &timelineData); err != nil {
-			fmt.Printf("Error unmarshaling timeline data: %v\n", err)
-			http.Error(w, "Error processing timeline data", http.StatusInternalServerError)
-			return
-		}
-
-		// Check if we got any data
-		if len(timelineData) == 0 {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]interface{}{}) // Return empty object if no data
-			return
-		}
-
-		// Return the first timeline found
-		responseData := map[string]interface{}{
-			"start_date":  timelineData[0].StartDate,
-			"end_date":    timelineData[0].EndDate,
-			"description": timelineData[0].Description,
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(responseData)
-	}).Methods("GET", "OPTIONS")
-
-	// Update timeline endpoint
-	r.HandleFunc("/api/timeline/{id}", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		vars := mux.Vars(r)
-		timelineId := vars["id"]
-
-		var timeline Timeline
-		if err := json.NewDecoder(r.Body).Decode(&timeline); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// Update in Supabase
-		_, _, err := client.From("project_timelines").
-			Update(timeline, "", "").
-			Eq("id", timelineId).
-			Execute()
-
+	} else {
+		webhookSecrets, err := newSecretBox(cfg.Webhooks.SecretKey)
 		if err != nil {
-			fmt.Printf("Error updating timeline: %v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			fmt.Println("cannot configure webhooks:", err)
 			return
 		}
+		repos = NewSupabaseRepositories(newSupabaseDB(cfg.Supabase.URL, cfg.Supabase.ServiceKey), webhookSecrets)
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(timeline)
-	}).Methods("PUT", "OPTIONS")
-
-	// Create task endpoint
-	r.HandleFunc("/api/milestones/{milestoneId}/tasks", func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		milestoneId := vars["milestoneId"]
-
-		if r.Method == http.MethodGet {
-			// Fetch tasks from Supabase
-			data, _, err := client.From("milestone_tasks").
-				Select("*", "", false).
-				Eq("milestone_id", milestoneId).
-				Execute()
-
-			if err != nil {
-				fmt.Printf("Error fetching tasks: %v\n", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			// Parse the tasks data
-			var tasks []Task
-			if err := json.Unmarshal(data, &tasks); err != nil {
-				fmt.Printf("Error unmarshaling tasks: %v\n", err)
-				http.Error(w, "Error processing tasks data", http.StatusInternalServerError)
-				return
-			}
+	verifier, err := verifierFromConfig(cfg.Auth)
+	if err != nil {
+		fmt.Println("cannot configure authentication:", err)
+		return
+	}
 
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(tasks)
-			return
-		}
+	evidence, err := newEvidenceServices(cfg.Uploads)
+	if err != nil {
+		fmt.Println("cannot configure evidence uploads:", err)
+		return
+	}
 
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+	// Background work outlives the signal so that requests still being
+	// drained can publish events; it stops once the server is down.
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
-		var taskReq CreateTaskRequest
-		if err := json.NewDecoder(r.Body).Decode(&taskReq); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	notifier := newNotifierFromConfig(repos, cfg.Notifications)
+	go notifier.Run(background)
 
-		// Create task in Supabase
-		task := map[string]interface{}{
-			"milestone_id": milestoneId,
-			"title":        taskReq.Title,
-			"description":  taskReq.Description,
-			"assignee_id":  taskReq.AssigneeID,
-			"due_date":     taskReq.DueDate,
-			"status":       "pending",
-			"reviewed":     false,
-			"created_by":   taskReq.CreatedBy,
-		}
+	dispatcher := NewWebhookDispatcher(repos, cfg.Webhooks.AllowHTTP)
+	go dispatcher.Run(background)
 
-		data, _, err := client.From("milestone_tasks").Insert(task, false, "", "", "").Execute()
-		if err != nil {
-			fmt.Printf("Error creating task: %v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	srv := newServer(cfg, repos, evidence, notifier, dispatcher, verifier)
+	srv.startScheduler(background, repos.Locks, cfg.Scheduler)
+	r := srv.routes()
 
-		// Parse the response to get the created task
-		var createdTasks []Task
-		if err := json.Unmarshal(data, &createdTasks); err != nil {
-			fmt.Printf("Error unmarshaling task response: %v\n", err)
-			http.Error(w, "Error processing task data", http.StatusInternalServerError)
-			return
-		}
+	httpServer := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+	}
 
-		if len(createdTasks) == 0 {
-			http.Error(w, "No task was created", http.StatusInternalServerError)
-			return
-		}
+	signalled, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Server starting on %s (%s)\n", cfg.Addr, cfg.Environment)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		fmt.Println("server stopped:", err)
+		os.Exit(1)
+	case <-signalled.Done():
+		// A second signal kills the process without waiting.
+		stopSignals()
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(createdTasks[0])
-	}).Methods("GET", "POST", "OPTIONS")
+	// Stop accepting connections and let in-flight requests finish. Those
+	// still running at the deadline have their connections closed, which
+	// cancels their contexts and the Supabase calls made with them.
+	fmt.Printf("Shutting down; draining requests for up to %s\n", cfg.Timeouts.Shutdown)
+	drain, cancelDrain := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancelDrain()
+	if err := httpServer.Shutdown(drain); err != nil {
+		fmt.Println("requests still running at shutdown deadline:", err)
+		httpServer.Close()
+	}
+	stopBackground()
+	fmt.Println("Server stopped")
+}
 
-	// Update task endpoint
-	r.HandleFunc("/api/tasks/{taskId}", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+// loadConfig reads the server settings from the command line, the
+// environment and an optional config file. A way to verify access tokens
+// is always required, and the Supabase URL, service key and webhook secret
+// key are required unless the memory backend is used.
+func loadConfig() (config.Config, error) {
+	defaults := config.Default()
+	defaults.Addr = ":8000"
+	cfg, err := config.Load("server", defaults, os.Args[1:])
+	if err != nil {
+		return cfg, err
+	}
+	var errs []error
+	if cfg.Auth.JWTSecret == "" && cfg.Auth.JWKSFile == "" {
+		errs = append(errs, errors.New("set SUPABASE_JWT_SECRET or SUPABASE_JWKS_FILE to verify access tokens"))
+	}
+	if cfg.Storage == config.StorageSupabase && (cfg.Supabase.URL == "" || cfg.Supabase.ServiceKey == "") {
+		errs = append(errs, errors.New("set SUPABASE_URL and SUPABASE_SERVICE_KEY, or supabase.url and supabase.service_key in the config file"))
+	}
+	if cfg.Storage == config.StorageSupabase && cfg.Webhooks.SecretKey == "" {
+		errs = append(errs, errors.New("set WEBHOOK_SECRET_KEY, or webhooks.secret_key in the config file, to encrypt webhook secrets"))
+	}
+	if cfg.Storage == config.StorageSupabase && cfg.Uploads.URLSecret == "" {
+		errs = append(errs, errors.New("set EVIDENCE_URL_SECRET, or uploads.url_secret in the config file, to sign evidence download links"))
+	}
+	return cfg, errors.Join(errs...)
+}
 
-		vars := mux.Vars(r)
-		taskId := vars["taskId"]
+type ReassignTaskRequest struct {
+	AssigneeID string `json:"assignee_id"`
+}
 
-		var updates map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-			fmt.Printf("Error decoding task updates: %v\n", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+type MoveTaskRequest struct {
+	MilestoneID string `json:"milestone_id"`
+}
 
-		// Validate status if it's being updated
-		if status, ok := updates["status"].(string); ok {
-			validStatuses := []string{"pending", "in_progress", "completed"}
-			valid := false
-			for _, validStatus := range validStatuses {
-				if status == validStatus {
-					valid = true
-					break
-				}
-			}
-			if !valid {
-				http.Error(w, "Invalid status value", http.StatusBadRequest)
-				return
-			}
-		}
+// TaskTransition is one entry in a task's status history. FromStatus is
+// empty for the entry recorded when the task is created.
+type TaskTransition struct {
+	ID         string `json:"id"`
+	TaskID     string `json:"task_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	ChangedBy  string `json:"changed_by"`
+	Note       string `json:"note"`
+	CreatedAt  string `json:"created_at"`
+}
 
-		// Update task in Supabase
-		data, _, err := client.From("milestone_tasks").
-			Update(updates, "", "").
-			Eq("id", taskId).
-			Execute()
+type TransitionTaskRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
 
-		if err != nil {
-			fmt.Printf("Error updating task: %v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+// TaskReview is a reviewer's verdict on one submission round of a task.
+type TaskReview struct {
+	ID              string `json:"id"`
+	TaskID          string `json:"task_id"`
+	ReviewerID      string `json:"reviewer_id"`
+	Verdict         string `json:"verdict"`
+	Comments        string `json:"comments"`
+	SubmissionRound int    `json:"submission_round"`
+	CreatedAt       string `json:"created_at"`
+
+	// EvidenceIDs are the evidence items of the reviewed round and
+	// EvidenceSHA256 the hashes of its files, so the verdict stays tied to
+	// the exact content that was reviewed.
+	EvidenceIDs    []string `json:"evidence_ids"`
+	EvidenceSHA256 []string `json:"evidence_sha256"`
+}
 
-		// Parse the response to get the updated task
-		var updatedTasks []Task
-		if err := json.Unmarshal(data, &updatedTasks); err != nil {
-			fmt.Printf("Error unmarshaling task response: %v\n", err)
-			http.Error(w, "Error processing task data", http.StatusInternalServerError)
-			return
-		}
+type ReviewTaskRequest struct {
+	Verdict  string `json:"verdict"`
+	Comments string `json:"comments"`
+	// SubmissionRound, when set, must match the task's current round so a
+	// reviewer cannot rule on a submission that has since been replaced.
+	SubmissionRound *int `json:"submission_round"`
+}
 
-		if len(updatedTasks) == 0 {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
-		}
+// Evidence kinds.
+const (
+	EvidenceFile = "file"
+	EvidenceLink = "link"
+	EvidenceText = "text"
+)
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(updatedTasks[0])
-	}).Methods("PUT", "OPTIONS")
+// Evidence is one item a contributor submits for a task: an uploaded
+// file, a link or a text note. SubmissionRound is the round the item
+// belongs to, so reviewers see exactly what was submitted each time.
+type Evidence struct {
+	ID              string `json:"id"`
+	TaskID          string `json:"task_id"`
+	ProjectID       string `json:"project_id"`
+	Kind            string `json:"kind"`
+	FileName        string `json:"file_name,omitempty"`
+	URL             string `json:"url,omitempty"`
+	Body            string `json:"body,omitempty"`
+	MimeType        string `json:"mime_type,omitempty"`
+	Size            int64  `json:"size"`
+	SHA256          string `json:"sha256,omitempty"`
+	SubmissionRound int    `json:"submission_round"`
+	UploadedBy      string `json:"uploaded_by"`
+	CreatedAt       string `json:"created_at"`
+}
 
-	// Review task endpoint
-	r.HandleFunc("/api/tasks/{taskId}/review", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+// CreateEvidenceRequest adds a link or text note; files are uploaded as
+// multipart form data instead.
+type CreateEvidenceRequest struct {
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+	Body string `json:"body"`
+}
 
-		vars := mux.Vars(r)
-		taskId := vars["taskId"]
+// UploadPolicy narrows the server's evidence upload limits for one
+// project. Empty or zero fields fall back to the server defaults.
+type UploadPolicy struct {
+	ProjectID        string   `json:"project_id"`
+	AllowedMimeTypes []string `json:"allowed_mime_types"`
+	MaxFileBytes     int64    `json:"max_file_bytes"`
+	QuotaBytes       int64    `json:"quota_bytes"`
+	UpdatedAt        string   `json:"updated_at,omitempty"`
+}
 
-		// Update task review status in Supabase
-		updates := map[string]interface{}{
-			"reviewed": true,
-		}
+// UploadPolicyResponse is a project's effective upload policy and how much
+// of its quota is used.
+type UploadPolicyResponse struct {
+	UploadPolicy
+	UsedBytes int64 `json:"used_bytes"`
+}
 
-		data, _, err := client.From("milestone_tasks").
-			Update(updates, "", "").
-			Eq("id", taskId).
-			Execute()
+// Schedule issue kinds reported by the schedule validation endpoint.
+const (
+	IssuePhaseEndsBeforeStart    = "phase_ends_before_start"
+	IssueMilestoneOutsidePhase   = "milestone_outside_phase"
+	IssueMilestoneOutsideProject = "milestone_outside_timeline"
+	IssueTaskAfterMilestone      = "task_due_after_milestone"
+)
 
-		if err != nil {
-			fmt.Printf("Error reviewing task: %v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+// ScheduleIssue is one inconsistency in a project's schedule.
+type ScheduleIssue struct {
+	Kind        string `json:"kind"`
+	Message     string `json:"message"`
+	PhaseID     string `json:"phase_id,omitempty"`
+	MilestoneID string `json:"milestone_id,omitempty"`
+	TaskID      string `json:"task_id,omitempty"`
+}
 
-		// Parse the response to get the updated task
-		var updatedTask Task
-		if err := json.Unmarshal(data, &updatedTask); err != nil {
-			fmt.Printf("Error unmarshaling task response: %v\n", err)
-			http.Error(w, "Error processing task data", http.StatusInternalServerError)
-			return
-		}
+// ScheduleReport lists every schedule issue found in a project.
+type ScheduleReport struct {
+	Valid  bool            `json:"valid"`
+	Issues []ScheduleIssue `json:"issues"`
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(updatedTask)
-	}).Methods("POST", "OPTIONS")
+// Page is one page of a paginated listing. NextCursor is empty on the
+// last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
 
-	// Upload task evidence endpoint
-	r.HandleFunc("/api/tasks/{taskId}/evidence", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+// Assigned task buckets.
+const (
+	BucketOverdue     = "overdue"
+	BucketDueSoon     = "due_soon"
+	BucketLater       = "later"
+	BucketUnscheduled = "unscheduled"
+	BucketDone        = "done"
+)
 
-		vars := mux.Vars(r)
-		taskId := vars["taskId"]
+// AssignedTask is a task listed outside its milestone, with the context
+// needed to show it there.
+type AssignedTask struct {
+	Task
+	ProjectID        string `json:"project_id"`
+	MilestoneTitle   string `json:"milestone_title"`
+	MilestoneDueDate Date   `json:"milestone_due_date"`
+	// Bucket is set by the assigned tasks endpoints.
+	Bucket string `json:"bucket,omitempty"`
+}
 
-		// Parse multipart form
-		if err := r.ParseMultipartForm(10 << 20); err != nil { // 10 MB max
-			http.Error(w, "Error parsing form", http.StatusBadRequest)
-			return
-		}
+// AssignedMilestoneTasks groups a user's tasks in one milestone.
+type AssignedMilestoneTasks struct {
+	MilestoneID string         `json:"milestone_id"`
+	Title       string         `json:"title"`
+	DueDate     Date           `json:"due_date"`
+	Tasks       []AssignedTask `json:"tasks"`
+}
 
-		file, handler, err := r.FormFile("file")
-		if err != nil {
-			http.Error(w, "Error retrieving file", http.StatusBadRequest)
-			return
-		}
-		defer file.Close()
+// AssignedProjectTasks groups a user's tasks in one project.
+type AssignedProjectTasks struct {
+	ProjectID  string                   `json:"project_id"`
+	Milestones []AssignedMilestoneTasks `json:"milestones"`
+}
 
-		// Create uploads directory if it doesn't exist
-		uploadDir := "./uploads"
-		if err := os.MkdirAll(uploadDir, 0755); err != nil {
-			http.Error(w, "Error creating upload directory", http.StatusInternalServerError)
-			return
-		}
+// AssignedTasksResponse is everything assigned to one user across
+// projects, grouped by project and milestone, with the overdue and
+// due-soon tasks also listed on their own.
+type AssignedTasksResponse struct {
+	AssigneeID string                 `json:"assignee_id"`
+	Total      int                    `json:"total"`
+	Overdue    []AssignedTask         `json:"overdue"`
+	DueSoon    []AssignedTask         `json:"due_soon"`
+	Projects   []AssignedProjectTasks `json:"projects"`
+}
 
-		// Create unique filename
-		filename := fmt.Sprintf("%s_%s", taskId, handler.Filename)
-		filepath := filepath.Join(uploadDir, filename)
+// Notification is one entry in a user's in-app inbox, created from a
+// domain event.
+type Notification struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	ProjectID   string `json:"project_id"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	MilestoneID string `json:"milestone_id,omitempty"`
+	TaskID      string `json:"task_id,omitempty"`
+	EventID     string `json:"event_id"`
+	ReadAt      string `json:"read_at,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
 
-		// Create the file
-		dst, err := os.Create(filepath)
-		if err != nil {
-			http.Error(w, "Error creating file", http.StatusInternalServerError)
-			return
-		}
-		defer dst.Close()
+// NotificationsResponse is a page of a user's inbox.
+type NotificationsResponse struct {
+	Items       []Notification `json:"items"`
+	UnreadCount int            `json:"unread_count"`
+}
 
-		// Copy the uploaded file to the destination file
-		if _, err := io.Copy(dst, file); err != nil {
-			http.Error(w, "Error saving file", http.StatusInternalServerError)
-			return
-		}
+// NotificationPreferences choose how a user is notified besides the
+// in-app inbox. MutedTypes lists event types the user is not notified
+// about at all.
+type NotificationPreferences struct {
+	UserID         string   `json:"user_id"`
+	Email          string   `json:"email"`
+	EmailEnabled   bool     `json:"email_enabled"`
+	WebhookURL     string   `json:"webhook_url"`
+	WebhookEnabled bool     `json:"webhook_enabled"`
+	MutedTypes     []string `json:"muted_types"`
+	UpdatedAt      string   `json:"updated_at,omitempty"`
+}
 
-		// Generate URL for the uploaded file
-		evidenceUrl := fmt.Sprintf("/uploads/%s", filename)
+// Webhook subscribes an external URL to a project's events. Deliveries
+// are signed with Secret, which is only returned when the webhook is
+// created.
+type Webhook struct {
+	ID        string   `json:"id"`
+	ProjectID string   `json:"project_id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	Active    bool     `json:"active"`
+	CreatedBy string   `json:"created_by"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
 
-		// Update task with evidence URL in Supabase
-		updates := map[string]interface{}{
-			"evidence": evidenceUrl,
-		}
+// withoutSecret returns the webhook with its signing secret cleared. The
+// secret is only shown in the response that creates the webhook.
+func (w Webhook) withoutSecret() Webhook {
+	w.Secret = ""
+	return w
+}
 
-		data, _, err := client.From("tasks").
-			Update(updates, "", "").
-			Eq("id", taskId).
-			Execute()
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Active defaults to true.
+	Active *bool `json:"active"`
+}
 
-		if err != nil {
-			fmt.Printf("Error updating task evidence: %v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+// UpdateWebhookRequest changes the fields that are present.
+type UpdateWebhookRequest struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
 
-		// Parse the response to get the updated task
-		var updatedTask Task
-		if err := json.Unmarshal(data, &updatedTask); err != nil {
-			fmt.Printf("Error unmarshaling task response: %v\n", err)
-			http.Error(w, "Error processing task data", http.StatusInternalServerError)
-			return
-		}
+// WebhookDelivery is one event sent to a webhook, with the outcome of
+// its latest attempt. Pending deliveries are retried at NextAttemptAt.
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	ProjectID      string          `json:"project_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  string          `json:"last_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	// ReplayOf is the delivery this one replays.
+	ReplayOf  string `json:"replay_of,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"evidenceUrl": evidenceUrl,
-		})
-	}).Methods("POST", "OPTIONS")
+// AuditEntry records one change made through the API: who made it, with
+// which request, and how the entity's fields changed. Entries are never
+// updated or deleted.
+type AuditEntry struct {
+	ID        string `json:"id"`
+	RequestID string `json:"request_id"`
+	ActorID   string `json:"actor_id"`
+	Method    string `json:"method"`
+	// Endpoint is the route template, such as /api/tasks/{taskId}.
+	Endpoint   string                 `json:"endpoint"`
+	Path       string                 `json:"path"`
+	Status     int                    `json:"status"`
+	ProjectID  string                 `json:"project_id,omitempty"`
+	EntityType string                 `json:"entity_type"`
+	EntityID   string                 `json:"entity_id,omitempty"`
+	Action     string                 `json:"action"`
+	Changes    map[string]AuditChange `json:"changes,omitempty"`
+	CreatedAt  string                 `json:"created_at"`
+}
 
-	fmt.Println("Server starting on :8000")
-	http.ListenAndServe(":8000", r)
+// AuditChange is a field's value before and after a change; From is null
+// for created entities and To for deleted ones.
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
 }
@@ -0,0 +1,1138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore holds every table of the in-memory backend behind one lock so
+// joined reads (milestones with their tasks) see a consistent snapshot.
+type memoryStore struct {
+	mu         sync.RWMutex
+	timelines  map[string]Timeline
+	milestones map[string]Milestone
+	tasks      map[string]Task
+	history    []TaskTransition
+	reviews    []TaskReview
+	evidence   []Evidence
+	files      map[string]memoryFile
+	policies   map[string]UploadPolicy
+	members    map[string]map[string]Role
+	locks      map[string]memoryLock
+
+	notifications     []Notification
+	notificationPrefs map[string]NotificationPreferences
+	webhooks          map[string]Webhook
+	webhookDeliveries []WebhookDelivery
+	audit             []AuditEntry
+}
+
+// NewMemoryRepositories returns repositories that keep all data in process
+// memory. It is intended for local development and tests without Supabase.
+func NewMemoryRepositories() Repositories {
+	store := &memoryStore{
+		timelines:  make(map[string]Timeline),
+		milestones: make(map[string]Milestone),
+		tasks:      make(map[string]Task),
+		files:      make(map[string]memoryFile),
+		policies:   make(map[string]UploadPolicy),
+		members:    make(map[string]map[string]Role),
+		locks:      make(map[string]memoryLock),
+
+		notificationPrefs: make(map[string]NotificationPreferences),
+		webhooks:          make(map[string]Webhook),
+	}
+	return Repositories{
+		Timelines:  &memoryTimelineRepository{store: store},
+		Milestones: &memoryMilestoneRepository{store: store},
+		Tasks:      &memoryTaskRepository{store: store},
+		History:    &memoryTaskHistoryRepository{store: store},
+		Reviews:    &memoryReviewRepository{store: store},
+		Evidence:   &memoryEvidenceRepository{store: store},
+		Policies:   &memoryUploadPolicyRepository{store: store},
+		Members:    &MemoryMembershipRepository{store: store},
+		Locks:      &memoryLockRepository{store: store},
+
+		Notifications:     &memoryNotificationRepository{store: store},
+		NotificationPrefs: &memoryNotificationPreferenceRepository{store: store},
+		Webhooks:          &memoryWebhookRepository{store: store},
+		WebhookDeliveries: &memoryWebhookDeliveryRepository{store: store},
+		Audit:             &memoryAuditRepository{store: store},
+	}
+}
+
+// now returns the current time in the format PostgREST uses for timestamps.
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// sortByCreated orders records the way rows come back from Postgres when
+// inserted sequentially.
+func sortByCreated[T any](items []T, key func(T) (string, string)) {
+	sort.Slice(items, func(i, j int) bool {
+		ci, ii := key(items[i])
+		cj, ij := key(items[j])
+		if ci != cj {
+			return ci < cj
+		}
+		return ii < ij
+	})
+}
+
+type memoryTimelineRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryTimelineRepository) Create(ctx context.Context, timeline Timeline) (Timeline, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	timeline.ID = uuid.NewString()
+	timeline.CreatedAt = now()
+	timeline.UpdatedAt = timeline.CreatedAt
+	r.store.timelines[timeline.ID] = timeline
+	return timeline, nil
+}
+
+func (r *memoryTimelineRepository) Get(ctx context.Context, id string) (Timeline, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	timeline, ok := r.store.timelines[id]
+	if !ok {
+		return Timeline{}, ErrNotFound
+	}
+	return timeline, nil
+}
+
+func (r *memoryTimelineRepository) ListByProject(ctx context.Context, projectID string) ([]Timeline, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	timelines := []Timeline{}
+	for _, t := range r.store.timelines {
+		if t.ProjectID == projectID {
+			timelines = append(timelines, t)
+		}
+	}
+	sortByCreated(timelines, func(t Timeline) (string, string) { return t.CreatedAt, t.ID })
+	sort.SliceStable(timelines, func(i, j int) bool { return timelines[i].Position < timelines[j].Position })
+	return timelines, nil
+}
+
+func (r *memoryTimelineRepository) Update(ctx context.Context, id string, timeline Timeline) (Timeline, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.timelines[id]
+	if !ok {
+		return Timeline{}, ErrNotFound
+	}
+	existing.Name = timeline.Name
+	existing.StartDate = timeline.StartDate
+	existing.EndDate = timeline.EndDate
+	existing.Description = timeline.Description
+	existing.UpdatedAt = now()
+	r.store.timelines[id] = existing
+	return existing, nil
+}
+
+func (r *memoryTimelineRepository) Reorder(ctx context.Context, projectID string, ids []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, id := range ids {
+		if t, ok := r.store.timelines[id]; !ok || t.ProjectID != projectID {
+			return ErrNotFound
+		}
+	}
+	for i, id := range ids {
+		t := r.store.timelines[id]
+		t.Position = i
+		t.UpdatedAt = now()
+		r.store.timelines[id] = t
+	}
+	return nil
+}
+
+// Delete mirrors the ON DELETE SET NULL on milestones.phase_id.
+func (r *memoryTimelineRepository) Delete(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.timelines[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.timelines, id)
+	for mid, m := range r.store.milestones {
+		if m.PhaseID == id {
+			m.PhaseID = ""
+			r.store.milestones[mid] = m
+		}
+	}
+	return nil
+}
+
+type memoryMilestoneRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryMilestoneRepository) Create(ctx context.Context, milestone Milestone) (Milestone, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	milestone.ID = uuid.NewString()
+	milestone.CreatedAt = now()
+	milestone.UpdatedAt = milestone.CreatedAt
+	milestone.Tasks = nil
+	r.store.milestones[milestone.ID] = milestone
+	return r.withTasks(milestone), nil
+}
+
+func (r *memoryMilestoneRepository) Get(ctx context.Context, id string) (Milestone, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	milestone, ok := r.store.milestones[id]
+	if !ok {
+		return Milestone{}, ErrNotFound
+	}
+	return r.withTasks(milestone), nil
+}
+
+func (r *memoryMilestoneRepository) ListByProject(ctx context.Context, projectID string) ([]Milestone, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	milestones := []Milestone{}
+	for _, m := range r.store.milestones {
+		if m.ProjectID == projectID {
+			milestones = append(milestones, r.withTasks(m))
+		}
+	}
+	sortByCreated(milestones, func(m Milestone) (string, string) { return m.CreatedAt, m.ID })
+	return milestones, nil
+}
+
+func (r *memoryMilestoneRepository) List(ctx context.Context, projectID string, q ListQuery) ([]Milestone, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	milestones := []Milestone{}
+	for _, m := range r.store.milestones {
+		if m.ProjectID == projectID && q.matchMilestone(m) {
+			milestones = append(milestones, r.withTasks(m))
+		}
+	}
+	return sortAndPage(q, milestones, milestoneSortKey(q.Sort)), nil
+}
+
+func (r *memoryMilestoneRepository) ListOverdueCandidates(ctx context.Context, now time.Time) ([]Milestone, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	milestones := []Milestone{}
+	for _, m := range r.store.milestones {
+		if m.OverdueSince != "" || milestoneOverdue(m, now) {
+			milestones = append(milestones, m)
+		}
+	}
+	sortByCreated(milestones, func(m Milestone) (string, string) { return m.CreatedAt, m.ID })
+	return milestones, nil
+}
+
+func (r *memoryMilestoneRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (Milestone, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	milestone, ok := r.store.milestones[id]
+	if !ok {
+		return Milestone{}, ErrNotFound
+	}
+	updated, err := applyUpdates(milestone, updates)
+	if err != nil {
+		return Milestone{}, err
+	}
+	updated.ID = milestone.ID
+	updated.CreatedAt = milestone.CreatedAt
+	updated.UpdatedAt = now()
+	updated.Tasks = nil
+	r.store.milestones[id] = updated
+	return r.withTasks(updated), nil
+}
+
+func (r *memoryMilestoneRepository) Delete(ctx context.Context, id, onTasks, targetID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	milestone, ok := r.store.milestones[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if onTasks == onTasksMove {
+		target, ok := r.store.milestones[targetID]
+		if !ok || targetID == id || target.ProjectID != milestone.ProjectID {
+			return ErrConflict
+		}
+	}
+	for taskID, t := range r.store.tasks {
+		if t.MilestoneID != id {
+			continue
+		}
+		switch onTasks {
+		case onTasksCascade:
+			delete(r.store.tasks, taskID)
+			r.store.deleteTaskRecords(taskID)
+		case onTasksMove:
+			t.MilestoneID = targetID
+			r.store.tasks[taskID] = t
+		default:
+			return ErrConflict
+		}
+	}
+	delete(r.store.milestones, id)
+	return nil
+}
+
+// withTasks embeds the milestone's tasks the way the Supabase join does.
+// The caller must hold the store lock.
+func (r *memoryMilestoneRepository) withTasks(milestone Milestone) Milestone {
+	milestone.Tasks = []Task{}
+	for _, t := range r.store.tasks {
+		if t.MilestoneID == milestone.ID {
+			milestone.Tasks = append(milestone.Tasks, t)
+		}
+	}
+	sortByCreated(milestone.Tasks, func(t Task) (string, string) { return t.CreatedAt, t.ID })
+	return milestone
+}
+
+type memoryTaskRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryTaskRepository) Create(ctx context.Context, task Task) (Task, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	task.ID = uuid.NewString()
+	task.CreatedAt = now()
+	task.UpdatedAt = task.CreatedAt
+	r.store.tasks[task.ID] = task
+	return task, nil
+}
+
+func (r *memoryTaskRepository) Get(ctx context.Context, id string) (Task, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	task, ok := r.store.tasks[id]
+	if !ok {
+		return Task{}, ErrNotFound
+	}
+	return task, nil
+}
+
+func (r *memoryTaskRepository) ListByMilestone(ctx context.Context, milestoneID string) ([]Task, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	tasks := []Task{}
+	for _, t := range r.store.tasks {
+		if t.MilestoneID == milestoneID {
+			tasks = append(tasks, t)
+		}
+	}
+	sortByCreated(tasks, func(t Task) (string, string) { return t.CreatedAt, t.ID })
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) ListByAssignee(ctx context.Context, assigneeID string) ([]AssignedTask, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	tasks := []AssignedTask{}
+	for _, t := range r.store.tasks {
+		if t.AssigneeID != assigneeID {
+			continue
+		}
+		milestone, ok := r.store.milestones[t.MilestoneID]
+		if !ok {
+			continue
+		}
+		tasks = append(tasks, AssignedTask{
+			Task:             t,
+			ProjectID:        milestone.ProjectID,
+			MilestoneTitle:   milestone.Title,
+			MilestoneDueDate: milestone.DueDate,
+		})
+	}
+	byDue := ListQuery{Sort: "due_date"}
+	return sortAndPage(byDue, tasks, func(t AssignedTask) (*string, string) { return dateKey(t.DueDate), t.ID }), nil
+}
+
+func (r *memoryTaskRepository) ListOverdueCandidates(ctx context.Context, now time.Time) ([]Task, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	tasks := []Task{}
+	for _, t := range r.store.tasks {
+		if t.OverdueSince != "" || taskOverdue(t, now) {
+			tasks = append(tasks, t)
+		}
+	}
+	sortByCreated(tasks, func(t Task) (string, string) { return t.CreatedAt, t.ID })
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) List(ctx context.Context, milestoneID string, q ListQuery) ([]Task, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	tasks := []Task{}
+	for _, t := range r.store.tasks {
+		if t.MilestoneID == milestoneID && q.matchTask(t) {
+			tasks = append(tasks, t)
+		}
+	}
+	return sortAndPage(q, tasks, taskSortKey(q.Sort)), nil
+}
+
+func (r *memoryTaskRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (Task, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.tasks[id]; !ok {
+		return Task{}, ErrNotFound
+	}
+	return r.update(id, updates)
+}
+
+func (r *memoryTaskRepository) UpdateIfStatus(ctx context.Context, id, status string, updates map[string]interface{}) (Task, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if task, ok := r.store.tasks[id]; !ok || task.Status != status {
+		return Task{}, ErrConflict
+	}
+	return r.update(id, updates)
+}
+
+// update applies updates to an existing task. The caller must hold the
+// store lock.
+func (r *memoryTaskRepository) update(id string, updates map[string]interface{}) (Task, error) {
+	task := r.store.tasks[id]
+	updated, err := applyUpdates(task, updates)
+	if err != nil {
+		return Task{}, err
+	}
+	updated.ID = task.ID
+	updated.CreatedAt = task.CreatedAt
+	updated.UpdatedAt = now()
+	r.store.tasks[id] = updated
+	return updated, nil
+}
+
+func (r *memoryTaskRepository) Delete(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.tasks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.tasks, id)
+	r.store.deleteTaskRecords(id)
+	return nil
+}
+
+// deleteTaskRecords mirrors the ON DELETE CASCADE on task_status_history,
+// task_reviews and task_evidence. The caller must hold the store lock.
+func (s *memoryStore) deleteTaskRecords(taskID string) {
+	kept := s.history[:0]
+	for _, h := range s.history {
+		if h.TaskID != taskID {
+			kept = append(kept, h)
+		}
+	}
+	s.history = kept
+
+	keptReviews := s.reviews[:0]
+	for _, rv := range s.reviews {
+		if rv.TaskID != taskID {
+			keptReviews = append(keptReviews, rv)
+		}
+	}
+	s.reviews = keptReviews
+
+	keptEvidence := s.evidence[:0]
+	for _, e := range s.evidence {
+		if e.TaskID != taskID {
+			keptEvidence = append(keptEvidence, e)
+		}
+	}
+	s.evidence = keptEvidence
+}
+
+type memoryTaskHistoryRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryTaskHistoryRepository) Append(ctx context.Context, transition TaskTransition) (TaskTransition, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	transition.ID = uuid.NewString()
+	transition.CreatedAt = now()
+	r.store.history = append(r.store.history, transition)
+	return transition, nil
+}
+
+func (r *memoryTaskHistoryRepository) ListByTask(ctx context.Context, taskID string) ([]TaskTransition, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	transitions := []TaskTransition{}
+	for _, h := range r.store.history {
+		if h.TaskID == taskID {
+			transitions = append(transitions, h)
+		}
+	}
+	return transitions, nil
+}
+
+type memoryReviewRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryReviewRepository) Create(ctx context.Context, review TaskReview) (TaskReview, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	review.ID = uuid.NewString()
+	review.CreatedAt = now()
+	r.store.reviews = append(r.store.reviews, review)
+	return review, nil
+}
+
+func (r *memoryReviewRepository) ListByTask(ctx context.Context, taskID string) ([]TaskReview, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	reviews := []TaskReview{}
+	for _, rv := range r.store.reviews {
+		if rv.TaskID == taskID {
+			reviews = append(reviews, rv)
+		}
+	}
+	return reviews, nil
+}
+
+func (r *memoryReviewRepository) Delete(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, rv := range r.store.reviews {
+		if rv.ID == id {
+			r.store.reviews = append(r.store.reviews[:i], r.store.reviews[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+type memoryEvidenceRepository struct {
+	store *memoryStore
+}
+
+// memoryFile tracks a stored evidence file for the collector.
+type memoryFile struct {
+	lastUsed   time.Time
+	collecting bool
+}
+
+func (r *memoryEvidenceRepository) Create(ctx context.Context, evidence Evidence, quotaBytes int64) (Evidence, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.tasks[evidence.TaskID]; !ok {
+		return Evidence{}, ErrNotFound
+	}
+	if evidence.Kind == EvidenceFile {
+		if r.projectUsage(evidence.ProjectID)+evidence.Size > quotaBytes {
+			return Evidence{}, ErrQuotaExceeded
+		}
+		file, ok := r.store.files[evidence.SHA256]
+		if !ok || file.collecting {
+			return Evidence{}, ErrConflict
+		}
+		r.store.files[evidence.SHA256] = memoryFile{lastUsed: time.Now()}
+	}
+	evidence.ID = uuid.NewString()
+	evidence.CreatedAt = now()
+	r.store.evidence = append(r.store.evidence, evidence)
+	return evidence, nil
+}
+
+func (r *memoryEvidenceRepository) Get(ctx context.Context, id string) (Evidence, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, e := range r.store.evidence {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Evidence{}, ErrNotFound
+}
+
+func (r *memoryEvidenceRepository) ListByTask(ctx context.Context, taskID string) ([]Evidence, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	items := []Evidence{}
+	for _, e := range r.store.evidence {
+		if e.TaskID == taskID {
+			items = append(items, e)
+		}
+	}
+	return items, nil
+}
+
+func (r *memoryEvidenceRepository) Delete(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, e := range r.store.evidence {
+		if e.ID == id {
+			r.store.evidence = append(r.store.evidence[:i], r.store.evidence[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryEvidenceRepository) ProjectUsage(ctx context.Context, projectID string) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.projectUsage(projectID), nil
+}
+
+// projectUsage totals a project's files. The caller holds the lock.
+func (r *memoryEvidenceRepository) projectUsage(projectID string) int64 {
+	var used int64
+	for _, e := range r.store.evidence {
+		if e.ProjectID == projectID && e.Kind == EvidenceFile {
+			used += e.Size
+		}
+	}
+	return used
+}
+
+func (r *memoryEvidenceRepository) ClaimFile(ctx context.Context, sha256 string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.store.files[sha256].collecting {
+		return ErrConflict
+	}
+	r.store.files[sha256] = memoryFile{lastUsed: time.Now()}
+	return nil
+}
+
+func (r *memoryEvidenceRepository) CollectFiles(ctx context.Context, unusedFor time.Duration) ([]string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	referenced := make(map[string]bool)
+	for _, e := range r.store.evidence {
+		if e.Kind == EvidenceFile {
+			referenced[e.SHA256] = true
+		}
+	}
+	cutoff := time.Now().Add(-unusedFor)
+	hashes := []string{}
+	for sha256, file := range r.store.files {
+		if file.collecting || (!referenced[sha256] && !file.lastUsed.After(cutoff)) {
+			r.store.files[sha256] = memoryFile{lastUsed: file.lastUsed, collecting: true}
+			hashes = append(hashes, sha256)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+func (r *memoryEvidenceRepository) ForgetFile(ctx context.Context, sha256 string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.store.files[sha256].collecting {
+		delete(r.store.files, sha256)
+	}
+	return nil
+}
+
+type memoryUploadPolicyRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryUploadPolicyRepository) Get(ctx context.Context, projectID string) (UploadPolicy, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	policy, ok := r.store.policies[projectID]
+	if !ok {
+		return UploadPolicy{}, ErrNotFound
+	}
+	return policy, nil
+}
+
+func (r *memoryUploadPolicyRepository) Save(ctx context.Context, policy UploadPolicy) (UploadPolicy, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	policy.UpdatedAt = now()
+	r.store.policies[policy.ProjectID] = policy
+	return policy, nil
+}
+
+// MemoryMembershipRepository keeps project roles in memory. A project
+// exists once any user has been granted a role in it.
+type MemoryMembershipRepository struct {
+	store *memoryStore
+}
+
+// Grant gives userID the role in projectID, replacing any previous role.
+func (r *MemoryMembershipRepository) Grant(projectID, userID string, role Role) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.store.members[projectID] == nil {
+		r.store.members[projectID] = make(map[string]Role)
+	}
+	r.store.members[projectID][userID] = role
+}
+
+func (r *MemoryMembershipRepository) ProjectRole(ctx context.Context, projectID, userID string) (Role, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	members, ok := r.store.members[projectID]
+	if !ok {
+		return RoleNone, ErrNotFound
+	}
+	return members[userID], nil
+}
+
+func (r *MemoryMembershipRepository) ProjectLead(ctx context.Context, projectID string) (string, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	members, ok := r.store.members[projectID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	for userID, role := range members {
+		if role == RoleLead {
+			return userID, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// seedMemberships grants roles from project:user:role entries, which the
+// config has already checked, so the in-memory backend can be used without
+// the ideas and idea_contributors tables.
+func seedMemberships(repo *MemoryMembershipRepository, entries []string) {
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) == 3 {
+			repo.Grant(parts[0], parts[1], Role(parts[2]))
+		}
+	}
+}
+
+// applyUpdates merges a column/value map into a record the way a PostgREST
+// PATCH does, by round-tripping the record through its JSON representation.
+func applyUpdates[T any](record T, updates map[string]interface{}) (T, error) {
+	var merged T
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return merged, err
+	}
+	var columns map[string]interface{}
+	if err := json.Unmarshal(raw, &columns); err != nil {
+		return merged, err
+	}
+	for k, v := range updates {
+		columns[k] = v
+	}
+	raw, err = json.Marshal(columns)
+	if err != nil {
+		return merged, err
+	}
+	if err := json.Unmarshal(raw, &merged); err != nil {
+		return merged, err
+	}
+	return merged, nil
+}
+
+type memoryLock struct {
+	holder  string
+	expires time.Time
+}
+
+type memoryLockRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryLockRepository) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	current, held := r.store.locks[name]
+	if held && current.holder != holder && time.Now().Before(current.expires) {
+		return false, nil
+	}
+	r.store.locks[name] = memoryLock{holder: holder, expires: time.Now().Add(ttl)}
+	return true, nil
+}
+
+type memoryNotificationRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryNotificationRepository) Create(ctx context.Context, notification Notification) (Notification, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	notification.ID = uuid.NewString()
+	notification.CreatedAt = now()
+	r.store.notifications = append(r.store.notifications, notification)
+	return notification, nil
+}
+
+func (r *memoryNotificationRepository) ListByUser(ctx context.Context, userID string, unreadOnly bool, limit int) ([]Notification, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	notifications := []Notification{}
+	for i := len(r.store.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		n := r.store.notifications[i]
+		if n.UserID == userID && (!unreadOnly || n.ReadAt == "") {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (r *memoryNotificationRepository) CountUnread(ctx context.Context, userID string) (int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	count := 0
+	for _, n := range r.store.notifications {
+		if n.UserID == userID && n.ReadAt == "" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryNotificationRepository) MarkRead(ctx context.Context, userID, id string) (Notification, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, n := range r.store.notifications {
+		if n.ID == id && n.UserID == userID {
+			if n.ReadAt == "" {
+				r.store.notifications[i].ReadAt = now()
+			}
+			return r.store.notifications[i], nil
+		}
+	}
+	return Notification{}, ErrNotFound
+}
+
+func (r *memoryNotificationRepository) MarkAllRead(ctx context.Context, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	readAt := now()
+	for i, n := range r.store.notifications {
+		if n.UserID == userID && n.ReadAt == "" {
+			r.store.notifications[i].ReadAt = readAt
+		}
+	}
+	return nil
+}
+
+type memoryNotificationPreferenceRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryNotificationPreferenceRepository) Get(ctx context.Context, userID string) (NotificationPreferences, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	prefs, ok := r.store.notificationPrefs[userID]
+	if !ok {
+		return NotificationPreferences{}, ErrNotFound
+	}
+	return prefs, nil
+}
+
+func (r *memoryNotificationPreferenceRepository) Save(ctx context.Context, prefs NotificationPreferences) (NotificationPreferences, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	prefs.UpdatedAt = now()
+	r.store.notificationPrefs[prefs.UserID] = prefs
+	return prefs, nil
+}
+
+type memoryWebhookRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryWebhookRepository) Create(ctx context.Context, webhook Webhook) (Webhook, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	webhook.ID = uuid.NewString()
+	webhook.CreatedAt = now()
+	webhook.UpdatedAt = webhook.CreatedAt
+	r.store.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (r *memoryWebhookRepository) Get(ctx context.Context, id string) (Webhook, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	webhook, ok := r.store.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (r *memoryWebhookRepository) ListByProject(ctx context.Context, projectID string) ([]Webhook, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	webhooks := []Webhook{}
+	for _, w := range r.store.webhooks {
+		if w.ProjectID == projectID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	sortByCreated(webhooks, func(w Webhook) (string, string) { return w.CreatedAt, w.ID })
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) ListForEvent(ctx context.Context, projectID, eventType string) ([]Webhook, error) {
+	webhooks, err := r.ListByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	subscribed := []Webhook{}
+	for _, w := range webhooks {
+		if w.Active && contains(w.Events, eventType) {
+			subscribed = append(subscribed, w)
+		}
+	}
+	return subscribed, nil
+}
+
+func (r *memoryWebhookRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (Webhook, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	webhook, ok := r.store.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrNotFound
+	}
+	updated, err := applyUpdates(webhook, updates)
+	if err != nil {
+		return Webhook{}, err
+	}
+	updated.ID = webhook.ID
+	updated.CreatedAt = webhook.CreatedAt
+	updated.UpdatedAt = now()
+	r.store.webhooks[id] = updated
+	return updated, nil
+}
+
+func (r *memoryWebhookRepository) Delete(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.webhooks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.webhooks, id)
+
+	kept := r.store.webhookDeliveries[:0]
+	for _, d := range r.store.webhookDeliveries {
+		if d.WebhookID != id {
+			kept = append(kept, d)
+		}
+	}
+	r.store.webhookDeliveries = kept
+	return nil
+}
+
+type memoryWebhookDeliveryRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryWebhookDeliveryRepository) Create(ctx context.Context, delivery WebhookDelivery) (WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delivery.ID = uuid.NewString()
+	delivery.CreatedAt = now()
+	r.store.webhookDeliveries = append(r.store.webhookDeliveries, delivery)
+	return delivery, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) Get(ctx context.Context, id string) (WebhookDelivery, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, d := range r.store.webhookDeliveries {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	return WebhookDelivery{}, ErrNotFound
+}
+
+func (r *memoryWebhookDeliveryRepository) ListByWebhook(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	deliveries := []WebhookDelivery{}
+	for i := len(r.store.webhookDeliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if d := r.store.webhookDeliveries[i]; d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	deliveries := []WebhookDelivery{}
+	for _, d := range r.store.webhookDeliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.Status != DeliveryPending || d.NextAttemptAt == "" {
+			continue
+		}
+		next, err := time.Parse(time.RFC3339Nano, d.NextAttemptAt)
+		if err == nil && !next.After(now) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, d := range r.store.webhookDeliveries {
+		if d.ID != id {
+			continue
+		}
+		updated, err := applyUpdates(d, updates)
+		if err != nil {
+			return WebhookDelivery{}, err
+		}
+		updated.ID = d.ID
+		updated.CreatedAt = d.CreatedAt
+		r.store.webhookDeliveries[i] = updated
+		return updated, nil
+	}
+	return WebhookDelivery{}, ErrNotFound
+}
+
+type memoryAuditRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryAuditRepository) Append(ctx context.Context, entries []AuditEntry) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	createdAt := now()
+	for _, entry := range entries {
+		entry.ID = uuid.NewString()
+		entry.CreatedAt = createdAt
+		r.store.audit = append(r.store.audit, entry)
+	}
+	return nil
+}
+
+func (r *memoryAuditRepository) List(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	type keyed struct {
+		entry AuditEntry
+		at    time.Time
+	}
+	var before time.Time
+	if q.Before != nil {
+		t, err := time.Parse(time.RFC3339Nano, q.Before.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		before = t
+	}
+
+	var matched []keyed
+	for _, e := range r.store.audit {
+		at, _ := time.Parse(time.RFC3339Nano, e.CreatedAt)
+		switch {
+		case q.ProjectID != "" && e.ProjectID != q.ProjectID,
+			q.EntityType != "" && e.EntityType != q.EntityType,
+			q.EntityID != "" && e.EntityID != q.EntityID,
+			q.ActorID != "" && e.ActorID != q.ActorID,
+			!q.Since.IsZero() && at.Before(q.Since),
+			!q.Until.IsZero() && !at.Before(q.Until):
+			continue
+		}
+		if q.Before != nil && (at.After(before) || at.Equal(before) && e.ID >= q.Before.ID) {
+			continue
+		}
+		matched = append(matched, keyed{entry: e, at: at})
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].at.Equal(matched[j].at) {
+			return matched[i].at.After(matched[j].at)
+		}
+		return matched[i].entry.ID > matched[j].entry.ID
+	})
+	entries := []AuditEntry{}
+	for _, m := range matched {
+		if q.Limit > 0 && len(entries) == q.Limit {
+			break
+		}
+		entries = append(entries, m.entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// taskEffort is a task's weight in milestone progress; unset counts as 1.
+func taskEffort(task Task) int {
+	if task.Effort < 1 {
+		return 1
+	}
+	return task.Effort
+}
+
+// taskDone reports whether a task's work was accepted: it is approved, or
+// was closed after approval.
+func taskDone(task Task) bool {
+	status := normalizeTaskStatus(task.Status)
+	return status == TaskApproved || (status == TaskClosed && task.Reviewed)
+}
+
+// taskRejected reports whether a task was closed without being approved.
+// Rejected work no longer counts towards its milestone.
+func taskRejected(task Task) bool {
+	return normalizeTaskStatus(task.Status) == TaskClosed && !task.Reviewed
+}
+
+// milestoneProgress computes effort-weighted progress over tasks.
+func milestoneProgress(tasks []Task) MilestoneProgress {
+	var p MilestoneProgress
+	for _, task := range tasks {
+		if taskRejected(task) {
+			continue
+		}
+		p.TotalTasks++
+		p.TotalEffort += taskEffort(task)
+		if taskDone(task) {
+			p.DoneTasks++
+			p.DoneEffort += taskEffort(task)
+		}
+	}
+	if p.TotalEffort > 0 {
+		p.Percent = math.Round(float64(p.DoneEffort)*1000/float64(p.TotalEffort)) / 10
+	}
+	return p
+}
+
+// withProgress returns the milestone with its derived progress attached.
+func withProgress(milestone Milestone) Milestone {
+	progress := milestoneProgress(milestone.Tasks)
+	milestone.Progress = &progress
+	return milestone
+}
+
+// derivedMilestoneStatus is the status a milestone's tasks imply:
+// completed once every counted task is done, in_progress once any work
+// has started, pending otherwise. It returns "" for a milestone with no
+// counted tasks, whose status is then left to the lead.
+func derivedMilestoneStatus(tasks []Task) string {
+	progress := milestoneProgress(tasks)
+	if progress.TotalTasks == 0 {
+		return ""
+	}
+	if progress.DoneTasks == progress.TotalTasks {
+		return "completed"
+	}
+	for _, task := range tasks {
+		if !taskRejected(task) && normalizeTaskStatus(task.Status) != TaskPending {
+			return "in_progress"
+		}
+	}
+	return "pending"
+}
+
+// syncMilestoneStatus updates a milestone's status to match its tasks.
+// It runs after task changes have been saved, so failures are logged
+// rather than failing the request that caused them.
+func (s *server) syncMilestoneStatus(ctx context.Context, milestoneID string) {
+	milestone, err := s.milestones.Get(ctx, milestoneID)
+	if err != nil {
+		fmt.Printf("Error fetching milestone for status sync: %v\n", err)
+		return
+	}
+
+	status := derivedMilestoneStatus(milestone.Tasks)
+	if status == "" || status == milestone.Status {
+		return
+	}
+
+	updated, err := s.milestones.Update(ctx, milestoneID, map[string]interface{}{
+		"status": status,
+	})
+	if err != nil {
+		fmt.Printf("Error updating milestone status: %v\n", err)
+		return
+	}
+	recordAudit(ctx, "milestone", milestoneID, milestone, updated)
+}
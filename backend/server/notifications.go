@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// List notifications endpoint
+func (s *server) handleListNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	unreadOnly := false
+	if raw := r.URL.Query().Get("unread"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, "unread must be true or false", http.StatusBadRequest)
+			return
+		}
+		unreadOnly = b
+	}
+	limit := defaultNotificationLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			writeError(w, fmt.Sprintf("limit must be between 1 and %d", maxNotificationLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	userId := currentUserID(r)
+	items, err := s.notifications.ListByUser(r.Context(), userId, unreadOnly, limit)
+	if err != nil {
+		fmt.Printf("Error fetching notifications: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	unread, err := s.notifications.CountUnread(r.Context(), userId)
+	if err != nil {
+		fmt.Printf("Error counting unread notifications: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	if items == nil {
+		items = []Notification{}
+	}
+	writeJSON(w, NotificationsResponse{Items: items, UnreadCount: unread})
+}
+
+// Mark notification read endpoint
+func (s *server) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	notificationId := mux.Vars(r)["notificationId"]
+
+	notification, err := s.notifications.MarkRead(r.Context(), currentUserID(r), notificationId)
+	if err != nil {
+		fmt.Printf("Error marking notification read: %v\n", err)
+		writeRepoError(w, err, "Notification not found")
+		return
+	}
+
+	writeJSON(w, notification)
+}
+
+// Mark all notifications read endpoint
+func (s *server) handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.notifications.MarkAllRead(r.Context(), currentUserID(r)); err != nil {
+		fmt.Printf("Error marking notifications read: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notificationPreferences returns the caller's preferences, defaulting the
+// email address to the one on their token.
+func (s *server) notificationPreferences(r *http.Request) (NotificationPreferences, error) {
+	user, _ := userFromContext(r.Context())
+	prefs, err := s.notificationPrefs.Get(r.Context(), user.ID)
+	if errors.Is(err, ErrNotFound) {
+		prefs = NotificationPreferences{UserID: user.ID}
+	} else if err != nil {
+		return NotificationPreferences{}, err
+	}
+	if prefs.Email == "" {
+		prefs.Email = user.Email
+	}
+	if prefs.MutedTypes == nil {
+		prefs.MutedTypes = []string{}
+	}
+	return prefs, nil
+}
+
+// Get notification preferences endpoint
+func (s *server) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prefs, err := s.notificationPreferences(r)
+	if err != nil {
+		fmt.Printf("Error fetching notification preferences: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, prefs)
+}
+
+// Update notification preferences endpoint
+func (s *server) handleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prefs, err := s.notificationPreferences(r)
+	if err != nil {
+		fmt.Printf("Error fetching notification preferences: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	before := prefs
+
+	// Fields left out of the body keep their current values
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	prefs.UserID = currentUserID(r)
+
+	if prefs.Email != "" {
+		addr, err := mail.ParseAddress(prefs.Email)
+		if err != nil {
+			writeError(w, "email must be a valid address", http.StatusBadRequest)
+			return
+		}
+		prefs.Email = addr.Address
+	}
+	if prefs.EmailEnabled {
+		if !s.notifier.HasChannel(ChannelEmail) {
+			writeError(w, "Email notifications are not configured on this server", http.StatusBadRequest)
+			return
+		}
+		if prefs.Email == "" {
+			writeError(w, "email is required to enable email notifications", http.StatusBadRequest)
+			return
+		}
+	}
+	if prefs.WebhookURL != "" {
+		if err := validateWebhookURL(r.Context(), prefs.WebhookURL, s.notifier.allowHTTPWebhooks); err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if prefs.WebhookEnabled && prefs.WebhookURL == "" {
+		writeError(w, "webhook_url is required to enable webhook notifications", http.StatusBadRequest)
+		return
+	}
+	if prefs.MutedTypes == nil {
+		prefs.MutedTypes = []string{}
+	}
+	for _, t := range prefs.MutedTypes {
+		if !contains(notifiableEvents, t) {
+			writeError(w, fmt.Sprintf("Unknown notification type %q", t), http.StatusBadRequest)
+			return
+		}
+	}
+
+	saved, err := s.notificationPrefs.Save(r.Context(), prefs)
+	if err != nil {
+		fmt.Printf("Error saving notification preferences: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	recordAudit(r.Context(), "notification_preferences", saved.UserID, before, saved)
+
+	writeJSON(w, saved)
+}
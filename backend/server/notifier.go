@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"imara-shared/config"
+)
+
+// Notification channel names, as used in NotificationPreferences.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// notifiableEvents are the event types users can be notified about and
+// may mute.
+var notifiableEvents = []string{
+	EventTaskAssigned,
+	EventTaskStatusChanged,
+	EventTaskReviewed,
+	EventEvidenceUploaded,
+	EventTaskOverdue,
+	EventMilestoneOverdue,
+}
+
+// NotificationChannel delivers a notification outside the app.
+type NotificationChannel interface {
+	Send(ctx context.Context, prefs NotificationPreferences, n Notification) error
+}
+
+// Notifier turns domain events into notifications. Events are queued by
+// the publishing request and delivered by a background worker, so a slow
+// mail server never holds up the API.
+type Notifier struct {
+	notifications NotificationRepository
+	prefs         NotificationPreferenceRepository
+	members       MembershipRepository
+	channels      map[string]NotificationChannel
+	queue         chan DomainEvent
+
+	// allowHTTPWebhooks accepts plain http webhook URLs, for local
+	// development.
+	allowHTTPWebhooks bool
+}
+
+// NewNotifier returns a notifier delivering through channels, keyed by
+// channel name. Channels that are not configured are left out.
+func NewNotifier(repos Repositories, channels map[string]NotificationChannel) *Notifier {
+	return &Notifier{
+		notifications: repos.Notifications,
+		prefs:         repos.NotificationPrefs,
+		members:       repos.Members,
+		channels:      channels,
+		queue:         make(chan DomainEvent, 256),
+	}
+}
+
+// HasChannel reports whether the named channel is configured.
+func (n *Notifier) HasChannel(name string) bool {
+	_, ok := n.channels[name]
+	return ok
+}
+
+// Enqueue queues an event for delivery. It is an EventHandler. When the
+// queue is full the event is dropped rather than blocking the request.
+func (n *Notifier) Enqueue(ctx context.Context, event DomainEvent) {
+	select {
+	case n.queue <- event:
+	default:
+		fmt.Printf("Error queueing notifications for event %s: queue is full\n", event.ID)
+	}
+}
+
+// Run delivers queued events until ctx is done.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.queue:
+			if err := n.deliver(ctx, event); err != nil {
+				fmt.Printf("Error delivering notifications for event %s: %v\n", event.ID, err)
+			}
+		}
+	}
+}
+
+// deliver notifies everyone the event concerns except the user who
+// caused it.
+func (n *Notifier) deliver(ctx context.Context, event DomainEvent) error {
+	recipients, err := n.recipients(ctx, event)
+	if err != nil {
+		return err
+	}
+	title, body := describeEvent(event)
+
+	for _, userID := range recipients {
+		prefs, err := n.prefs.Get(ctx, userID)
+		if errors.Is(err, ErrNotFound) {
+			prefs = NotificationPreferences{UserID: userID}
+		} else if err != nil {
+			return err
+		}
+		if contains(prefs.MutedTypes, event.Type) {
+			continue
+		}
+
+		created, err := n.notifications.Create(ctx, Notification{
+			UserID:      userID,
+			ProjectID:   event.ProjectID,
+			Type:        event.Type,
+			Title:       title,
+			Body:        body,
+			MilestoneID: event.MilestoneID,
+			TaskID:      event.TaskID,
+			EventID:     event.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// A failing channel is logged; the inbox entry already exists
+		for name, enabled := range map[string]bool{ChannelEmail: prefs.EmailEnabled, ChannelWebhook: prefs.WebhookEnabled} {
+			channel, ok := n.channels[name]
+			if !ok || !enabled {
+				continue
+			}
+			if err := channel.Send(ctx, prefs, created); err != nil {
+				fmt.Printf("Error sending %s notification to %s: %v\n", name, userID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// recipients lists who an event concerns: assignees hear about their
+// tasks, and the project lead about work waiting for them.
+func (n *Notifier) recipients(ctx context.Context, event DomainEvent) ([]string, error) {
+	assignee, _ := event.Data["assignee_id"].(string)
+
+	var users []string
+	lead := false
+	switch event.Type {
+	case EventTaskAssigned, EventTaskReviewed:
+		users = append(users, assignee)
+	case EventTaskStatusChanged:
+		if to, _ := event.Data["to"].(string); to == TaskSubmitted {
+			lead = true
+		}
+	case EventEvidenceUploaded, EventMilestoneOverdue:
+		lead = true
+	case EventTaskOverdue:
+		users = append(users, assignee)
+		lead = true
+	}
+	if lead {
+		leadID, err := n.members.ProjectLead(ctx, event.ProjectID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		users = append(users, leadID)
+	}
+
+	var recipients []string
+	for _, userID := range users {
+		if userID == "" || userID == event.ActorID || contains(recipients, userID) {
+			continue
+		}
+		recipients = append(recipients, userID)
+	}
+	return recipients, nil
+}
+
+// describeEvent writes the notification title and body for an event.
+func describeEvent(event DomainEvent) (string, string) {
+	title, _ := event.Data["title"].(string)
+	switch event.Type {
+	case EventTaskAssigned:
+		return "Task assigned to you", fmt.Sprintf("You have been assigned %q.", title)
+	case EventTaskStatusChanged:
+		to, _ := event.Data["to"].(string)
+		if to == TaskSubmitted {
+			return "Task submitted for review", fmt.Sprintf("%q is waiting for your review.", title)
+		}
+		return "Task status changed", fmt.Sprintf("%q is now %s.", title, to)
+	case EventTaskReviewed:
+		verdict, _ := event.Data["verdict"].(string)
+		outcome := map[string]string{
+			VerdictApprove:        "approved",
+			VerdictRequestChanges: "changes requested",
+			VerdictReject:         "rejected",
+		}[verdict]
+		body := fmt.Sprintf("Your submission of %q was reviewed: %s.", title, outcome)
+		if comments, _ := event.Data["comments"].(string); comments != "" {
+			body += "\n\n" + comments
+		}
+		return "Task reviewed", body
+	case EventEvidenceUploaded:
+		fileName, _ := event.Data["file_name"].(string)
+		if fileName == "" {
+			return "Evidence added", fmt.Sprintf("New evidence was added to %q.", title)
+		}
+		return "Evidence added", fmt.Sprintf("%s was added to %q.", fileName, title)
+	case EventTaskOverdue:
+		return "Task overdue", fmt.Sprintf("%q is past its due date.", title)
+	case EventMilestoneOverdue:
+		return "Milestone overdue", fmt.Sprintf("Milestone %q is past its due date with work still open.", title)
+	default:
+		return event.Type, title
+	}
+}
+
+// SMTPChannel emails notifications through an SMTP server.
+type SMTPChannel struct {
+	addr string
+	auth smtp.Auth
+	from string
+	// sender is the bare address in from, used as the envelope sender.
+	sender string
+}
+
+// NewSMTPChannel returns a channel sending from the given address via the
+// server at host:port. from may include a display name, as in
+// "Imara <no-reply@imarahub.xyz>". Authentication is skipped when username
+// is empty, as for a local relay.
+func NewSMTPChannel(host string, port int, username, password, from string) *SMTPChannel {
+	c := &SMTPChannel{addr: fmt.Sprintf("%s:%d", host, port), from: from, sender: from}
+	if addr, err := mail.ParseAddress(from); err == nil {
+		c.sender = addr.Address
+	}
+	if username != "" {
+		c.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return c
+}
+
+func (c *SMTPChannel) Send(ctx context.Context, prefs NotificationPreferences, n Notification) error {
+	if prefs.Email == "" {
+		return fmt.Errorf("no email address")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", headerValue(c.from))
+	fmt.Fprintf(&msg, "To: %s\r\n", headerValue(prefs.Email))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(n.Title)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return smtp.SendMail(c.addr, c.auth, c.sender, []string{prefs.Email}, msg.Bytes())
+}
+
+// headerValue strips line breaks so a value cannot add mail headers.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+}
+
+// WebhookChannel posts notifications as JSON to the user's webhook URL.
+type WebhookChannel struct {
+	client *http.Client
+}
+
+func NewWebhookChannel() *WebhookChannel {
+	return &WebhookChannel{client: newWebhookClient()}
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, prefs NotificationPreferences, n Notification) error {
+	if prefs.WebhookURL == "" {
+		return fmt.Errorf("no webhook URL")
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, prefs.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// validateWebhookURL checks a user's webhook URL. Plain http is only
+// accepted when allowHTTP is set, for local development. The host must
+// resolve to public addresses only, so a webhook cannot be aimed at the
+// server's own network.
+func validateWebhookURL(ctx context.Context, raw string, allowHTTP bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an absolute URL")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && allowHTTP:
+	default:
+		return fmt.Errorf("webhook_url must use https")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("webhook_url host cannot be resolved")
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return errPrivateWebhookAddress
+		}
+	}
+	return nil
+}
+
+var errPrivateWebhookAddress = errors.New("webhook_url must not point to a private or local address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which net.IP does
+// not count as private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is a unicast address outside the loopback,
+// private, link-local and unspecified ranges.
+func publicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if ip4[0] == 0 || sharedAddressSpace.Contains(ip4) || ip4.Equal(net.IPv4bcast) {
+			return false
+		}
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// newWebhookClient returns the client for users' webhook URLs. The
+// address is checked again when dialling, so a host that resolves
+// differently after validateWebhookURL still cannot reach the server's
+// network, and redirects are not followed.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errPrivateWebhookAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// newNotifierFromConfig builds a notifier with the webhook channel and,
+// when SMTP is configured, the email channel.
+func newNotifierFromConfig(repos Repositories, cfg config.Notifications) *Notifier {
+	channels := map[string]NotificationChannel{ChannelWebhook: NewWebhookChannel()}
+	if smtp := cfg.SMTP; smtp.Host != "" {
+		channels[ChannelEmail] = NewSMTPChannel(smtp.Host, smtp.Port, smtp.Username, smtp.Password, smtp.From)
+	}
+	notifier := NewNotifier(repos, channels)
+	notifier.allowHTTPWebhooks = cfg.AllowHTTPWebhooks
+	return notifier
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// smtpSession is what fakeSMTP recorded for one delivered message.
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP runs a minimal SMTP server on l that offers AUTH PLAIN and
+// sends each delivered message to sessions.
+func fakeSMTP(t *testing.T, l net.Listener) <-chan smtpSession {
+	t.Helper()
+	sessions := make(chan smtpSession, 4)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, sessions)
+		}
+	}()
+	return sessions
+}
+
+func serveSMTP(conn net.Conn, sessions chan<- smtpSession) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+
+	var s smtpSession
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			tp.PrintfLine("250-fake\r\n250-8BITMIME\r\n250 AUTH PLAIN")
+		case "AUTH":
+			creds, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			s.auth = string(creds)
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:"), " ")
+			s.from = strings.TrimSuffix(strings.TrimPrefix(from, "<"), ">")
+			tp.PrintfLine("250 2.1.0 Ok")
+		case "RCPT":
+			s.to = append(s.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 2.1.5 Ok")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 2.0.0 Ok: queued")
+			sessions <- s
+		case "QUIT":
+			tp.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			tp.PrintfLine("502 5.5.2 Error: command not recognized")
+		}
+	}
+}
+
+func TestSMTPChannel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := fakeSMTP(t, l)
+	port := l.Addr().(*net.TCPAddr).Port
+
+	// PLAIN auth is only sent without TLS to localhost.
+	channel := NewSMTPChannel("localhost", port, "mailer", "hunter2", "Imara <no-reply@imarahub.xyz>")
+	prefs := NotificationPreferences{Email: "ada@example.com"}
+	n := Notification{Title: "Task reviewed: ✓\r\nBcc: eve@example.com", Body: "Line one\nLine two"}
+	if err := channel.Send(context.Background(), prefs, n); err != nil {
+		t.Fatal(err)
+	}
+
+	s := <-sessions
+	if s.auth != "\x00mailer\x00hunter2" {
+		t.Errorf("auth = %q", s.auth)
+	}
+	if s.from != "no-reply@imarahub.xyz" {
+		t.Errorf("envelope sender = %q", s.from)
+	}
+	if len(s.to) != 1 || s.to[0] != "ada@example.com" {
+		t.Errorf("recipients = %v", s.to)
+	}
+	// The dot reader has turned CRLF line endings into LF.
+	header, body, _ := strings.Cut(s.data, "\n\n")
+	if strings.Contains(header, "\nBcc:") {
+		t.Errorf("title added a header:\n%s", header)
+	}
+	for _, want := range []string{"From: Imara <no-reply@imarahub.xyz>", "To: ada@example.com", "Subject: =?utf-8?q?", "Content-Type: text/plain; charset=utf-8"} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header lacks %q:\n%s", want, header)
+		}
+	}
+	if body != "Line one\nLine two\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSMTPChannelWithoutAuth(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := fakeSMTP(t, l)
+
+	channel := NewSMTPChannel("127.0.0.1", l.Addr().(*net.TCPAddr).Port, "", "", "no-reply@imarahub.xyz")
+	if err := channel.Send(context.Background(), NotificationPreferences{Email: "ada@example.com"}, Notification{Title: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := <-sessions; s.auth != "" || s.from != "no-reply@imarahub.xyz" {
+		t.Errorf("session = %+v", s)
+	}
+
+	if err := channel.Send(context.Background(), NotificationPreferences{}, Notification{Title: "Hi"}); err == nil {
+		t.Error("sent without an email address")
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	ctx := context.Background()
+	cases := map[string]struct {
+		allowHTTP bool
+		ok        bool
+	}{
+		"https://93.184.216.34/hook":             {ok: true},
+		"http://93.184.216.34/hook":              {},
+		"http://93.184.216.34/hook?dev":          {allowHTTP: true, ok: true},
+		"/hook":                                  {},
+		"https://127.0.0.1/hook":                 {},
+		"https://[::1]/hook":                     {},
+		"https://localhost/hook":                 {},
+		"https://10.1.2.3/hook":                  {},
+		"https://172.16.0.1/hook":                {},
+		"https://192.168.1.1/hook":               {},
+		"https://100.64.0.1/hook":                {},
+		"https://169.254.169.254/latest":         {},
+		"https://[fe80::1]/hook":                 {},
+		"https://[fd00::1]/hook":                 {},
+		"https://0.0.0.0/hook":                   {},
+		"https://[::ffff:127.0.0.1]/hook":        {},
+		"http://127.0.0.1:8080/hook?allow_local": {allowHTTP: true},
+	}
+	for raw, c := range cases {
+		err := validateWebhookURL(ctx, raw, c.allowHTTP)
+		if (err == nil) != c.ok {
+			t.Errorf("validateWebhookURL(%q) = %v", raw, err)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("webhook reached a loopback address")
+	}))
+	defer target.Close()
+
+	_, err := newWebhookClient().Post(target.URL, "application/json", nil)
+	if !errors.Is(err, errPrivateWebhookAddress) {
+		t.Fatalf("posting to %s: %v", target.URL, err)
+	}
+}
+
+func TestWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect was followed")
+	}))
+	defer internal.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer receiver.Close()
+
+	// Dial loopback for the test, keeping the redirect policy
+	client := newWebhookClient()
+	client.Transport = http.DefaultTransport
+	resp, err := client.Post(receiver.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"imara-shared/apierror"
+)
+
+// openAPIJSON documents the API. Its paths use the same templates as the
+// router so requests can be matched to their operation.
+//
+//go:embed openapi.json
+var openAPIJSON []byte
+
+// maxJSONBody caps the JSON request bodies that are validated.
+const maxJSONBody = 1 << 20
+
+// openAPIDocument is the part of an OpenAPI 3 document used to validate
+// requests.
+type openAPIDocument struct {
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// schema is the subset of the OpenAPI schema object the validator
+// understands.
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Nullable   bool               `json:"nullable"`
+	Enum       []interface{}      `json:"enum"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+	AllOf      []*schema          `json:"allOf"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	MinItems   *int               `json:"minItems"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+}
+
+var openAPI = mustParseOpenAPI(openAPIJSON)
+
+func mustParseOpenAPI(raw []byte) *openAPIDocument {
+	var doc openAPIDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		panic(fmt.Sprintf("invalid openapi.json: %v", err))
+	}
+	return &doc
+}
+
+// requestSchema returns the JSON body schema of the operation at a route
+// template, or nil if it takes none.
+func (d *openAPIDocument) requestSchema(template, method string) *schema {
+	op, ok := d.Paths[template][strings.ToLower(method)]
+	if !ok || op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+// resolve follows a "#/components/schemas/Name" reference.
+func (d *openAPIDocument) resolve(s *schema) *schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// validate checks value, decoded with UseNumber, against s and returns a
+// FieldError for every problem found.
+func (d *openAPIDocument) validate(s *schema, value interface{}, field string) []apierror.FieldError {
+	s = d.resolve(s)
+	if s == nil {
+		return nil
+	}
+	var errs []apierror.FieldError
+	fail := func(format string, args ...interface{}) []apierror.FieldError {
+		name := field
+		if name == "" {
+			name = "body"
+		}
+		return append(errs, apierror.FieldError{Field: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, sub := range s.AllOf {
+		errs = append(errs, d.validate(sub, value, field)...)
+	}
+	if value == nil {
+		if s.Type != "" && !s.Nullable {
+			return fail("must not be null")
+		}
+		return errs
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, apierror.FieldError{Field: joinField(field, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			// encoding/json leaves fields alone for null, so null stands
+			// for an optional field that was left out
+			if v, ok := obj[name]; ok && (v != nil || contains(s.Required, name)) {
+				errs = append(errs, d.validate(s.Properties[name], v, joinField(field, name))...)
+			}
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.MinItems != nil && len(list) < *s.MinItems {
+			errs = fail("must have at least %d items", *s.MinItems)
+		}
+		for i, item := range list {
+			errs = append(errs, d.validate(s.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		n := len([]rune(str))
+		switch {
+		case s.MinLength != nil && n < *s.MinLength:
+			if *s.MinLength == 1 {
+				return fail("must not be empty")
+			}
+			return fail("must be at least %d characters", *s.MinLength)
+		case s.MaxLength != nil && n > *s.MaxLength:
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+		if msg := checkFormat(s.Format, str); msg != "" {
+			return fail("%s", msg)
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fail("must be %s", map[string]string{"integer": "an integer", "number": "a number"}[s.Type])
+		}
+		if _, err := num.Int64(); s.Type == "integer" && err != nil {
+			return fail("must be an integer")
+		}
+		f, _ := num.Float64()
+		switch {
+		case s.Minimum != nil && f < *s.Minimum:
+			return fail("must be at least %v", *s.Minimum)
+		case s.Maximum != nil && f > *s.Maximum:
+			return fail("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = fmt.Sprint(e)
+		}
+		if !contains(allowed, fmt.Sprint(value)) {
+			return fail("must be one of: %s", strings.Join(allowed, ", "))
+		}
+	}
+	return errs
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// checkFormat describes why str does not match format. Empty strings
+// pass: they mean "unset" for dates and are checked by the handlers
+// where a value is needed.
+func checkFormat(format, str string) string {
+	if str == "" {
+		return ""
+	}
+	switch format {
+	case "date":
+		if _, err := ParseDate(str); err != nil {
+			return "must be a date (YYYY-MM-DD) or an RFC 3339 timestamp with a time zone"
+		}
+	case "uri":
+		u, err := url.Parse(strings.TrimSpace(str))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+	}
+	return ""
+}
+
+// validateRequests checks JSON request bodies against the OpenAPI
+// document before they reach the handlers, reporting every invalid field
+// at once. Fields the document does not list are left to the handlers:
+// older clients send extras such as a task's milestone_id.
+func validateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := openAPI.requestSchema(template, r.Method)
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); body == nil || strings.HasPrefix(mediaType, "multipart/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, fmt.Sprintf("Request body exceeds %d bytes", maxJSONBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+			writeBodyError(w, err)
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+
+		if errs := openAPI.validate(body, value, ""); len(errs) > 0 {
+			msgs := make([]string, len(errs))
+			for i, e := range errs {
+				msgs[i] = e.Field + " " + e.Message
+			}
+			apierror.Write(w, apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, strings.Join(msgs, "; ")).WithDetails(errs))
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OpenAPI document endpoint
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIJSON)
+}
+
+// apiDocsPage renders the OpenAPI document with ReDoc.
+const apiDocsPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Imara API</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+<redoc spec-url="/api/openapi.json"></redoc>
+<script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// API docs endpoint
+func handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, apiDocsPage)
+}
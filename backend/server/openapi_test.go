@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"imara-shared/apierror"
+)
+
+// validationErrors sends body as user and returns the field errors of
+// the validation_failed response.
+func (api *testAPI) validationErrors(t *testing.T, user, method, path string, body interface{}) []apierror.FieldError {
+	t.Helper()
+	var envelope struct {
+		Code    string                `json:"code"`
+		Details []apierror.FieldError `json:"details"`
+	}
+	resp := api.do(t, user, method, path, body, &envelope)
+	if resp.StatusCode != http.StatusBadRequest || envelope.Code != apierror.CodeValidationFailed {
+		t.Fatalf("%s %s = %d %q, want 400 validation_failed", method, path, resp.StatusCode, envelope.Code)
+	}
+	return envelope.Details
+}
+
+func TestValidateRequestsReportsFields(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead")
+
+	got := api.validationErrors(t, "lead", "POST", "/api/milestones", map[string]interface{}{})
+	want := []apierror.FieldError{
+		{Field: "project_id", Message: "is required"},
+		{Field: "title", Message: "is required"},
+		{Field: "due_date", Message: "is required"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("missing fields: %+v\nwant %+v", got, want)
+	}
+
+	got = api.validationErrors(t, "lead", "POST", "/api/milestones", map[string]interface{}{
+		"project_id": "p1",
+		"title":      42,
+		"due_date":   "someday",
+	})
+	want = []apierror.FieldError{
+		{Field: "due_date", Message: "must be a date (YYYY-MM-DD) or an RFC 3339 timestamp with a time zone"},
+		{Field: "title", Message: "must be a string"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong types: %+v\nwant %+v", got, want)
+	}
+
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	got = api.validationErrors(t, "lead", "POST", "/api/milestones/"+milestone.ID+"/tasks", []string{"Spec"})
+	if len(got) != 1 || got[0].Field != "body" {
+		t.Errorf("array body: %+v", got)
+	}
+}
+
+func TestValidateRequestsLeavesUnknownFieldsToHandlers(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+
+	// Older clients repeat the milestone in the body
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "milestone_id": milestone.ID})
+
+	// Task updates list the fields they accept themselves
+	var envelope struct {
+		Code    string      `json:"code"`
+		Message string      `json:"message"`
+		Details interface{} `json:"details"`
+	}
+	resp := api.do(t, "lead", "PUT", "/api/tasks/"+task.ID, map[string]interface{}{"colour": "red"}, &envelope)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(envelope.Message, `"colour"`) || envelope.Details != nil {
+		t.Errorf("unknown field: %d %+v", resp.StatusCode, envelope)
+	}
+}
+
+func TestValidateRequestsPassesUnlistedRoutes(t *testing.T) {
+	var got string
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+	}
+	router := mux.NewRouter()
+	router.Use(validateRequests)
+	router.HandleFunc("/api/unlisted", echo).Methods("POST")
+	router.HandleFunc("/api/milestones", echo).Methods("POST", "OPTIONS")
+
+	cases := []struct {
+		method, path, contentType, body string
+	}{
+		{"POST", "/api/unlisted", "application/json", `{"title":42}`},
+		{"POST", "/api/unlisted", "application/json", `not json`},
+		{"OPTIONS", "/api/milestones", "application/json", `{"title":42}`},
+		{"POST", "/api/milestones", "multipart/form-data; boundary=x", `--x--`},
+	}
+	for _, c := range cases {
+		got = ""
+		req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || got != c.body {
+			t.Errorf("%s %s: status %d, handler read %q", c.method, c.path, w.Code, got)
+		}
+	}
+
+	// A listed route validates, and the handler still reads the body
+	req := httptest.NewRequest("POST", "/api/milestones", strings.NewReader(`{"project_id":"p1","title":"Alpha","due_date":"2030-01-31"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(got, `"Alpha"`) {
+		t.Errorf("listed route: status %d, handler read %q", w.Code, got)
+	}
+}
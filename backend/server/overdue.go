@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// taskOpen reports whether work on a task is still expected.
+func taskOpen(task Task) bool {
+	status := normalizeTaskStatus(task.Status)
+	return status != TaskApproved && status != TaskClosed
+}
+
+// taskOverdue reports whether an open task's due date has passed.
+func taskOverdue(task Task, now time.Time) bool {
+	return taskOpen(task) && !task.DueDate.IsZero() && task.DueDate.end().Before(now)
+}
+
+// milestoneOverdue reports whether an unfinished milestone's due date has
+// passed.
+func milestoneOverdue(milestone Milestone, now time.Time) bool {
+	return milestone.Status != "completed" && !milestone.DueDate.IsZero() && milestone.DueDate.end().Before(now)
+}
+
+// sweepOverdue marks tasks and milestones that have become overdue,
+// publishing an event for each, and clears the marker from those that
+// were completed or rescheduled since.
+func (s *server) sweepOverdue(ctx context.Context) error {
+	current := time.Now()
+	stamp := current.UTC().Format(time.RFC3339Nano)
+
+	milestones, err := s.milestones.ListOverdueCandidates(ctx, current)
+	if err != nil {
+		return fmt.Errorf("listing overdue milestones: %w", err)
+	}
+	for _, milestone := range milestones {
+		overdue := milestoneOverdue(milestone, current)
+		switch {
+		case overdue && milestone.OverdueSince == "":
+			if _, err := s.milestones.Update(ctx, milestone.ID, map[string]interface{}{"overdue_since": stamp}); err != nil {
+				return fmt.Errorf("marking milestone %s overdue: %w", milestone.ID, err)
+			}
+			s.events.Publish(ctx, DomainEvent{
+				Type:        EventMilestoneOverdue,
+				ProjectID:   milestone.ProjectID,
+				MilestoneID: milestone.ID,
+				Data:        map[string]interface{}{"title": milestone.Title, "due_date": milestone.DueDate},
+			})
+		case !overdue && milestone.OverdueSince != "":
+			if _, err := s.milestones.Update(ctx, milestone.ID, map[string]interface{}{"overdue_since": nil}); err != nil {
+				return fmt.Errorf("clearing milestone %s overdue marker: %w", milestone.ID, err)
+			}
+		}
+	}
+
+	tasks, err := s.tasks.ListOverdueCandidates(ctx, current)
+	if err != nil {
+		return fmt.Errorf("listing overdue tasks: %w", err)
+	}
+	projects := map[string]string{}
+	for _, task := range tasks {
+		overdue := taskOverdue(task, current)
+		switch {
+		case overdue && task.OverdueSince == "":
+			projectID, ok := projects[task.MilestoneID]
+			if !ok {
+				milestone, err := s.milestones.Get(ctx, task.MilestoneID)
+				if err != nil {
+					return fmt.Errorf("fetching milestone of task %s: %w", task.ID, err)
+				}
+				projectID = milestone.ProjectID
+				projects[task.MilestoneID] = projectID
+			}
+			if _, err := s.tasks.Update(ctx, task.ID, map[string]interface{}{"overdue_since": stamp}); err != nil {
+				return fmt.Errorf("marking task %s overdue: %w", task.ID, err)
+			}
+			s.events.Publish(ctx, DomainEvent{
+				Type:        EventTaskOverdue,
+				ProjectID:   projectID,
+				MilestoneID: task.MilestoneID,
+				TaskID:      task.ID,
+				Data: map[string]interface{}{
+					"title":       task.Title,
+					"due_date":    task.DueDate,
+					"assignee_id": task.AssigneeID,
+				},
+			})
+		case !overdue && task.OverdueSince != "":
+			if _, err := s.tasks.Update(ctx, task.ID, map[string]interface{}{"overdue_since": nil}); err != nil {
+				return fmt.Errorf("clearing task %s overdue marker: %w", task.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// createPhase appends a phase to the end of its project's timeline.
+func (s *server) createPhase(ctx context.Context, phase Timeline) (Timeline, error) {
+	phases, err := s.timelines.ListByProject(ctx, phase.ProjectID)
+	if err != nil {
+		return Timeline{}, err
+	}
+	phase.Position = len(phases)
+	if phase.Name == "" {
+		phase.Name = fmt.Sprintf("Phase %d", len(phases)+1)
+	}
+	created, err := s.timelines.Create(ctx, phase)
+	if err != nil {
+		return Timeline{}, err
+	}
+	recordAudit(ctx, "timeline", created.ID, nil, created)
+	return created, nil
+}
+
+// validatePhase checks that phaseID names a phase of projectID.
+func (s *server) validatePhase(w http.ResponseWriter, r *http.Request, projectID, phaseID string) bool {
+	phase, err := s.timelines.Get(r.Context(), phaseID)
+	if err != nil {
+		fmt.Printf("Error fetching phase: %v\n", err)
+		writeRepoError(w, err, "Phase not found")
+		return false
+	}
+	if phase.ProjectID != projectID {
+		writeError(w, "Phase belongs to a different project", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// List timeline phases endpoint
+func (s *server) handleListPhases(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	if _, ok := s.authorizeProject(w, r, projectId, anyMember); !ok {
+		return
+	}
+
+	phases, err := s.timelines.ListByProject(r.Context(), projectId)
+	if err != nil {
+		fmt.Printf("Error fetching phases: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, phases)
+}
+
+// Create timeline phase endpoint
+func (s *server) handleCreatePhase(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	var phase Timeline
+	if err := json.NewDecoder(r.Body).Decode(&phase); err != nil {
+		fmt.Printf("Error decoding phase request: %v\n", err)
+		writeBodyError(w, err)
+		return
+	}
+	phase.ProjectID = projectId
+
+	if phase.Name == "" {
+		writeError(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateDateRange(phase.StartDate, phase.EndDate); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := s.authorizeProject(w, r, projectId, leadOnly); !ok {
+		return
+	}
+
+	created, err := s.createPhase(r.Context(), phase)
+	if err != nil {
+		fmt.Printf("Error inserting phase: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, created)
+}
+
+// Reorder timeline phases endpoint. The request must list every phase of
+// the project exactly once.
+func (s *server) handleReorderPhases(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	var req ReorderPhasesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	if _, ok := s.authorizeProject(w, r, projectId, leadOnly); !ok {
+		return
+	}
+
+	phases, err := s.timelines.ListByProject(r.Context(), projectId)
+	if err != nil {
+		fmt.Printf("Error fetching phases: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	existing := make(map[string]bool, len(phases))
+	for _, phase := range phases {
+		existing[phase.ID] = true
+	}
+	seen := make(map[string]bool, len(req.PhaseIDs))
+	for _, id := range req.PhaseIDs {
+		if !existing[id] {
+			writeError(w, fmt.Sprintf("Phase %s does not belong to this project", id), http.StatusBadRequest)
+			return
+		}
+		if seen[id] {
+			writeError(w, fmt.Sprintf("Phase %s is listed more than once", id), http.StatusBadRequest)
+			return
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(existing) {
+		writeError(w, "phase_ids must list every phase of the project", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.timelines.Reorder(r.Context(), projectId, req.PhaseIDs); err != nil {
+		fmt.Printf("Error reordering phases: %v\n", err)
+		writeRepoError(w, err, "Phase not found")
+		return
+	}
+
+	reordered, err := s.timelines.ListByProject(r.Context(), projectId)
+	if err != nil {
+		fmt.Printf("Error fetching phases: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	for i, phase := range phases {
+		for _, after := range reordered {
+			if after.ID == phase.ID {
+				recordAudit(r.Context(), "timeline", phase.ID, phases[i], after)
+			}
+		}
+	}
+	phases = reordered
+
+	writeJSON(w, phases)
+}
+
+// Delete timeline phase endpoint. Milestones in the phase are kept and
+// left unassigned; the remaining phases close the gap in the ordering.
+func (s *server) handleDeletePhase(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	phaseId := mux.Vars(r)["phaseId"]
+
+	phase, err := s.timelines.Get(r.Context(), phaseId)
+	if err != nil {
+		fmt.Printf("Error fetching phase: %v\n", err)
+		writeRepoError(w, err, "Phase not found")
+		return
+	}
+	if _, ok := s.authorizeProject(w, r, phase.ProjectID, leadOnly); !ok {
+		return
+	}
+
+	if err := s.timelines.Delete(r.Context(), phaseId); err != nil {
+		fmt.Printf("Error deleting phase: %v\n", err)
+		writeRepoError(w, err, "Phase not found")
+		return
+	}
+	recordAudit(r.Context(), "timeline", phaseId, phase, nil)
+
+	remaining, err := s.timelines.ListByProject(r.Context(), phase.ProjectID)
+	if err == nil {
+		ids := make([]string, len(remaining))
+		for i, p := range remaining {
+			ids[i] = p.ID
+		}
+		err = s.timelines.Reorder(r.Context(), phase.ProjectID, ids)
+	}
+	if err != nil {
+		// The phase is gone; a gap in positions does not change the order.
+		fmt.Printf("Error compacting phase positions: %v\n", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
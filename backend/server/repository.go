@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when the requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned by conditional updates when the record no longer
+// matches the expected state.
+var ErrConflict = errors.New("record was modified concurrently")
+
+// ErrQuotaExceeded is returned when file evidence would take a project past
+// its storage quota.
+var ErrQuotaExceeded = errors.New("project storage quota exceeded")
+
+// TimelineRepository persists project timeline phases.
+type TimelineRepository interface {
+	Create(ctx context.Context, timeline Timeline) (Timeline, error)
+	Get(ctx context.Context, id string) (Timeline, error)
+	// ListByProject returns a project's phases ordered by position.
+	ListByProject(ctx context.Context, projectID string) ([]Timeline, error)
+	Update(ctx context.Context, id string, timeline Timeline) (Timeline, error)
+	// Reorder sets each phase's position to its index in ids.
+	Reorder(ctx context.Context, projectID string, ids []string) error
+	// Delete removes a phase, unassigning its milestones.
+	Delete(ctx context.Context, id string) error
+}
+
+// MilestoneRepository persists milestones. Milestones returned by
+// ListByProject and Get carry their tasks in Milestone.Tasks.
+type MilestoneRepository interface {
+	Create(ctx context.Context, milestone Milestone) (Milestone, error)
+	Get(ctx context.Context, id string) (Milestone, error)
+	ListByProject(ctx context.Context, projectID string) ([]Milestone, error)
+	// List returns the project's milestones matching q, in q's order.
+	List(ctx context.Context, projectID string, q ListQuery) ([]Milestone, error)
+	// ListOverdueCandidates returns, without their tasks, the milestones
+	// past due at now that are not completed, and those already marked
+	// overdue.
+	ListOverdueCandidates(ctx context.Context, now time.Time) ([]Milestone, error)
+	Update(ctx context.Context, id string, updates map[string]interface{}) (Milestone, error)
+	// Delete removes a milestone and, in the same transaction, deals with
+	// its tasks as onTasks says: onTasksBlock fails with ErrConflict while
+	// it has any, onTasksCascade deletes them and onTasksMove moves them to
+	// targetID, which must be another milestone of the same project.
+	Delete(ctx context.Context, id, onTasks, targetID string) error
+}
+
+// TaskRepository persists milestone tasks.
+type TaskRepository interface {
+	Create(ctx context.Context, task Task) (Task, error)
+	Get(ctx context.Context, id string) (Task, error)
+	ListByMilestone(ctx context.Context, milestoneID string) ([]Task, error)
+	// List returns the milestone's tasks matching q, in q's order.
+	List(ctx context.Context, milestoneID string, q ListQuery) ([]Task, error)
+	// ListByAssignee returns every task assigned to a user across
+	// projects, ordered by due date with undated tasks last.
+	ListByAssignee(ctx context.Context, assigneeID string) ([]AssignedTask, error)
+	// ListOverdueCandidates returns the open tasks past due at now and
+	// those already marked overdue.
+	ListOverdueCandidates(ctx context.Context, now time.Time) ([]Task, error)
+	Update(ctx context.Context, id string, updates map[string]interface{}) (Task, error)
+	// UpdateIfStatus applies updates only while the task is still in the
+	// given status, returning ErrConflict otherwise.
+	UpdateIfStatus(ctx context.Context, id, status string, updates map[string]interface{}) (Task, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// TaskHistoryRepository persists the append-only log of task status transitions.
+type TaskHistoryRepository interface {
+	Append(ctx context.Context, transition TaskTransition) (TaskTransition, error)
+	ListByTask(ctx context.Context, taskID string) ([]TaskTransition, error)
+}
+
+// ReviewRepository persists task reviews.
+type ReviewRepository interface {
+	Create(ctx context.Context, review TaskReview) (TaskReview, error)
+	ListByTask(ctx context.Context, taskID string) ([]TaskReview, error)
+	// Delete removes a review whose verdict could not be applied.
+	Delete(ctx context.Context, id string) error
+}
+
+// EvidenceRepository persists the evidence items submitted for tasks and
+// tracks the stored files they refer to. Stored files are shared by every
+// item with the same SHA-256, so they are only deleted by the evidence
+// collector: an upload claims its file before storing it, and the
+// collector only takes files that have gone unclaimed and unreferenced
+// for a grace period.
+type EvidenceRepository interface {
+	// Create records an item. File evidence must refer to a claimed file;
+	// it fails with ErrConflict if the file is being collected and with
+	// ErrQuotaExceeded if it would take the project's files past
+	// quotaBytes. Links and text notes ignore quotaBytes.
+	Create(ctx context.Context, evidence Evidence, quotaBytes int64) (Evidence, error)
+	Get(ctx context.Context, id string) (Evidence, error)
+	ListByTask(ctx context.Context, taskID string) ([]Evidence, error)
+	// Delete removes an item but not its stored file.
+	Delete(ctx context.Context, id string) error
+	// ProjectUsage totals the size of the files uploaded to a project.
+	ProjectUsage(ctx context.Context, projectID string) (int64, error)
+
+	// ClaimFile marks the stored file with the given SHA-256 as in use
+	// before it is stored. It fails with ErrConflict while the file is
+	// being collected.
+	ClaimFile(ctx context.Context, sha256 string) error
+	// CollectFiles marks the files that no item has referred to for at
+	// least unusedFor as being collected and returns their hashes,
+	// together with those whose collection did not finish.
+	CollectFiles(ctx context.Context, unusedFor time.Duration) ([]string, error)
+	// ForgetFile drops a collected file once it is deleted from the store.
+	ForgetFile(ctx context.Context, sha256 string) error
+}
+
+// UploadPolicyRepository persists per-project evidence upload policies.
+type UploadPolicyRepository interface {
+	// Get returns ErrNotFound when the project has no policy of its own.
+	Get(ctx context.Context, projectID string) (UploadPolicy, error)
+	Save(ctx context.Context, policy UploadPolicy) (UploadPolicy, error)
+}
+
+// MembershipRepository resolves a user's role in a project from the ideas
+// and idea_contributors tables. It returns ErrNotFound for unknown projects
+// and RoleNone for users with no relationship to the project.
+type MembershipRepository interface {
+	ProjectRole(ctx context.Context, projectID, userID string) (Role, error)
+	// ProjectLead returns the ID of the user who leads the project.
+	ProjectLead(ctx context.Context, projectID string) (string, error)
+}
+
+// NotificationRepository persists users' in-app notifications.
+type NotificationRepository interface {
+	Create(ctx context.Context, notification Notification) (Notification, error)
+	// ListByUser returns a user's newest notifications first.
+	ListByUser(ctx context.Context, userID string, unreadOnly bool, limit int) ([]Notification, error)
+	CountUnread(ctx context.Context, userID string) (int, error)
+	// MarkRead returns ErrNotFound unless the notification is the user's.
+	MarkRead(ctx context.Context, userID, id string) (Notification, error)
+	MarkAllRead(ctx context.Context, userID string) error
+}
+
+// NotificationPreferenceRepository persists users' notification settings.
+type NotificationPreferenceRepository interface {
+	// Get returns ErrNotFound when the user has not saved preferences.
+	Get(ctx context.Context, userID string) (NotificationPreferences, error)
+	Save(ctx context.Context, prefs NotificationPreferences) (NotificationPreferences, error)
+}
+
+// LockRepository hands out expiring named locks so that only one replica
+// runs each scheduled job.
+type LockRepository interface {
+	// TryAcquire takes or renews the lock for ttl. It returns false while
+	// another holder's lock has not expired.
+	TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+}
+
+// WebhookRepository persists project webhook subscriptions.
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook Webhook) (Webhook, error)
+	Get(ctx context.Context, id string) (Webhook, error)
+	ListByProject(ctx context.Context, projectID string) ([]Webhook, error)
+	// ListForEvent returns the project's active webhooks subscribed to
+	// eventType.
+	ListForEvent(ctx context.Context, projectID, eventType string) ([]Webhook, error)
+	Update(ctx context.Context, id string, updates map[string]interface{}) (Webhook, error)
+	// Delete removes a webhook and its delivery log.
+	Delete(ctx context.Context, id string) error
+}
+
+// WebhookDeliveryRepository persists the webhook delivery log.
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, delivery WebhookDelivery) (WebhookDelivery, error)
+	Get(ctx context.Context, id string) (WebhookDelivery, error)
+	// ListByWebhook returns a webhook's most recent deliveries, newest
+	// first.
+	ListByWebhook(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error)
+	// ListDue returns pending deliveries whose next attempt is due at now,
+	// oldest first.
+	ListDue(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	Update(ctx context.Context, id string, updates map[string]interface{}) (WebhookDelivery, error)
+}
+
+// AuditQuery selects audit entries. Empty fields do not filter.
+type AuditQuery struct {
+	ProjectID  string
+	EntityType string
+	EntityID   string
+	ActorID    string
+	Since      time.Time
+	Until      time.Time
+	// Before continues a listing after the entry it names.
+	Before *auditCursor
+	Limit  int
+}
+
+// AuditRepository persists the append-only audit log.
+type AuditRepository interface {
+	Append(ctx context.Context, entries []AuditEntry) error
+	// List returns the entries matching q, newest first.
+	List(ctx context.Context, q AuditQuery) ([]AuditEntry, error)
+}
+
+// Repositories bundles the storage backends used by the API handlers.
+type Repositories struct {
+	Timelines  TimelineRepository
+	Milestones MilestoneRepository
+	Tasks      TaskRepository
+	History    TaskHistoryRepository
+	Reviews    ReviewRepository
+	Evidence   EvidenceRepository
+	Policies   UploadPolicyRepository
+	Members    MembershipRepository
+	Locks      LockRepository
+
+	Notifications     NotificationRepository
+	NotificationPrefs NotificationPreferenceRepository
+	Webhooks          WebhookRepository
+	WebhookDeliveries WebhookDeliveryRepository
+	Audit             AuditRepository
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Review verdicts and the task status each one moves a submission to.
+const (
+	VerdictApprove        = "approve"
+	VerdictRequestChanges = "request_changes"
+	VerdictReject         = "reject"
+)
+
+var verdictStatus = map[string]string{
+	VerdictApprove:        TaskApproved,
+	VerdictRequestChanges: TaskChangesRequested,
+	VerdictReject:         TaskClosed,
+}
+
+// ReviewResponse is returned after a review is recorded.
+type ReviewResponse struct {
+	Review TaskReview `json:"review"`
+	Task   Task       `json:"task"`
+}
+
+// Review task endpoint. A review rules on the task's current submission
+// round; requesting changes sends the task back to the assignee, who
+// resubmits to open the next round.
+func (s *server) handleReviewTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	var req ReviewTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	to, ok := verdictStatus[req.Verdict]
+	if !ok {
+		writeError(w, "verdict must be one of approve, request_changes, reject", http.StatusBadRequest)
+		return
+	}
+	if req.Verdict != VerdictApprove && req.Comments == "" {
+		writeError(w, "Comments are required when requesting changes or rejecting", http.StatusBadRequest)
+		return
+	}
+
+	task, _, ok := s.authorizeTask(w, r, taskId, leadOnly)
+	if !ok {
+		return
+	}
+	if req.SubmissionRound != nil && *req.SubmissionRound != task.SubmissionRound {
+		writeError(w, fmt.Sprintf("Submission round %d is no longer current (now %d)", *req.SubmissionRound, task.SubmissionRound), http.StatusConflict)
+		return
+	}
+
+	if !canTransition(task.Status, to) {
+		writeTransitionError(w, &transitionError{from: task.Status, to: to})
+		return
+	}
+
+	evidence, err := s.evidence.ListByTask(r.Context(), task.ID)
+	if err != nil {
+		fmt.Printf("Error fetching reviewed evidence: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	review := TaskReview{
+		TaskID:          task.ID,
+		ReviewerID:      currentUserID(r),
+		Verdict:         req.Verdict,
+		Comments:        req.Comments,
+		SubmissionRound: task.SubmissionRound,
+		EvidenceIDs:     []string{},
+		EvidenceSHA256:  []string{},
+	}
+	for _, item := range evidence {
+		if item.SubmissionRound != task.SubmissionRound {
+			continue
+		}
+		review.EvidenceIDs = append(review.EvidenceIDs, item.ID)
+		if item.SHA256 != "" {
+			review.EvidenceSHA256 = append(review.EvidenceSHA256, item.SHA256)
+		}
+	}
+
+	// Record the review first so a verdict is never applied without one.
+	// If the task moved on meanwhile, the review is withdrawn again.
+	review, err = s.reviews.Create(r.Context(), review)
+	if err != nil {
+		fmt.Printf("Error saving review: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	updated, err := s.transitionTask(r.Context(), task, to, review.ReviewerID, req.Comments, nil)
+	if err != nil {
+		fmt.Printf("Error reviewing task: %v\n", err)
+		if delErr := s.reviews.Delete(r.Context(), review.ID); delErr != nil {
+			fmt.Printf("Error withdrawing review %s: %v\n", review.ID, delErr)
+		}
+		writeTransitionError(w, err)
+		return
+	}
+	recordAudit(r.Context(), "review", review.ID, nil, review)
+
+	s.publishTaskEvent(r.Context(), EventTaskReviewed, updated, review.ReviewerID, map[string]interface{}{
+		"verdict":          review.Verdict,
+		"comments":         review.Comments,
+		"submission_round": review.SubmissionRound,
+	})
+
+	writeJSONStatus(w, http.StatusCreated, ReviewResponse{Review: review, Task: updated})
+}
+
+// List task reviews endpoint
+func (s *server) handleListReviews(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	if _, _, ok := s.authorizeTask(w, r, taskId, anyMember); !ok {
+		return
+	}
+
+	reviews, err := s.reviews.ListByTask(r.Context(), taskId)
+	if err != nil {
+		fmt.Printf("Error fetching reviews: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, reviews)
+}
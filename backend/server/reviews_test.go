@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestReviewRecordsEvidenceAndWithdrawsOnConflict(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	status := "/api/tasks/" + task.ID + "/status"
+
+	api.do(t, "dev", "POST", status, map[string]interface{}{"status": TaskInProgress}, nil)
+	var note Evidence
+	if resp := api.do(t, "dev", "POST", "/api/tasks/"+task.ID+"/evidence", map[string]interface{}{"kind": "text", "body": "Done"}, &note); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("adding evidence: status %d", resp.StatusCode)
+	}
+	api.do(t, "dev", "POST", status, map[string]interface{}{"status": TaskSubmitted}, nil)
+
+	var result ReviewResponse
+	review := "/api/tasks/" + task.ID + "/review"
+	if resp := api.do(t, "lead", "POST", review, map[string]interface{}{"verdict": VerdictApprove}, &result); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("reviewing: status %d", resp.StatusCode)
+	}
+	if result.Task.Status != TaskApproved {
+		t.Errorf("task status = %q", result.Task.Status)
+	}
+	if len(result.Review.EvidenceIDs) != 1 || result.Review.EvidenceIDs[0] != note.ID {
+		t.Errorf("review evidence = %v, want [%s]", result.Review.EvidenceIDs, note.ID)
+	}
+
+	// A second verdict on the same round is refused and leaves no review
+	api.expectError(t, "lead", "POST", review, map[string]interface{}{"verdict": VerdictRequestChanges, "comments": "Redo"}, http.StatusConflict, "conflict")
+	reviews, err := api.repos.Reviews.ListByTask(context.Background(), task.ID)
+	if err != nil || len(reviews) != 1 {
+		t.Fatalf("reviews = %+v, %v; want one", reviews, err)
+	}
+}
+
+// failingTasks fails the conditional update a verdict is applied with, as
+// when another reviewer got there first.
+type failingTasks struct {
+	TaskRepository
+}
+
+func (failingTasks) UpdateIfStatus(ctx context.Context, id, status string, updates map[string]interface{}) (Task, error) {
+	return Task{}, ErrConflict
+}
+
+func TestReviewIsWithdrawnWhenVerdictFails(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	task := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	status := "/api/tasks/" + task.ID + "/status"
+	api.do(t, "dev", "POST", status, map[string]interface{}{"status": TaskInProgress}, nil)
+	api.do(t, "dev", "POST", status, map[string]interface{}{"status": TaskSubmitted}, nil)
+
+	api.server.tasks = failingTasks{api.server.tasks}
+	api.expectError(t, "lead", "POST", "/api/tasks/"+task.ID+"/review", map[string]interface{}{"verdict": VerdictApprove}, http.StatusConflict, "conflict")
+	if reviews, _ := api.repos.Reviews.ListByTask(context.Background(), task.ID); len(reviews) != 0 {
+		t.Errorf("reviews = %+v, want none", reviews)
+	}
+}
+
+func TestRejectingLegacyReviewedTaskClearsReview(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:dev:contributor")
+	milestone := api.createMilestone(t, "lead", "p1", "Alpha")
+	legacy := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Spec", "assignee_id": "dev"})
+	approved := api.createTask(t, "lead", milestone.ID, map[string]interface{}{"title": "Build", "assignee_id": "dev"})
+	ctx := context.Background()
+
+	// A task completed and reviewed before the lifecycle existed
+	if _, err := api.repos.Tasks.Update(ctx, legacy.ID, map[string]interface{}{"status": taskLegacyCompleted, "reviewed": true}); err != nil {
+		t.Fatal(err)
+	}
+	var result ReviewResponse
+	if resp := api.do(t, "lead", "POST", "/api/tasks/"+legacy.ID+"/review", map[string]interface{}{"verdict": VerdictReject, "comments": "Out of scope"}, &result); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("rejecting: status %d", resp.StatusCode)
+	}
+	if result.Task.Status != TaskClosed || result.Task.Reviewed || taskDone(result.Task) {
+		t.Errorf("rejected legacy task: status %q, reviewed %v", result.Task.Status, result.Task.Reviewed)
+	}
+
+	// Closing an approved task keeps its approval
+	status := "/api/tasks/" + approved.ID + "/status"
+	api.do(t, "dev", "POST", status, map[string]interface{}{"status": TaskInProgress}, nil)
+	api.do(t, "dev", "POST", status, map[string]interface{}{"status": TaskSubmitted}, nil)
+	api.do(t, "lead", "POST", "/api/tasks/"+approved.ID+"/review", map[string]interface{}{"verdict": VerdictApprove}, nil)
+	var closed Task
+	if resp := api.do(t, "lead", "POST", status, map[string]interface{}{"status": TaskClosed}, &closed); resp.StatusCode != http.StatusOK {
+		t.Fatalf("closing: status %d", resp.StatusCode)
+	}
+	if !closed.Reviewed || !taskDone(closed) {
+		t.Errorf("closed approved task: reviewed %v", closed.Reviewed)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// S3Config points an S3EvidenceStore at an S3-compatible service. Requests
+// use path-style addressing so MinIO and similar servers work unchanged.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3EvidenceStore keeps evidence as objects named by their SHA-256 in an
+// S3-compatible bucket, signing requests with AWS Signature Version 4.
+type S3EvidenceStore struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// NewS3EvidenceStore returns a store for cfg.
+func NewS3EvidenceStore(cfg S3Config) (*S3EvidenceStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", cfg.Endpoint)
+	}
+	return &S3EvidenceStore{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3EvidenceStore) objectPath(key string) string {
+	return s.endpoint.Path + "/" + s.cfg.Bucket + "/" + key
+}
+
+func (s *S3EvidenceStore) Put(ctx context.Context, r io.Reader) (StoredObject, error) {
+	tmp, err := spool(r, os.TempDir())
+	if err != nil {
+		return StoredObject{}, err
+	}
+	defer tmp.cleanup()
+
+	obj := StoredObject{
+		Key:      tmp.key,
+		Size:     tmp.size,
+		Location: fmt.Sprintf("s3://%s/%s", s.cfg.Bucket, tmp.key),
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, tmp.key, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return StoredObject{}, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return obj, nil
+	}
+
+	// The object's key is its SHA-256, which is also the signed payload hash.
+	resp, err = s.do(ctx, http.MethodPut, tmp.key, tmp.File, tmp.size, tmp.key, nil)
+	if err != nil {
+		return StoredObject{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return StoredObject{}, s3Error(resp)
+	}
+	return obj, nil
+}
+
+func (s *S3EvidenceStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !validEvidenceKey(key) {
+		return nil, ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp)
+	}
+
+	body := &rangedReader{
+		size: resp.ContentLength,
+		open: func(offset int64) (io.ReadCloser, error) {
+			var header http.Header
+			if offset > 0 {
+				header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}}
+			}
+			resp, err := s.do(ctx, http.MethodGet, key, nil, 0, emptyPayloadHash, header)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+				defer resp.Body.Close()
+				return nil, s3Error(resp)
+			}
+			return resp.Body, nil
+		},
+	}
+	return newVerifyingReader(body, key, resp.ContentLength), nil
+}
+
+func (s *S3EvidenceStore) Delete(ctx context.Context, key string) error {
+	if !validEvidenceKey(key) {
+		return ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// do sends a signed request for the object key. Extra headers are sent
+// unsigned.
+func (s *S3EvidenceStore) do(ctx context.Context, method, key string, body io.Reader, size int64, payloadHash string, header http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = s.objectPath(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.sign(req, payloadHash)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s %s: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (s *S3EvidenceStore) sign(req *http.Request, payloadHash string) {
+	t := s.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Error turns an unsuccessful S3 response into an error.
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
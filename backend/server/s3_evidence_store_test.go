@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestS3SignatureV4(t *testing.T) {
+	store, err := NewS3EvidenceStore(S3Config{
+		Endpoint:        "http://minio.test:9000/",
+		Bucket:          "evidence",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.now = func() time.Time { return time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC) }
+
+	key := "9af4c73b2a919f220f4b008e466b52808a1987122d95ff0f2dde00968e36e844"
+	req, _ := http.NewRequest(http.MethodGet, "http://minio.test:9000"+store.objectPath(key), nil)
+	store.sign(req, emptyPayloadHash)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20130524/us-east-1/s3/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=6c35ed17411f2fe3b2ace837c3bd0674aec819b25e84f15a1782e93e9f3c3eb4"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+	if req.Header.Get("X-Amz-Date") != "20130524T000000Z" || req.Header.Get("X-Amz-Content-Sha256") != emptyPayloadHash {
+		t.Errorf("signing headers = %v", req.Header)
+	}
+}
+
+// fakeS3 is an in-memory bucket that checks each request names the
+// credentials and payload hash the store should sign with.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	puts    int
+	ranges  []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=minio/") {
+		f.t.Errorf("%s %s: Authorization = %q", r.Method, r.URL.Path, auth)
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/evidence/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(data)
+		if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != hex.EncodeToString(sum[:]) {
+			http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = data
+		f.puts++
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			f.ranges = append(f.ranges, rng)
+			var offset int
+			fmt.Sscanf(rng, "bytes=%d-", &offset)
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[offset:])
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3EvidenceStore(t *testing.T) {
+	bucket := &fakeS3{t: t, objects: map[string][]byte{}}
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	store, err := NewS3EvidenceStore(S3Config{
+		Endpoint:        srv.URL,
+		Bucket:          "evidence",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	content := "hello evidence"
+	obj, err := store.Put(ctx, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if obj.Key != hex.EncodeToString(sum[:]) || obj.Size != int64(len(content)) || obj.Location != "s3://evidence/"+obj.Key {
+		t.Errorf("stored object = %+v", obj)
+	}
+	if _, err := store.Put(ctx, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if bucket.puts != 1 {
+		t.Errorf("stored the same content %d times", bucket.puts)
+	}
+
+	body, err := store.Open(ctx, obj.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(body); err != nil || string(data) != content {
+		t.Errorf("read %q, %v", data, err)
+	}
+	if _, err := body.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(body); err != nil || string(data) != "evidence" {
+		t.Errorf("ranged read %q, %v", data, err)
+	}
+	body.Close()
+	if len(bucket.ranges) != 1 || bucket.ranges[0] != "bytes=6-" {
+		t.Errorf("ranges = %v", bucket.ranges)
+	}
+
+	bucket.objects[obj.Key] = []byte("hello tampered")
+	body, err = store.Open(ctx, obj.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(body); !errors.Is(err, errDigestMismatch) {
+		t.Errorf("reading tampered evidence: %v", err)
+	}
+	body.Close()
+
+	if err := store.Delete(ctx, obj.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(ctx, obj.Key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("open after delete: %v", err)
+	}
+	if _, err := store.Open(ctx, "../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("open invalid key: %v", err)
+	}
+}
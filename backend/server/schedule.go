@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// checkSchedule compares milestone due dates with the timeline and task
+// due dates with their milestones. Unset dates are not checked.
+// Milestones assigned to a phase must fall inside it; the others must
+// fall inside the span of all phases.
+func checkSchedule(phases []Timeline, milestones []Milestone) ScheduleReport {
+	issues := []ScheduleIssue{}
+
+	byID := make(map[string]Timeline, len(phases))
+	var first, last Date
+	for _, phase := range phases {
+		byID[phase.ID] = phase
+		if validateDateRange(phase.StartDate, phase.EndDate) != nil {
+			issues = append(issues, ScheduleIssue{
+				Kind:    IssuePhaseEndsBeforeStart,
+				Message: fmt.Sprintf("Phase %q runs from %s to %s", phase.Name, phase.StartDate, phase.EndDate),
+				PhaseID: phase.ID,
+			})
+			continue
+		}
+		if first.IsZero() || phase.StartDate.Before(first) {
+			first = phase.StartDate
+		}
+		if last.IsZero() || phase.EndDate.After(last) {
+			last = phase.EndDate
+		}
+	}
+
+	for _, milestone := range milestones {
+		due := milestone.DueDate
+		if !due.IsZero() {
+			if phase, ok := byID[milestone.PhaseID]; ok {
+				if due.Before(phase.StartDate) || due.After(phase.EndDate) {
+					issues = append(issues, ScheduleIssue{
+						Kind:        IssueMilestoneOutsidePhase,
+						Message:     fmt.Sprintf("Milestone %q is due %s, outside phase %q (%s to %s)", milestone.Title, due, phase.Name, phase.StartDate, phase.EndDate),
+						PhaseID:     phase.ID,
+						MilestoneID: milestone.ID,
+					})
+				}
+			} else if !first.IsZero() && (due.Before(first) || due.After(last)) {
+				issues = append(issues, ScheduleIssue{
+					Kind:        IssueMilestoneOutsideProject,
+					Message:     fmt.Sprintf("Milestone %q is due %s, outside the project timeline (%s to %s)", milestone.Title, due, first, last),
+					MilestoneID: milestone.ID,
+				})
+			}
+		}
+
+		for _, task := range milestone.Tasks {
+			if due.IsZero() || task.DueDate.IsZero() || !task.DueDate.After(due) {
+				continue
+			}
+			issues = append(issues, ScheduleIssue{
+				Kind:        IssueTaskAfterMilestone,
+				Message:     fmt.Sprintf("Task %q is due %s, after its milestone %q (%s)", task.Title, task.DueDate, milestone.Title, due),
+				MilestoneID: milestone.ID,
+				TaskID:      task.ID,
+			})
+		}
+	}
+
+	return ScheduleReport{Valid: len(issues) == 0, Issues: issues}
+}
+
+// Validate project schedule endpoint
+func (s *server) handleValidateSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	if _, ok := s.authorizeProject(w, r, projectId, anyMember); !ok {
+		return
+	}
+
+	phases, err := s.timelines.ListByProject(r.Context(), projectId)
+	if err != nil {
+		fmt.Printf("Error fetching phases: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	milestones, err := s.milestones.ListByProject(r.Context(), projectId)
+	if err != nil {
+		fmt.Printf("Error fetching milestones: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, checkSchedule(phases, milestones))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func mustDate(s string) Date {
+	d, err := ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestCheckSchedule(t *testing.T) {
+	phases := []Timeline{
+		{ID: "discovery", Name: "Discovery", StartDate: mustDate("2030-01-01"), EndDate: mustDate("2030-01-31")},
+		{ID: "build", Name: "Build", StartDate: mustDate("2030-02-01"), EndDate: mustDate("2030-03-31")},
+		{ID: "broken", Name: "Broken", StartDate: mustDate("2030-05-01"), EndDate: mustDate("2030-04-01")},
+	}
+	milestones := []Milestone{
+		// On the phase's last day, and an instant late on it
+		{ID: "m1", PhaseID: "discovery", DueDate: mustDate("2030-01-31")},
+		{ID: "m2", PhaseID: "discovery", DueDate: mustDate("2030-01-31T23:00:00Z")},
+		{ID: "m3", PhaseID: "discovery", DueDate: mustDate("2030-02-01")},
+		{ID: "m4", DueDate: mustDate("2030-03-31")},
+		{ID: "m5", DueDate: mustDate("2030-04-15")},
+		{ID: "m6", PhaseID: "build", Tasks: []Task{{ID: "t1", DueDate: mustDate("2031-01-01")}}},
+		{ID: "m7", PhaseID: "build", DueDate: mustDate("2030-03-01"), Tasks: []Task{
+			{ID: "t2", DueDate: mustDate("2030-03-01T12:00:00Z")},
+			{ID: "t3", DueDate: mustDate("2030-03-02")},
+			{ID: "t4"},
+		}},
+	}
+
+	report := checkSchedule(phases, milestones)
+	var got [][3]string
+	for _, issue := range report.Issues {
+		got = append(got, [3]string{issue.Kind, issue.MilestoneID, issue.TaskID})
+	}
+	want := [][3]string{
+		{IssuePhaseEndsBeforeStart, "", ""},
+		{IssueMilestoneOutsidePhase, "m3", ""},
+		{IssueMilestoneOutsideProject, "m5", ""},
+		{IssueTaskAfterMilestone, "m7", "t3"},
+	}
+	if report.Valid || !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %v, want %v", got, want)
+	}
+
+	if report := checkSchedule(nil, []Milestone{{ID: "m1", DueDate: mustDate("2040-01-01")}}); !report.Valid || report.Issues == nil {
+		t.Errorf("without phases: %+v", report)
+	}
+}
+
+func TestValidateScheduleEndpoint(t *testing.T) {
+	api := newTestAPI(t, "p1:lead:lead", "p1:watcher:viewer")
+	resp := api.do(t, "lead", "POST", "/api/timeline", map[string]interface{}{
+		"project_id": "p1",
+		"name":       "Discovery",
+		"start_date": "2029-01-01",
+		"end_date":   "2029-12-31",
+	}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("creating phase: status %d", resp.StatusCode)
+	}
+	api.createMilestone(t, "lead", "p1", "Alpha")
+
+	var report ScheduleReport
+	if resp = api.do(t, "watcher", "GET", "/api/projects/p1/schedule/validate", nil, &report); resp.StatusCode != http.StatusOK {
+		t.Fatalf("validating: status %d", resp.StatusCode)
+	}
+	if report.Valid || len(report.Issues) != 1 || report.Issues[0].Kind != IssueMilestoneOutsideProject {
+		t.Errorf("report = %+v", report)
+	}
+	api.expectError(t, "stranger", "GET", "/api/projects/p1/schedule/validate", nil, http.StatusForbidden, "forbidden")
+}
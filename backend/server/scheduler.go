@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"imara-shared/config"
+)
+
+// Job is a task the scheduler runs periodically.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs jobs in the background. Before each run it takes the
+// job's lock, so with several replicas only the current holder runs it;
+// the lock outlives two intervals, letting another replica take over
+// when the holder stops.
+type Scheduler struct {
+	locks  LockRepository
+	holder string
+	jobs   []Job
+}
+
+// NewScheduler returns a scheduler that identifies itself to the lock
+// repository by host name and a random suffix.
+func NewScheduler(locks LockRepository) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{locks: locks, holder: host + "-" + uuid.NewString()[:8]}
+}
+
+// Add registers a job. It must be called before Run.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Run starts every job, running each immediately and then on its
+// interval, and blocks until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for _, job := range s.jobs {
+		go func(job Job) {
+			defer func() { done <- struct{}{} }()
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+			for {
+				s.runOnce(ctx, job)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(job)
+	}
+	for range s.jobs {
+		<-done
+	}
+}
+
+// runOnce runs job if this replica holds its lock.
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	leader, err := s.locks.TryAcquire(ctx, job.Name, s.holder, 2*job.Interval)
+	if err != nil {
+		fmt.Printf("Error acquiring %s lock: %v\n", job.Name, err)
+		return
+	}
+	if !leader {
+		return
+	}
+	if err := job.Run(ctx); err != nil {
+		fmt.Printf("Error running %s: %v\n", job.Name, err)
+	}
+}
+
+// startScheduler runs the server's background jobs unless disabled.
+func (s *server) startScheduler(ctx context.Context, locks LockRepository, cfg config.Scheduler) {
+	if cfg.Disabled {
+		fmt.Println("Background jobs are disabled on this replica")
+		return
+	}
+	scheduler := NewScheduler(locks)
+	scheduler.Add(Job{Name: "overdue-sweep", Interval: cfg.OverdueSweeps, Run: s.sweepOverdue})
+	scheduler.Add(Job{Name: "webhook-retries", Interval: cfg.WebhookRetries, Run: s.webhookDispatcher.RetryDue})
+	scheduler.Add(Job{Name: "evidence-gc", Interval: cfg.EvidenceGC, Run: s.collectEvidenceFiles})
+	go scheduler.Run(ctx)
+}
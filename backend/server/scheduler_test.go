@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryLock(t *testing.T) {
+	repos := NewMemoryRepositories()
+	locks := repos.Locks
+	ctx := context.Background()
+
+	acquire := func(holder string, want bool) {
+		t.Helper()
+		if got, err := locks.TryAcquire(ctx, "sweep", holder, time.Minute); err != nil || got != want {
+			t.Fatalf("%s acquiring: %v, %v; want %v", holder, got, err, want)
+		}
+	}
+	acquire("a", true)
+	acquire("b", false)
+	// The holder renews
+	acquire("a", true)
+	acquire("b", false)
+
+	// Another replica takes over once the lock expires
+	store := locks.(*memoryLockRepository).store
+	store.mu.Lock()
+	lock := store.locks["sweep"]
+	lock.expires = time.Now().Add(-time.Second)
+	store.locks["sweep"] = lock
+	store.mu.Unlock()
+	acquire("b", true)
+	acquire("a", false)
+
+	// Locks are independent
+	if got, _ := locks.TryAcquire(ctx, "retries", "a", time.Minute); !got {
+		t.Error("a second lock is held by the first")
+	}
+}
+
+func TestSupabaseLock(t *testing.T) {
+	var renewed, insert string
+	var filter string
+	postgrest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			filter = r.URL.Query().Get("or")
+			w.Write([]byte(renewed))
+		case http.MethodPost:
+			if insert != "" {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(insert))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`[{"name":"sweep"}]`))
+		}
+	}))
+	defer postgrest.Close()
+	locks := &supabaseLockRepository{db: newSupabaseDB(postgrest.URL, "service")}
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		renewed string
+		insert  string
+		want    bool
+		err     bool
+	}{
+		{"renewed", `[{"name":"sweep"}]`, "", true, false},
+		{"created", `[]`, "", true, false},
+		{"held elsewhere", `[]`, `{"code":"23505","message":"duplicate key value violates unique constraint"}`, false, false},
+		{"other error", `[]`, `{"code":"42501","message":"permission denied"}`, false, true},
+	}
+	for _, c := range cases {
+		renewed, insert = c.renewed, c.insert
+		got, err := locks.TryAcquire(ctx, "sweep", "replica-1", time.Minute)
+		if got != c.want || (err != nil) != c.err {
+			t.Errorf("%s: %v, %v", c.name, got, err)
+		}
+	}
+	if !strings.HasPrefix(filter, `(holder.eq."replica-1",expires_at.lt."`) {
+		t.Errorf("renewal filter = %s", filter)
+	}
+}
+
+// fakeLocks answers TryAcquire with leader and err.
+type fakeLocks struct {
+	leader bool
+	err    error
+}
+
+func (l *fakeLocks) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	return l.leader, l.err
+}
+
+func TestSchedulerRunsOnlyOnTheLeader(t *testing.T) {
+	locks := &fakeLocks{}
+	scheduler := NewScheduler(locks)
+	var runs atomic.Int32
+	job := Job{Name: "sweep", Interval: time.Hour, Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+	ctx := context.Background()
+
+	scheduler.runOnce(ctx, job)
+	locks.err = errors.New("database unavailable")
+	scheduler.runOnce(ctx, job)
+	if runs.Load() != 0 {
+		t.Fatalf("job ran %d times without the lock", runs.Load())
+	}
+
+	locks.leader, locks.err = true, nil
+	scheduler.runOnce(ctx, job)
+	if runs.Load() != 1 {
+		t.Fatalf("job ran %d times as leader", runs.Load())
+	}
+}
+
+func TestSchedulerRunsJobsUntilCancelled(t *testing.T) {
+	scheduler := NewScheduler(NewMemoryRepositories().Locks)
+	ran := make(chan string, 16)
+	for _, name := range []string{"sweep", "retries"} {
+		name := name
+		scheduler.Add(Job{Name: name, Interval: time.Hour, Run: func(ctx context.Context) error {
+			ran <- name
+			return nil
+		}})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(stopped)
+	}()
+
+	// Every job runs straight away
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case name := <-ran:
+			seen[name] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("jobs run: %v", seen)
+		}
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// urlSigner issues and checks short-lived links that grant access to a
+// path without an access token.
+type urlSigner struct {
+	key []byte
+	now func() time.Time
+}
+
+// newURLSigner returns a signer keyed by secret.
+func newURLSigner(secret string) (*urlSigner, error) {
+	if secret == "" {
+		return nil, errors.New("a URL signing secret is required")
+	}
+	return &urlSigner{key: []byte(secret), now: time.Now}, nil
+}
+
+// randomURLSecret returns a per-process signing secret for the memory
+// backend. Links signed with it stop working when the server restarts.
+func randomURLSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generating URL signing key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+func (s *urlSigner) signature(path string, expires int64) string {
+	mac := hmacSHA256(s.key, path+"\n"+strconv.FormatInt(expires, 10))
+	return hex.EncodeToString(mac)
+}
+
+// sign returns path with expires and signature query parameters valid for ttl.
+func (s *urlSigner) sign(path string, ttl time.Duration) (string, time.Time) {
+	expiresAt := s.now().Add(ttl).UTC().Truncate(time.Second)
+	expires := expiresAt.Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {s.signature(path, expires)},
+	}
+	return path + "?" + query.Encode(), expiresAt
+}
+
+// verify reports whether signature grants access to path until expires
+// and that time has not passed.
+func (s *urlSigner) verify(path, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.signature(path, exp)))
+}
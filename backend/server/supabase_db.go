@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	postgrest "github.com/supabase-community/postgrest-go"
+)
+
+// supabaseDB starts PostgREST queries that are bound to a context, so a
+// request that is cancelled, or cut off at shutdown, stops the queries it
+// started. postgrest-go builds its requests without a context, so every
+// query gets a light client whose transport attaches one.
+type supabaseDB struct {
+	restURL string
+	headers map[string]string
+}
+
+// newSupabaseDB returns a database for the Supabase project at url,
+// authenticated with key.
+func newSupabaseDB(url, key string) *supabaseDB {
+	return &supabaseDB{
+		restURL: strings.TrimRight(url, "/") + "/rest/v1",
+		headers: map[string]string{
+			"apikey":        key,
+			"Authorization": "Bearer " + key,
+		},
+	}
+}
+
+// From starts a query on table that is cancelled with ctx.
+func (db *supabaseDB) From(ctx context.Context, table string) *postgrest.QueryBuilder {
+	client := postgrest.NewClient(db.restURL, "public", db.headers)
+	if client.ClientError == nil {
+		client.Transport.Parent = contextTransport{ctx: ctx, next: http.DefaultTransport}
+	}
+	return client.From(table)
+}
+
+// RPC calls the SQL function name with params as its named arguments and
+// returns the response body. Errors read "(code) message" like those of
+// postgrest-go, so they map to API errors the same way.
+func (db *supabaseDB) RPC(ctx context.Context, name string, params interface{}) ([]byte, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, db.restURL+"/rpc/"+name, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range db.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		var pgErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &pgErr) != nil || pgErr.Code == "" {
+			return nil, fmt.Errorf("rpc %s: status %d", name, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("(%s) %s", pgErr.Code, pgErr.Message)
+	}
+	return data, nil
+}
+
+// contextTransport sends requests with its context.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMilestoneDeleteCallsRPC(t *testing.T) {
+	var got map[string]interface{}
+	reply := http.StatusNoContent
+	var body string
+	postgrest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/rpc/delete_milestone" || r.Header.Get("apikey") != "service" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(reply)
+		w.Write([]byte(body))
+	}))
+	defer postgrest.Close()
+
+	repo := &supabaseMilestoneRepository{db: newSupabaseDB(postgrest.URL, "service")}
+	ctx := context.Background()
+
+	if err := repo.Delete(ctx, "m1", onTasksMove, "m2"); err != nil {
+		t.Fatal(err)
+	}
+	if got["p_milestone_id"] != "m1" || got["p_on_tasks"] != onTasksMove || got["p_target_milestone_id"] != "m2" {
+		t.Errorf("params = %v", got)
+	}
+
+	for code, want := range map[string]error{"P0002": ErrNotFound, "23503": ErrConflict} {
+		reply, body = http.StatusBadRequest, `{"code":"`+code+`","message":"nope"}`
+		if err := repo.Delete(ctx, "m1", onTasksBlock, ""); !errors.Is(err, want) {
+			t.Errorf("code %s: error %v, want %v", code, err, want)
+		}
+	}
+
+	reply, body = http.StatusBadRequest, `{"code":"22023","message":"bad target"}`
+	err := repo.Delete(ctx, "m1", onTasksMove, "m3")
+	if apiErr := serverError(err); apiErr.Status != http.StatusBadRequest {
+		t.Errorf("invalid target maps to %d, want 400", apiErr.Status)
+	}
+}
+
+func TestWebhookSecretsAreStoredSealed(t *testing.T) {
+	box, err := newSecretBox("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	postgrest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&stored)
+		stored["id"] = "w1"
+		json.NewEncoder(w).Encode([]map[string]interface{}{stored})
+	}))
+	defer postgrest.Close()
+
+	repo := &supabaseWebhookRepository{db: newSupabaseDB(postgrest.URL, "service"), secrets: box}
+	created, err := repo.Create(context.Background(), Webhook{ProjectID: "p1", URL: "https://example.com", Secret: "whsec_plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := stored["secret_encrypted"].(string)
+	if _, ok := stored["secret"]; ok || sealed == "" || strings.Contains(sealed, "whsec_plain") {
+		t.Fatalf("stored row = %v", stored)
+	}
+	if created.Secret != "whsec_plain" {
+		t.Errorf("created secret = %q", created.Secret)
+	}
+
+	other, _ := newSecretBox("another key that is long enough to use")
+	if _, err := other.open(sealed); err == nil {
+		t.Error("secret opened with the wrong key")
+	}
+}
+
+func TestEvidenceCreateCallsRPC(t *testing.T) {
+	var got map[string]interface{}
+	reply, body := http.StatusOK, `[{"id":"e1","kind":"file"}]`
+	postgrest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/rpc/create_task_evidence" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(reply)
+		w.Write([]byte(body))
+	}))
+	defer postgrest.Close()
+
+	repo := &supabaseEvidenceRepository{db: newSupabaseDB(postgrest.URL, "service")}
+	ctx := context.Background()
+	item := Evidence{TaskID: "t1", ProjectID: "p1", Kind: EvidenceFile, Size: 10, SHA256: "abc", SubmissionRound: 1}
+
+	created, err := repo.Create(ctx, item, 1000)
+	if err != nil || created.ID != "e1" {
+		t.Fatalf("created %+v, %v", created, err)
+	}
+	if got["p_quota_bytes"] != float64(1000) || got["p_sha256"] != "abc" || got["p_url"] != nil {
+		t.Errorf("params = %v", got)
+	}
+
+	for code, want := range map[string]error{"53400": ErrQuotaExceeded, "55000": ErrConflict, "23503": ErrNotFound} {
+		reply, body = http.StatusBadRequest, `{"code":"`+code+`","message":"nope"}`
+		if _, err := repo.Create(ctx, item, 1000); !errors.Is(err, want) {
+			t.Errorf("code %s: error %v, want %v", code, err, want)
+		}
+	}
+}
+
+func TestMalformedStoredDatesReadAsUnset(t *testing.T) {
+	rows := `[{"id":"m1","title":"Alpha","due_date":"next week","milestone_tasks":[
+		{"id":"t1","title":"Spec","due_date":"2030-01-31T00:00:00+00:00"},
+		{"id":"t2","title":"Build","due_date":"31/01/2030"}]}]`
+
+	var milestones []Milestone
+	if err := decodeRows([]byte(rows), &milestones); err != nil {
+		t.Fatal(err)
+	}
+	m := milestones[0]
+	if !m.DueDate.IsZero() || m.Title != "Alpha" {
+		t.Errorf("milestone = %+v", m)
+	}
+	if len(m.Tasks) != 2 || m.Tasks[0].DueDate.String() != "2030-01-31T00:00:00Z" || !m.Tasks[1].DueDate.IsZero() {
+		t.Errorf("tasks = %+v", m.Tasks)
+	}
+
+	// Other decoding errors still fail
+	if err := decodeRows([]byte(`[{"id":1}]`), &milestones); err == nil {
+		t.Error("decoded a numeric id")
+	}
+}
+
+func TestTaskSearchEscapesLikeWildcards(t *testing.T) {
+	var filter string
+	postgrest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("and")
+		w.Write([]byte("[]"))
+	}))
+	defer postgrest.Close()
+
+	repo := &supabaseTaskRepository{db: newSupabaseDB(postgrest.URL, "service")}
+	if _, err := repo.List(context.Background(), "m1", ListQuery{Search: `50%_off\*`, Sort: "created_at"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `(or(title.ilike."*50\\%\\_off\\\\_*",description.ilike."*50\\%\\_off\\\\_*"))`
+	if filter != want {
+		t.Errorf("and = %s\nwant %s", filter, want)
+	}
+}
@@ -0,0 +1,1480 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	postgrest "github.com/supabase-community/postgrest-go"
+)
+
+// milestoneSelect fetches milestones together with their tasks using a join.
+const milestoneSelect = "*, milestone_tasks(*)"
+
+// NewSupabaseRepositories returns repositories backed by the given Supabase database.
+func NewSupabaseRepositories(db *supabaseDB, webhookSecrets *secretBox) Repositories {
+	return Repositories{
+		Timelines:  &supabaseTimelineRepository{db: db},
+		Milestones: &supabaseMilestoneRepository{db: db},
+		Tasks:      &supabaseTaskRepository{db: db},
+		History:    &supabaseTaskHistoryRepository{db: db},
+		Reviews:    &supabaseReviewRepository{db: db},
+		Evidence:   &supabaseEvidenceRepository{db: db},
+		Policies:   &supabaseUploadPolicyRepository{db: db},
+		Members:    &supabaseMembershipRepository{db: db},
+		Locks:      &supabaseLockRepository{db: db},
+
+		Notifications:     &supabaseNotificationRepository{db: db},
+		NotificationPrefs: &supabaseNotificationPreferenceRepository{db: db},
+		Webhooks:          &supabaseWebhookRepository{db: db, secrets: webhookSecrets},
+		WebhookDeliveries: &supabaseWebhookDeliveryRepository{db: db},
+		Audit:             &supabaseAuditRepository{db: db},
+	}
+}
+
+// nullable maps an empty string to SQL NULL so optional uuid columns are
+// not sent as "".
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nonNil maps a nil slice to an empty one so array columns are not sent
+// as null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// decodeRows unmarshals a PostgREST array response into dst.
+func decodeRows(data []byte, dst interface{}) error {
+	err := json.Unmarshal(data, dst)
+	var badDate *dateError
+	if errors.As(err, &badDate) {
+		// Dates written before they were validated may not parse; read
+		// them as unset rather than failing the whole response
+		var rows interface{}
+		if json.Unmarshal(data, &rows) == nil {
+			clearMalformedDates(rows)
+			if data, err = json.Marshal(rows); err == nil {
+				err = json.Unmarshal(data, dst)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// clearMalformedDates nulls the "*_date" fields of decoded rows, and of
+// the rows joined to them, that do not hold a valid date.
+func clearMalformedDates(v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			clearMalformedDates(item)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			s, isString := value.(string)
+			if !isString || !strings.HasSuffix(key, "_date") {
+				clearMalformedDates(value)
+				continue
+			}
+			if s == "" {
+				continue
+			}
+			if _, err := ParseDate(s); err != nil {
+				fmt.Printf("Ignoring stored %s of %v: %v\n", key, v["id"], err)
+				v[key] = nil
+			}
+		}
+	}
+}
+
+type supabaseTimelineRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseTimelineRepository) Create(ctx context.Context, timeline Timeline) (Timeline, error) {
+	row := map[string]interface{}{
+		"project_id":  timeline.ProjectID,
+		"name":        timeline.Name,
+		"position":    timeline.Position,
+		"start_date":  timeline.StartDate,
+		"end_date":    timeline.EndDate,
+		"description": timeline.Description,
+	}
+
+	data, _, err := r.db.From(ctx, "project_timelines").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return Timeline{}, err
+	}
+
+	var created []Timeline
+	if err := decodeRows(data, &created); err != nil {
+		return Timeline{}, err
+	}
+	if len(created) == 0 {
+		return Timeline{}, fmt.Errorf("no timeline was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseTimelineRepository) Get(ctx context.Context, id string) (Timeline, error) {
+	data, _, err := r.db.From(ctx, "project_timelines").
+		Select("*", "", false).
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Timeline{}, err
+	}
+
+	var timelines []Timeline
+	if err := decodeRows(data, &timelines); err != nil {
+		return Timeline{}, err
+	}
+	if len(timelines) == 0 {
+		return Timeline{}, ErrNotFound
+	}
+	return timelines[0], nil
+}
+
+func (r *supabaseTimelineRepository) ListByProject(ctx context.Context, projectID string) ([]Timeline, error) {
+	data, _, err := r.db.From(ctx, "project_timelines").
+		Select("*", "", false).
+		Eq("project_id", projectID).
+		Order("position", &postgrest.OrderOpts{Ascending: true}).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var timelines []Timeline
+	if err := decodeRows(data, &timelines); err != nil {
+		return nil, err
+	}
+	return timelines, nil
+}
+
+func (r *supabaseTimelineRepository) Update(ctx context.Context, id string, timeline Timeline) (Timeline, error) {
+	updates := map[string]interface{}{
+		"name":        timeline.Name,
+		"start_date":  timeline.StartDate,
+		"end_date":    timeline.EndDate,
+		"description": timeline.Description,
+	}
+
+	data, _, err := r.db.From(ctx, "project_timelines").
+		Update(updates, "", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Timeline{}, err
+	}
+
+	var updated []Timeline
+	if err := decodeRows(data, &updated); err != nil {
+		return Timeline{}, err
+	}
+	if len(updated) == 0 {
+		return Timeline{}, ErrNotFound
+	}
+	return updated[0], nil
+}
+
+// Reorder updates positions one phase at a time; PostgREST offers no
+// transaction, so a failure part way leaves the earlier positions set.
+func (r *supabaseTimelineRepository) Reorder(ctx context.Context, projectID string, ids []string) error {
+	for i, id := range ids {
+		data, _, err := r.db.From(ctx, "project_timelines").
+			Update(map[string]interface{}{"position": i}, "", "").
+			Eq("id", id).
+			Eq("project_id", projectID).
+			Execute()
+		if err != nil {
+			return err
+		}
+
+		var updated []Timeline
+		if err := decodeRows(data, &updated); err != nil {
+			return err
+		}
+		if len(updated) == 0 {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+func (r *supabaseTimelineRepository) Delete(ctx context.Context, id string) error {
+	data, _, err := r.db.From(ctx, "project_timelines").
+		Delete("", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	var deleted []Timeline
+	if err := decodeRows(data, &deleted); err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type supabaseMilestoneRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseMilestoneRepository) Create(ctx context.Context, milestone Milestone) (Milestone, error) {
+	row := map[string]interface{}{
+		"project_id":  milestone.ProjectID,
+		"phase_id":    nullable(milestone.PhaseID),
+		"title":       milestone.Title,
+		"description": milestone.Description,
+		"due_date":    milestone.DueDate,
+		"status":      milestone.Status,
+		"created_by":  milestone.CreatedBy,
+	}
+
+	data, _, err := r.db.From(ctx, "milestones").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return Milestone{}, err
+	}
+
+	var created []Milestone
+	if err := decodeRows(data, &created); err != nil {
+		return Milestone{}, err
+	}
+	if len(created) == 0 {
+		return Milestone{}, fmt.Errorf("no milestone was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseMilestoneRepository) Get(ctx context.Context, id string) (Milestone, error) {
+	data, _, err := r.db.From(ctx, "milestones").
+		Select(milestoneSelect, "", false).
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Milestone{}, err
+	}
+
+	var milestones []Milestone
+	if err := decodeRows(data, &milestones); err != nil {
+		return Milestone{}, err
+	}
+	if len(milestones) == 0 {
+		return Milestone{}, ErrNotFound
+	}
+	return milestones[0], nil
+}
+
+func (r *supabaseMilestoneRepository) ListByProject(ctx context.Context, projectID string) ([]Milestone, error) {
+	data, _, err := r.db.From(ctx, "milestones").
+		Select(milestoneSelect, "", false).
+		Eq("project_id", projectID).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var milestones []Milestone
+	if err := decodeRows(data, &milestones); err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
+
+func (r *supabaseMilestoneRepository) List(ctx context.Context, projectID string, q ListQuery) ([]Milestone, error) {
+	query := r.db.From(ctx, "milestones").
+		Select(milestoneSelect, "", false).
+		Eq("project_id", projectID)
+	data, _, err := q.apply(query).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var milestones []Milestone
+	if err := decodeRows(data, &milestones); err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
+
+func (r *supabaseMilestoneRepository) ListOverdueCandidates(ctx context.Context, now time.Time) ([]Milestone, error) {
+	data, _, err := r.db.From(ctx, "milestones").
+		Select("*", "", false).
+		Or(fmt.Sprintf("and(due_date.lt.%s,status.neq.completed),overdue_since.not.is.null", pgValue(now.UTC().Format(time.RFC3339))), "").
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var milestones []Milestone
+	if err := decodeRows(data, &milestones); err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
+
+func (r *supabaseMilestoneRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (Milestone, error) {
+	data, _, err := r.db.From(ctx, "milestones").
+		Update(updates, "", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Milestone{}, err
+	}
+
+	var updated []Milestone
+	if err := decodeRows(data, &updated); err != nil {
+		return Milestone{}, err
+	}
+	if len(updated) == 0 {
+		return Milestone{}, ErrNotFound
+	}
+	// Re-read so the response carries the embedded tasks.
+	return r.Get(ctx, id)
+}
+
+func (r *supabaseMilestoneRepository) Delete(ctx context.Context, id, onTasks, targetID string) error {
+	params := map[string]interface{}{
+		"p_milestone_id": id,
+		"p_on_tasks":     onTasks,
+	}
+	if targetID != "" {
+		params["p_target_milestone_id"] = targetID
+	}
+	_, err := r.db.RPC(ctx, "delete_milestone", params)
+	switch {
+	case err == nil:
+		return nil
+	case strings.HasPrefix(err.Error(), "(P0002) "):
+		return ErrNotFound
+	case strings.HasPrefix(err.Error(), "(23503) "):
+		return ErrConflict
+	}
+	return err
+}
+
+type supabaseTaskRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseTaskRepository) Create(ctx context.Context, task Task) (Task, error) {
+	row := map[string]interface{}{
+		"milestone_id": task.MilestoneID,
+		"title":        task.Title,
+		"description":  task.Description,
+		"assignee_id":  task.AssigneeID,
+		"due_date":     task.DueDate,
+		"status":       task.Status,
+		"reviewed":     task.Reviewed,
+		"created_by":   task.CreatedBy,
+		"effort":       task.Effort,
+	}
+
+	data, _, err := r.db.From(ctx, "milestone_tasks").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return Task{}, err
+	}
+
+	var created []Task
+	if err := decodeRows(data, &created); err != nil {
+		return Task{}, err
+	}
+	if len(created) == 0 {
+		return Task{}, fmt.Errorf("no task was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseTaskRepository) Get(ctx context.Context, id string) (Task, error) {
+	data, _, err := r.db.From(ctx, "milestone_tasks").
+		Select("*", "", false).
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Task{}, err
+	}
+
+	var tasks []Task
+	if err := decodeRows(data, &tasks); err != nil {
+		return Task{}, err
+	}
+	if len(tasks) == 0 {
+		return Task{}, ErrNotFound
+	}
+	return tasks[0], nil
+}
+
+func (r *supabaseTaskRepository) ListByMilestone(ctx context.Context, milestoneID string) ([]Task, error) {
+	data, _, err := r.db.From(ctx, "milestone_tasks").
+		Select("*", "", false).
+		Eq("milestone_id", milestoneID).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	if err := decodeRows(data, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// assignedTaskRow is a task row with its milestone embedded by PostgREST.
+type assignedTaskRow struct {
+	Task
+	Milestone struct {
+		ProjectID string `json:"project_id"`
+		Title     string `json:"title"`
+		DueDate   Date   `json:"due_date"`
+	} `json:"milestones"`
+}
+
+func (r *supabaseTaskRepository) ListByAssignee(ctx context.Context, assigneeID string) ([]AssignedTask, error) {
+	data, _, err := r.db.From(ctx, "milestone_tasks").
+		Select("*, milestones!inner(project_id, title, due_date)", "", false).
+		Eq("assignee_id", assigneeID).
+		Order("due_date", &postgrest.OrderOpts{Ascending: true}).
+		Order("id", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []assignedTaskRow
+	if err := decodeRows(data, &rows); err != nil {
+		return nil, err
+	}
+	tasks := make([]AssignedTask, len(rows))
+	for i, row := range rows {
+		tasks[i] = AssignedTask{
+			Task:             row.Task,
+			ProjectID:        row.Milestone.ProjectID,
+			MilestoneTitle:   row.Milestone.Title,
+			MilestoneDueDate: row.Milestone.DueDate,
+		}
+	}
+	return tasks, nil
+}
+
+func (r *supabaseTaskRepository) ListOverdueCandidates(ctx context.Context, now time.Time) ([]Task, error) {
+	data, _, err := r.db.From(ctx, "milestone_tasks").
+		Select("*", "", false).
+		Or(fmt.Sprintf("and(due_date.lt.%s,status.not.in.(%s,%s)),overdue_since.not.is.null", pgValue(now.UTC().Format(time.RFC3339)), TaskApproved, TaskClosed), "").
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	if err := decodeRows(data, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *supabaseTaskRepository) List(ctx context.Context, milestoneID string, q ListQuery) ([]Task, error) {
+	query := r.db.From(ctx, "milestone_tasks").
+		Select("*", "", false).
+		Eq("milestone_id", milestoneID)
+	data, _, err := q.apply(query).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	if err := decodeRows(data, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *supabaseTaskRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (Task, error) {
+	data, _, err := r.db.From(ctx, "milestone_tasks").
+		Update(updates, "", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Task{}, err
+	}
+
+	var updated []Task
+	if err := decodeRows(data, &updated); err != nil {
+		return Task{}, err
+	}
+	if len(updated) == 0 {
+		return Task{}, ErrNotFound
+	}
+	return updated[0], nil
+}
+
+func (r *supabaseTaskRepository) UpdateIfStatus(ctx context.Context, id, status string, updates map[string]interface{}) (Task, error) {
+	data, _, err := r.db.From(ctx, "milestone_tasks").
+		Update(updates, "", "").
+		Eq("id", id).
+		Eq("status", status).
+		Execute()
+	if err != nil {
+		return Task{}, err
+	}
+
+	var updated []Task
+	if err := decodeRows(data, &updated); err != nil {
+		return Task{}, err
+	}
+	if len(updated) == 0 {
+		return Task{}, ErrConflict
+	}
+	return updated[0], nil
+}
+
+func (r *supabaseTaskRepository) Delete(ctx context.Context, id string) error {
+	data, _, err := r.db.From(ctx, "milestone_tasks").
+		Delete("", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	var deleted []Task
+	if err := decodeRows(data, &deleted); err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type supabaseTaskHistoryRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseTaskHistoryRepository) Append(ctx context.Context, transition TaskTransition) (TaskTransition, error) {
+	row := map[string]interface{}{
+		"task_id":     transition.TaskID,
+		"from_status": nullable(transition.FromStatus),
+		"to_status":   transition.ToStatus,
+		"changed_by":  nullable(transition.ChangedBy),
+		"note":        transition.Note,
+	}
+
+	data, _, err := r.db.From(ctx, "task_status_history").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return TaskTransition{}, err
+	}
+
+	var created []TaskTransition
+	if err := decodeRows(data, &created); err != nil {
+		return TaskTransition{}, err
+	}
+	if len(created) == 0 {
+		return TaskTransition{}, fmt.Errorf("no history entry was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseTaskHistoryRepository) ListByTask(ctx context.Context, taskID string) ([]TaskTransition, error) {
+	data, _, err := r.db.From(ctx, "task_status_history").
+		Select("*", "", false).
+		Eq("task_id", taskID).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var transitions []TaskTransition
+	if err := decodeRows(data, &transitions); err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+type supabaseReviewRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseReviewRepository) Create(ctx context.Context, review TaskReview) (TaskReview, error) {
+	row := map[string]interface{}{
+		"task_id":          review.TaskID,
+		"reviewer_id":      nullable(review.ReviewerID),
+		"verdict":          review.Verdict,
+		"comments":         review.Comments,
+		"submission_round": review.SubmissionRound,
+		"evidence_ids":     nonNil(review.EvidenceIDs),
+		"evidence_sha256":  nonNil(review.EvidenceSHA256),
+	}
+
+	data, _, err := r.db.From(ctx, "task_reviews").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return TaskReview{}, err
+	}
+
+	var created []TaskReview
+	if err := decodeRows(data, &created); err != nil {
+		return TaskReview{}, err
+	}
+	if len(created) == 0 {
+		return TaskReview{}, fmt.Errorf("no review was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseReviewRepository) ListByTask(ctx context.Context, taskID string) ([]TaskReview, error) {
+	data, _, err := r.db.From(ctx, "task_reviews").
+		Select("*", "", false).
+		Eq("task_id", taskID).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var reviews []TaskReview
+	if err := decodeRows(data, &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *supabaseReviewRepository) Delete(ctx context.Context, id string) error {
+	_, _, err := r.db.From(ctx, "task_reviews").
+		Delete("minimal", "").
+		Eq("id", id).
+		Execute()
+	return err
+}
+
+type supabaseEvidenceRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseEvidenceRepository) Create(ctx context.Context, evidence Evidence, quotaBytes int64) (Evidence, error) {
+	// The function checks the quota and the stored file in the same
+	// transaction as the insert
+	params := map[string]interface{}{
+		"p_task_id":          evidence.TaskID,
+		"p_project_id":       evidence.ProjectID,
+		"p_kind":             evidence.Kind,
+		"p_file_name":        nullable(evidence.FileName),
+		"p_url":              nullable(evidence.URL),
+		"p_body":             nullable(evidence.Body),
+		"p_mime_type":        nullable(evidence.MimeType),
+		"p_size":             evidence.Size,
+		"p_sha256":           nullable(evidence.SHA256),
+		"p_submission_round": evidence.SubmissionRound,
+		"p_uploaded_by":      nullable(evidence.UploadedBy),
+		"p_quota_bytes":      quotaBytes,
+	}
+	data, err := r.db.RPC(ctx, "create_task_evidence", params)
+	switch {
+	case err == nil:
+	case strings.HasPrefix(err.Error(), "(53400) "):
+		return Evidence{}, ErrQuotaExceeded
+	case strings.HasPrefix(err.Error(), "(55000) "):
+		return Evidence{}, ErrConflict
+	case strings.HasPrefix(err.Error(), "(23503) "):
+		return Evidence{}, ErrNotFound
+	default:
+		return Evidence{}, err
+	}
+
+	var created []Evidence
+	if err := decodeRows(data, &created); err != nil {
+		return Evidence{}, err
+	}
+	if len(created) == 0 {
+		return Evidence{}, fmt.Errorf("no evidence was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseEvidenceRepository) Get(ctx context.Context, id string) (Evidence, error) {
+	data, _, err := r.db.From(ctx, "task_evidence").
+		Select("*", "", false).
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Evidence{}, err
+	}
+
+	var items []Evidence
+	if err := decodeRows(data, &items); err != nil {
+		return Evidence{}, err
+	}
+	if len(items) == 0 {
+		return Evidence{}, ErrNotFound
+	}
+	return items[0], nil
+}
+
+func (r *supabaseEvidenceRepository) ListByTask(ctx context.Context, taskID string) ([]Evidence, error) {
+	data, _, err := r.db.From(ctx, "task_evidence").
+		Select("*", "", false).
+		Eq("task_id", taskID).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Evidence
+	if err := decodeRows(data, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *supabaseEvidenceRepository) Delete(ctx context.Context, id string) error {
+	data, _, err := r.db.From(ctx, "task_evidence").
+		Delete("", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	var deleted []Evidence
+	if err := decodeRows(data, &deleted); err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *supabaseEvidenceRepository) ProjectUsage(ctx context.Context, projectID string) (int64, error) {
+	data, _, err := r.db.From(ctx, "task_evidence").
+		Select("size", "", false).
+		Eq("project_id", projectID).
+		Eq("kind", EvidenceFile).
+		Execute()
+	if err != nil {
+		return 0, err
+	}
+
+	var rows []struct {
+		Size int64 `json:"size"`
+	}
+	if err := decodeRows(data, &rows); err != nil {
+		return 0, err
+	}
+	var used int64
+	for _, row := range rows {
+		used += row.Size
+	}
+	return used, nil
+}
+
+func (r *supabaseEvidenceRepository) ClaimFile(ctx context.Context, sha256 string) error {
+	_, err := r.db.RPC(ctx, "claim_evidence_file", map[string]interface{}{"p_sha256": sha256})
+	if err != nil && strings.HasPrefix(err.Error(), "(55000) ") {
+		return ErrConflict
+	}
+	return err
+}
+
+func (r *supabaseEvidenceRepository) CollectFiles(ctx context.Context, unusedFor time.Duration) ([]string, error) {
+	data, err := r.db.RPC(ctx, "collect_evidence_files", map[string]interface{}{
+		"p_unused_seconds": int64(unusedFor / time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []string{}
+	if err := decodeRows(data, &hashes); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func (r *supabaseEvidenceRepository) ForgetFile(ctx context.Context, sha256 string) error {
+	_, _, err := r.db.From(ctx, "evidence_files").
+		Delete("minimal", "").
+		Eq("sha256", sha256).
+		Eq("state", "collecting").
+		Execute()
+	return err
+}
+
+type supabaseUploadPolicyRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseUploadPolicyRepository) Get(ctx context.Context, projectID string) (UploadPolicy, error) {
+	data, _, err := r.db.From(ctx, "project_upload_policies").
+		Select("*", "", false).
+		Eq("project_id", projectID).
+		Execute()
+	if err != nil {
+		return UploadPolicy{}, err
+	}
+
+	var policies []UploadPolicy
+	if err := decodeRows(data, &policies); err != nil {
+		return UploadPolicy{}, err
+	}
+	if len(policies) == 0 {
+		return UploadPolicy{}, ErrNotFound
+	}
+	return policies[0], nil
+}
+
+func (r *supabaseUploadPolicyRepository) Save(ctx context.Context, policy UploadPolicy) (UploadPolicy, error) {
+	row := map[string]interface{}{
+		"project_id":         policy.ProjectID,
+		"allowed_mime_types": policy.AllowedMimeTypes,
+		"max_file_bytes":     policy.MaxFileBytes,
+		"quota_bytes":        policy.QuotaBytes,
+		"updated_at":         now(),
+	}
+
+	data, _, err := r.db.From(ctx, "project_upload_policies").
+		Upsert(row, "project_id", "", "").
+		Execute()
+	if err != nil {
+		return UploadPolicy{}, err
+	}
+
+	var saved []UploadPolicy
+	if err := decodeRows(data, &saved); err != nil {
+		return UploadPolicy{}, err
+	}
+	if len(saved) == 0 {
+		return UploadPolicy{}, fmt.Errorf("no upload policy was saved")
+	}
+	return saved[0], nil
+}
+
+type supabaseMembershipRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseMembershipRepository) ProjectLead(ctx context.Context, projectID string) (string, error) {
+	data, _, err := r.db.From(ctx, "ideas").
+		Select("uid", "", false).
+		Eq("id", projectID).
+		Execute()
+	if err != nil {
+		return "", err
+	}
+
+	var ideas []struct {
+		UID string `json:"uid"`
+	}
+	if err := decodeRows(data, &ideas); err != nil {
+		return "", err
+	}
+	if len(ideas) == 0 {
+		return "", ErrNotFound
+	}
+	return ideas[0].UID, nil
+}
+
+func (r *supabaseMembershipRepository) ProjectRole(ctx context.Context, projectID, userID string) (Role, error) {
+	data, _, err := r.db.From(ctx, "ideas").
+		Select("uid", "", false).
+		Eq("id", projectID).
+		Execute()
+	if err != nil {
+		return RoleNone, err
+	}
+
+	var ideas []struct {
+		UID string `json:"uid"`
+	}
+	if err := decodeRows(data, &ideas); err != nil {
+		return RoleNone, err
+	}
+	if len(ideas) == 0 {
+		return RoleNone, ErrNotFound
+	}
+	if ideas[0].UID == userID {
+		return RoleLead, nil
+	}
+
+	data, _, err = r.db.From(ctx, "idea_contributors").
+		Select("role,approved_status", "", false).
+		Eq("idea_id", projectID).
+		Eq("user_id", userID).
+		Execute()
+	if err != nil {
+		return RoleNone, err
+	}
+
+	var contributions []Contribution
+	if err := decodeRows(data, &contributions); err != nil {
+		return RoleNone, err
+	}
+	return roleFromContributions(contributions), nil
+}
+
+type supabaseLockRepository struct {
+	db *supabaseDB
+}
+
+// TryAcquire renews the lock if the caller holds it or it has expired,
+// and otherwise tries to create it; the primary key on name makes only
+// one concurrent insert succeed.
+func (r *supabaseLockRepository) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	current := time.Now().UTC()
+	row := map[string]interface{}{
+		"holder":     holder,
+		"expires_at": current.Add(ttl).Format(time.RFC3339Nano),
+	}
+
+	data, _, err := r.db.From(ctx, "scheduler_locks").
+		Update(row, "", "").
+		Eq("name", name).
+		Or(fmt.Sprintf("holder.eq.%s,expires_at.lt.%s", pgValue(holder), pgValue(current.Format(time.RFC3339Nano))), "").
+		Execute()
+	if err != nil {
+		return false, err
+	}
+	var renewed []map[string]interface{}
+	if err := decodeRows(data, &renewed); err != nil {
+		return false, err
+	}
+	if len(renewed) > 0 {
+		return true, nil
+	}
+
+	row["name"] = name
+	if _, _, err := r.db.From(ctx, "scheduler_locks").Insert(row, false, "", "", "").Execute(); err != nil {
+		if strings.Contains(err.Error(), "(23505)") {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type supabaseNotificationRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseNotificationRepository) Create(ctx context.Context, notification Notification) (Notification, error) {
+	row := map[string]interface{}{
+		"user_id":      notification.UserID,
+		"project_id":   notification.ProjectID,
+		"type":         notification.Type,
+		"title":        notification.Title,
+		"body":         notification.Body,
+		"milestone_id": nullable(notification.MilestoneID),
+		"task_id":      nullable(notification.TaskID),
+		"event_id":     notification.EventID,
+	}
+
+	data, _, err := r.db.From(ctx, "notifications").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return Notification{}, err
+	}
+
+	var created []Notification
+	if err := decodeRows(data, &created); err != nil {
+		return Notification{}, err
+	}
+	if len(created) == 0 {
+		return Notification{}, fmt.Errorf("no notification was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseNotificationRepository) ListByUser(ctx context.Context, userID string, unreadOnly bool, limit int) ([]Notification, error) {
+	query := r.db.From(ctx, "notifications").
+		Select("*", "", false).
+		Eq("user_id", userID)
+	if unreadOnly {
+		query = query.Is("read_at", "null")
+	}
+	data, _, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Order("id", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var notifications []Notification
+	if err := decodeRows(data, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *supabaseNotificationRepository) CountUnread(ctx context.Context, userID string) (int, error) {
+	_, count, err := r.db.From(ctx, "notifications").
+		Select("id", "exact", true).
+		Eq("user_id", userID).
+		Is("read_at", "null").
+		Execute()
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func (r *supabaseNotificationRepository) MarkRead(ctx context.Context, userID, id string) (Notification, error) {
+	data, _, err := r.db.From(ctx, "notifications").
+		Update(map[string]interface{}{"read_at": now()}, "", "").
+		Eq("id", id).
+		Eq("user_id", userID).
+		Is("read_at", "null").
+		Execute()
+	if err != nil {
+		return Notification{}, err
+	}
+
+	var updated []Notification
+	if err := decodeRows(data, &updated); err != nil {
+		return Notification{}, err
+	}
+	if len(updated) > 0 {
+		return updated[0], nil
+	}
+
+	// Already read, or not the user's notification
+	data, _, err = r.db.From(ctx, "notifications").
+		Select("*", "", false).
+		Eq("id", id).
+		Eq("user_id", userID).
+		Execute()
+	if err != nil {
+		return Notification{}, err
+	}
+	var existing []Notification
+	if err := decodeRows(data, &existing); err != nil {
+		return Notification{}, err
+	}
+	if len(existing) == 0 {
+		return Notification{}, ErrNotFound
+	}
+	return existing[0], nil
+}
+
+func (r *supabaseNotificationRepository) MarkAllRead(ctx context.Context, userID string) error {
+	_, _, err := r.db.From(ctx, "notifications").
+		Update(map[string]interface{}{"read_at": now()}, "minimal", "").
+		Eq("user_id", userID).
+		Is("read_at", "null").
+		Execute()
+	return err
+}
+
+type supabaseNotificationPreferenceRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseNotificationPreferenceRepository) Get(ctx context.Context, userID string) (NotificationPreferences, error) {
+	data, _, err := r.db.From(ctx, "notification_preferences").
+		Select("*", "", false).
+		Eq("user_id", userID).
+		Execute()
+	if err != nil {
+		return NotificationPreferences{}, err
+	}
+
+	var prefs []NotificationPreferences
+	if err := decodeRows(data, &prefs); err != nil {
+		return NotificationPreferences{}, err
+	}
+	if len(prefs) == 0 {
+		return NotificationPreferences{}, ErrNotFound
+	}
+	return prefs[0], nil
+}
+
+func (r *supabaseNotificationPreferenceRepository) Save(ctx context.Context, prefs NotificationPreferences) (NotificationPreferences, error) {
+	row := map[string]interface{}{
+		"user_id":         prefs.UserID,
+		"email":           prefs.Email,
+		"email_enabled":   prefs.EmailEnabled,
+		"webhook_url":     prefs.WebhookURL,
+		"webhook_enabled": prefs.WebhookEnabled,
+		"muted_types":     prefs.MutedTypes,
+		"updated_at":      now(),
+	}
+
+	data, _, err := r.db.From(ctx, "notification_preferences").
+		Upsert(row, "user_id", "", "").
+		Execute()
+	if err != nil {
+		return NotificationPreferences{}, err
+	}
+
+	var saved []NotificationPreferences
+	if err := decodeRows(data, &saved); err != nil {
+		return NotificationPreferences{}, err
+	}
+	if len(saved) == 0 {
+		return NotificationPreferences{}, fmt.Errorf("no notification preferences were saved")
+	}
+	return saved[0], nil
+}
+
+// supabaseWebhookRepository stores signing secrets sealed in
+// secret_encrypted and opens them when webhooks are read.
+type supabaseWebhookRepository struct {
+	db      *supabaseDB
+	secrets *secretBox
+}
+
+// webhookRow is a project_webhooks row.
+type webhookRow struct {
+	Webhook
+	SecretEncrypted string `json:"secret_encrypted"`
+}
+
+// decode unmarshals webhook rows and opens their secrets.
+func (r *supabaseWebhookRepository) decode(data []byte) ([]Webhook, error) {
+	var rows []webhookRow
+	if err := decodeRows(data, &rows); err != nil {
+		return nil, err
+	}
+	webhooks := make([]Webhook, len(rows))
+	for i, row := range rows {
+		secret, err := r.secrets.open(row.SecretEncrypted)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", row.ID, err)
+		}
+		webhooks[i] = row.Webhook
+		webhooks[i].Secret = secret
+	}
+	return webhooks, nil
+}
+
+func (r *supabaseWebhookRepository) Create(ctx context.Context, webhook Webhook) (Webhook, error) {
+	sealed, err := r.secrets.seal(webhook.Secret)
+	if err != nil {
+		return Webhook{}, err
+	}
+	row := map[string]interface{}{
+		"project_id":       webhook.ProjectID,
+		"url":              webhook.URL,
+		"events":           webhook.Events,
+		"secret_encrypted": sealed,
+		"active":           webhook.Active,
+		"created_by":       webhook.CreatedBy,
+	}
+
+	data, _, err := r.db.From(ctx, "project_webhooks").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	created, err := r.decode(data)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if len(created) == 0 {
+		return Webhook{}, fmt.Errorf("no webhook was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseWebhookRepository) Get(ctx context.Context, id string) (Webhook, error) {
+	data, _, err := r.db.From(ctx, "project_webhooks").
+		Select("*", "", false).
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	webhooks, err := r.decode(data)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if len(webhooks) == 0 {
+		return Webhook{}, ErrNotFound
+	}
+	return webhooks[0], nil
+}
+
+func (r *supabaseWebhookRepository) ListByProject(ctx context.Context, projectID string) ([]Webhook, error) {
+	data, _, err := r.db.From(ctx, "project_webhooks").
+		Select("*", "", false).
+		Eq("project_id", projectID).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := r.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *supabaseWebhookRepository) ListForEvent(ctx context.Context, projectID, eventType string) ([]Webhook, error) {
+	data, _, err := r.db.From(ctx, "project_webhooks").
+		Select("*", "", false).
+		Eq("project_id", projectID).
+		Eq("active", "true").
+		Contains("events", []string{eventType}).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := r.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *supabaseWebhookRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (Webhook, error) {
+	row := map[string]interface{}{"updated_at": now()}
+	for k, v := range updates {
+		row[k] = v
+	}
+
+	data, _, err := r.db.From(ctx, "project_webhooks").
+		Update(row, "", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	updated, err := r.decode(data)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if len(updated) == 0 {
+		return Webhook{}, ErrNotFound
+	}
+	return updated[0], nil
+}
+
+// Delete relies on the foreign key to remove the delivery log.
+func (r *supabaseWebhookRepository) Delete(ctx context.Context, id string) error {
+	data, _, err := r.db.From(ctx, "project_webhooks").
+		Delete("", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return err
+	}
+
+	var deleted []Webhook
+	if err := decodeRows(data, &deleted); err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type supabaseWebhookDeliveryRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseWebhookDeliveryRepository) Create(ctx context.Context, delivery WebhookDelivery) (WebhookDelivery, error) {
+	row := map[string]interface{}{
+		"webhook_id":      delivery.WebhookID,
+		"project_id":      delivery.ProjectID,
+		"event_id":        delivery.EventID,
+		"event_type":      delivery.EventType,
+		"payload":         delivery.Payload,
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": nullable(delivery.NextAttemptAt),
+		"replay_of":       nullable(delivery.ReplayOf),
+	}
+
+	data, _, err := r.db.From(ctx, "webhook_deliveries").Insert(row, false, "", "", "").Execute()
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	var created []WebhookDelivery
+	if err := decodeRows(data, &created); err != nil {
+		return WebhookDelivery{}, err
+	}
+	if len(created) == 0 {
+		return WebhookDelivery{}, fmt.Errorf("no webhook delivery was created")
+	}
+	return created[0], nil
+}
+
+func (r *supabaseWebhookDeliveryRepository) Get(ctx context.Context, id string) (WebhookDelivery, error) {
+	data, _, err := r.db.From(ctx, "webhook_deliveries").
+		Select("*", "", false).
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	var deliveries []WebhookDelivery
+	if err := decodeRows(data, &deliveries); err != nil {
+		return WebhookDelivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return WebhookDelivery{}, ErrNotFound
+	}
+	return deliveries[0], nil
+}
+
+func (r *supabaseWebhookDeliveryRepository) ListByWebhook(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	data, _, err := r.db.From(ctx, "webhook_deliveries").
+		Select("*", "", false).
+		Eq("webhook_id", webhookID).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Order("id", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []WebhookDelivery
+	if err := decodeRows(data, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *supabaseWebhookDeliveryRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	data, _, err := r.db.From(ctx, "webhook_deliveries").
+		Select("*", "", false).
+		Eq("status", DeliveryPending).
+		Lte("next_attempt_at", now.UTC().Format(time.RFC3339Nano)).
+		Order("next_attempt_at", &postgrest.OrderOpts{Ascending: true}).
+		Limit(limit, "").
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []WebhookDelivery
+	if err := decodeRows(data, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *supabaseWebhookDeliveryRepository) Update(ctx context.Context, id string, updates map[string]interface{}) (WebhookDelivery, error) {
+	data, _, err := r.db.From(ctx, "webhook_deliveries").
+		Update(updates, "", "").
+		Eq("id", id).
+		Execute()
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	var updated []WebhookDelivery
+	if err := decodeRows(data, &updated); err != nil {
+		return WebhookDelivery{}, err
+	}
+	if len(updated) == 0 {
+		return WebhookDelivery{}, ErrNotFound
+	}
+	return updated[0], nil
+}
+
+type supabaseAuditRepository struct {
+	db *supabaseDB
+}
+
+func (r *supabaseAuditRepository) Append(ctx context.Context, entries []AuditEntry) error {
+	rows := make([]map[string]interface{}, len(entries))
+	for i, entry := range entries {
+		rows[i] = map[string]interface{}{
+			"request_id":  entry.RequestID,
+			"actor_id":    entry.ActorID,
+			"method":      entry.Method,
+			"endpoint":    entry.Endpoint,
+			"path":        entry.Path,
+			"status":      entry.Status,
+			"project_id":  nullable(entry.ProjectID),
+			"entity_type": entry.EntityType,
+			"entity_id":   entry.EntityID,
+			"action":      entry.Action,
+			"changes":     entry.Changes,
+		}
+	}
+
+	_, _, err := r.db.From(ctx, "audit_log").Insert(rows, false, "", "minimal", "").Execute()
+	return err
+}
+
+func (r *supabaseAuditRepository) List(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	query := r.db.From(ctx, "audit_log").Select("*", "", false)
+
+	// Filters share one And so that the since/until and cursor conditions
+	// on created_at do not overwrite each other.
+	var conditions []string
+	for column, value := range map[string]string{
+		"project_id":  q.ProjectID,
+		"entity_type": q.EntityType,
+		"entity_id":   q.EntityID,
+		"actor_id":    q.ActorID,
+	} {
+		if value != "" {
+			conditions = append(conditions, fmt.Sprintf("%s.eq.%s", column, pgValue(value)))
+		}
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "created_at.gte."+pgValue(q.Since.UTC().Format(time.RFC3339Nano)))
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "created_at.lt."+pgValue(q.Until.UTC().Format(time.RFC3339Nano)))
+	}
+	if q.Before != nil {
+		at, id := pgValue(q.Before.CreatedAt), pgValue(q.Before.ID)
+		conditions = append(conditions, fmt.Sprintf("or(created_at.lt.%s,and(created_at.eq.%s,id.lt.%s))", at, at, id))
+	}
+	if len(conditions) > 0 {
+		query = query.And(strings.Join(conditions, ","), "")
+	}
+
+	query = query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Order("id", &postgrest.OrderOpts{Ascending: false})
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit, "")
+	}
+	data, _, err := query.Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	if err := decodeRows(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Task lifecycle states.
+const (
+	TaskPending          = "pending"
+	TaskInProgress       = "in_progress"
+	TaskSubmitted        = "submitted"
+	TaskChangesRequested = "changes_requested"
+	TaskApproved         = "approved"
+	TaskClosed           = "closed"
+
+	// taskLegacyCompleted is the pre-lifecycle "done" status. It is still
+	// accepted from older clients and treated as submitted.
+	taskLegacyCompleted = "completed"
+)
+
+// taskTransitions lists the statuses each status may move to. Moves out of
+// submitted are review verdicts: approve, request changes, or reject
+// (which closes the task unapproved).
+var taskTransitions = map[string][]string{
+	TaskPending:          {TaskInProgress},
+	TaskInProgress:       {TaskSubmitted},
+	TaskSubmitted:        {TaskChangesRequested, TaskApproved, TaskClosed},
+	TaskChangesRequested: {TaskInProgress, TaskSubmitted},
+	TaskApproved:         {TaskClosed},
+	TaskClosed:           {},
+}
+
+// normalizeTaskStatus maps legacy status values onto the lifecycle.
+func normalizeTaskStatus(status string) string {
+	if status == taskLegacyCompleted {
+		return TaskSubmitted
+	}
+	return status
+}
+
+// validTaskStatus reports whether status is part of the lifecycle.
+func validTaskStatus(status string) bool {
+	_, ok := taskTransitions[status]
+	return ok
+}
+
+// canTransition reports whether a task may move from one status to another.
+func canTransition(from, to string) bool {
+	return contains(taskTransitions[normalizeTaskStatus(from)], to)
+}
+
+// transitionError reports a status change the lifecycle does not allow.
+type transitionError struct {
+	from, to string
+}
+
+func (e *transitionError) Error() string {
+	return fmt.Sprintf("cannot move task from %s to %s", e.from, e.to)
+}
+
+// transitionTask moves a task to a new status, applying any extra column
+// updates in the same write, and records the change in the task history.
+func (s *server) transitionTask(ctx context.Context, task Task, to, actor, note string, extra map[string]interface{}) (Task, error) {
+	to = normalizeTaskStatus(to)
+	if !canTransition(task.Status, to) {
+		return Task{}, &transitionError{from: task.Status, to: to}
+	}
+
+	updates := map[string]interface{}{"status": to}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	switch to {
+	case TaskSubmitted:
+		// Each submission opens a new review round.
+		updates["submission_round"] = task.SubmissionRound + 1
+	case TaskApproved:
+		updates["reviewed"] = true
+	case TaskChangesRequested, TaskInProgress:
+		updates["reviewed"] = false
+	case TaskClosed:
+		// Closing keeps an approval but is a rejection otherwise, whatever
+		// a legacy task's reviewed flag says.
+		updates["reviewed"] = normalizeTaskStatus(task.Status) == TaskApproved
+	}
+
+	updated, err := s.tasks.UpdateIfStatus(ctx, task.ID, task.Status, updates)
+	if err != nil {
+		return Task{}, err
+	}
+	recordAudit(ctx, "task", task.ID, task, updated)
+
+	if _, err := s.history.Append(ctx, TaskTransition{
+		TaskID:     task.ID,
+		FromStatus: task.Status,
+		ToStatus:   to,
+		ChangedBy:  actor,
+		Note:       note,
+	}); err != nil {
+		// The status change itself succeeded; surface the logging failure
+		// without pretending the update did not happen.
+		fmt.Printf("Error recording task transition: %v\n", err)
+	}
+
+	s.syncMilestoneStatus(ctx, updated.MilestoneID)
+
+	s.publishTaskEvent(ctx, EventTaskStatusChanged, updated, actor, map[string]interface{}{
+		"from": task.Status,
+		"to":   to,
+		"note": note,
+	})
+
+	return updated, nil
+}
+
+// errReviewRequired is returned when a submitted task is moved without a review.
+var errReviewRequired = errors.New("submitted tasks change status through POST /api/tasks/{taskId}/review")
+
+// manualTransition is transitionTask for direct status edits, which may
+// not decide the outcome of a submission.
+func (s *server) manualTransition(ctx context.Context, task Task, to, actor, note string, extra map[string]interface{}) (Task, error) {
+	if normalizeTaskStatus(task.Status) == TaskSubmitted {
+		return Task{}, errReviewRequired
+	}
+	return s.transitionTask(ctx, task, to, actor, note, extra)
+}
+
+// writeTransitionError reports a failed status change.
+func writeTransitionError(w http.ResponseWriter, err error) {
+	var terr *transitionError
+	switch {
+	case errors.As(err, &terr):
+		writeError(w, terr.Error(), http.StatusConflict)
+	case errors.Is(err, errReviewRequired):
+		writeError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrConflict):
+		writeError(w, "Task status changed concurrently; reload and retry", http.StatusConflict)
+	default:
+		writeRepoError(w, err, "Task not found")
+	}
+}
+
+// Change task status endpoint
+func (s *server) handleTransitionTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	var req TransitionTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validTaskStatus(normalizeTaskStatus(req.Status)) {
+		writeError(w, "Invalid status value", http.StatusBadRequest)
+		return
+	}
+
+	task, _, ok := s.authorizeTaskWork(w, r, taskId)
+	if !ok {
+		return
+	}
+
+	updated, err := s.manualTransition(r.Context(), task, req.Status, currentUserID(r), req.Note, nil)
+	if err != nil {
+		fmt.Printf("Error changing task status: %v\n", err)
+		writeTransitionError(w, err)
+		return
+	}
+
+	writeJSON(w, updated)
+}
+
+// Task status history endpoint
+func (s *server) handleTaskHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskId := mux.Vars(r)["taskId"]
+
+	if _, _, ok := s.authorizeTask(w, r, taskId, anyMember); !ok {
+		return
+	}
+
+	history, err := s.history.ListByTask(r.Context(), taskId)
+	if err != nil {
+		fmt.Printf("Error fetching task history: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, history)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"imara-shared/config"
+)
+
+// defaultAllowedMimeTypes are the evidence file types accepted when the
+// uploads.allowed_types setting is empty.
+var defaultAllowedMimeTypes = []string{
+	"application/pdf",
+	"application/zip",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"text/plain",
+	"video/mp4",
+}
+
+// officeTypes maps the extensions of Office Open XML documents, which
+// sniff as plain zip archives, to their own MIME types.
+var officeTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// uploadLimits are the server-wide evidence upload limits. Project
+// policies may narrow them but never widen them.
+type uploadLimits struct {
+	allowedTypes []string
+	maxFileBytes int64
+	quotaBytes   int64
+	// memoryBytes is how much of an upload is buffered in memory before
+	// the rest spills to a temporary file.
+	memoryBytes int64
+}
+
+// newUploadLimits takes the limits from the upload settings, using
+// defaultAllowedMimeTypes when no types are configured.
+func newUploadLimits(cfg config.Uploads) uploadLimits {
+	limits := uploadLimits{
+		allowedTypes: cfg.AllowedTypes,
+		maxFileBytes: cfg.MaxFileBytes,
+		quotaBytes:   cfg.QuotaBytes,
+		memoryBytes:  cfg.MemoryBytes,
+	}
+	if len(limits.allowedTypes) == 0 {
+		limits.allowedTypes = defaultAllowedMimeTypes
+	}
+	return limits
+}
+
+// effective fills the unset fields of a project's policy with the limits.
+func (l uploadLimits) effective(policy UploadPolicy) UploadPolicy {
+	if len(policy.AllowedMimeTypes) == 0 {
+		policy.AllowedMimeTypes = l.allowedTypes
+	}
+	if policy.MaxFileBytes == 0 {
+		policy.MaxFileBytes = l.maxFileBytes
+	}
+	if policy.QuotaBytes == 0 {
+		policy.QuotaBytes = l.quotaBytes
+	}
+	return policy
+}
+
+// validate checks that a project policy stays within the limits.
+func (l uploadLimits) validate(policy UploadPolicy) error {
+	for _, t := range policy.AllowedMimeTypes {
+		if !mimeTypeAllowed(l.allowedTypes, t) {
+			return fmt.Errorf("%s is not an allowed type on this server", t)
+		}
+	}
+	if policy.MaxFileBytes < 0 || policy.MaxFileBytes > l.maxFileBytes {
+		return fmt.Errorf("max_file_bytes must be between 0 and %d", l.maxFileBytes)
+	}
+	if policy.QuotaBytes < 0 || policy.QuotaBytes > l.quotaBytes {
+		return fmt.Errorf("quota_bytes must be between 0 and %d", l.quotaBytes)
+	}
+	return nil
+}
+
+// mimeTypeAllowed reports whether mediaType matches an entry of allowed,
+// where "type/*" matches any subtype.
+func mimeTypeAllowed(allowed []string, mediaType string) bool {
+	for _, a := range allowed {
+		if a == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffMimeType detects a file's type from its content, ignoring the type
+// the client claimed. The file is left positioned at its start.
+func sniffMimeType(file io.ReadSeeker, fileName string) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	sniffed := http.DetectContentType(head[:n])
+	if sniffed == "application/zip" {
+		if office, ok := officeTypes[strings.ToLower(path.Ext(fileName))]; ok {
+			return office, nil
+		}
+	}
+	return sniffed, nil
+}
+
+// sanitizeFileName reduces a client-supplied file name to a safe display
+// name: no directories, control characters or invalid UTF-8, and at most
+// 255 bytes. It is only used for Content-Disposition; files are stored
+// under their SHA-256.
+func sanitizeFileName(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), ".")
+
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "evidence"
+	}
+	return name
+}
+
+// mediaTypeOf strips parameters such as charset from a MIME type.
+func mediaTypeOf(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return mimeType
+	}
+	return mediaType
+}
+
+// uploadPolicy returns a project's effective upload policy.
+func (s *server) uploadPolicy(r *http.Request, projectID string) (UploadPolicy, error) {
+	policy, err := s.policies.Get(r.Context(), projectID)
+	if errors.Is(err, ErrNotFound) {
+		policy, err = UploadPolicy{ProjectID: projectID}, nil
+	}
+	if err != nil {
+		return UploadPolicy{}, err
+	}
+	return s.uploadLimits.effective(policy), nil
+}
+
+// Get project upload policy endpoint
+func (s *server) handleGetUploadPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	if _, ok := s.authorizeProject(w, r, projectId, anyMember); !ok {
+		return
+	}
+
+	s.writeUploadPolicy(w, r, projectId)
+}
+
+// Update project upload policy endpoint
+func (s *server) handleUpdateUploadPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+
+	var policy UploadPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		fmt.Printf("Error decoding upload policy: %v\n", err)
+		writeBodyError(w, err)
+		return
+	}
+	policy.ProjectID = projectId
+	for i, t := range policy.AllowedMimeTypes {
+		policy.AllowedMimeTypes[i] = strings.ToLower(strings.TrimSpace(t))
+	}
+	if err := s.uploadLimits.validate(policy); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := s.authorizeProject(w, r, projectId, leadOnly); !ok {
+		return
+	}
+
+	var before interface{}
+	if existing, err := s.policies.Get(r.Context(), projectId); err == nil {
+		before = existing
+	} else if !errors.Is(err, ErrNotFound) {
+		fmt.Printf("Error fetching upload policy: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	saved, err := s.policies.Save(r.Context(), policy)
+	if err != nil {
+		fmt.Printf("Error saving upload policy: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	recordAudit(r.Context(), "upload_policy", projectId, before, saved)
+
+	s.writeUploadPolicy(w, r, projectId)
+}
+
+// writeUploadPolicy responds with a project's effective policy and usage.
+func (s *server) writeUploadPolicy(w http.ResponseWriter, r *http.Request, projectID string) {
+	policy, err := s.uploadPolicy(r, projectID)
+	if err != nil {
+		fmt.Printf("Error fetching upload policy: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	used, err := s.evidence.ProjectUsage(r.Context(), projectID)
+	if err != nil {
+		fmt.Printf("Error fetching evidence usage: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSON(w, UploadPolicyResponse{UploadPolicy: policy, UsedBytes: used})
+}
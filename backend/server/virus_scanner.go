@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"imara-shared/config"
+)
+
+// ScanResult is a virus scanner's verdict on one file.
+type ScanResult struct {
+	Clean bool `json:"clean"`
+	// Signature names the detected threat when Clean is false.
+	Signature string `json:"signature,omitempty"`
+}
+
+// VirusScanner inspects uploaded evidence before it is stored.
+type VirusScanner interface {
+	Scan(ctx context.Context, r io.Reader) (ScanResult, error)
+}
+
+// noopScanner accepts every file. It is used when no scanner is configured.
+type noopScanner struct{}
+
+func (noopScanner) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	return ScanResult{Clean: true}, nil
+}
+
+// ClamdScanner streams files to a clamd daemon using its INSTREAM command.
+type ClamdScanner struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamdScanner returns a scanner for the clamd socket at addr, either
+// host:port or the path of a unix socket.
+func NewClamdScanner(addr string) *ClamdScanner {
+	network := "tcp"
+	if strings.HasPrefix(addr, "/") {
+		network = "unix"
+	}
+	return &ClamdScanner{network: network, addr: addr, timeout: time.Minute}
+}
+
+func (c *ClamdScanner) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("connecting to clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return ScanResult{}, fmt.Errorf("writing to clamd: %w", err)
+	}
+
+	// The stream is sent as length-prefixed chunks ending with a zero length.
+	buf := make([]byte, 32<<10)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(append(size, buf[:n]...)); err != nil {
+				return ScanResult{}, fmt.Errorf("writing to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return ScanResult{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return ScanResult{}, fmt.Errorf("writing to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return ScanResult{}, fmt.Errorf("reading clamd reply: %w", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply interprets "stream: OK" and "stream: <name> FOUND".
+func parseClamdReply(reply string) (ScanResult, error) {
+	_, status, _ := strings.Cut(reply, ": ")
+	switch {
+	case status == "OK":
+		return ScanResult{Clean: true}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return ScanResult{Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	default:
+		return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// HTTPScanner posts files to a scanning service that answers with a
+// JSON ScanResult.
+type HTTPScanner struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPScanner returns a scanner that posts to url.
+func NewHTTPScanner(url string) *HTTPScanner {
+	return &HTTPScanner{url: url, client: &http.Client{Timeout: time.Minute}}
+}
+
+func (h *HTTPScanner) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, r)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("calling scanner: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ScanResult{}, fmt.Errorf("scanner returned %s", resp.Status)
+	}
+
+	var result ScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ScanResult{}, fmt.Errorf("decoding scanner response: %w", err)
+	}
+	return result, nil
+}
+
+// newScanner builds the scanner selected by cfg.Kind.
+func newScanner(cfg config.Scanner) VirusScanner {
+	switch cfg.Kind {
+	case config.ScannerClamd:
+		return NewClamdScanner(cfg.ClamdAddr)
+	case config.ScannerHTTP:
+		return NewHTTPScanner(cfg.URL)
+	default:
+		return noopScanner{}
+	}
+}
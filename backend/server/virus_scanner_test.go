@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd accepts INSTREAM sessions on l and replies the way clamd does.
+// Streams larger than limit are refused like clamd's StreamMaxLength.
+func fakeClamd(t *testing.T, l net.Listener, limit int) {
+	t.Helper()
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(t, conn, limit)
+		}
+	}()
+}
+
+func serveClamd(t *testing.T, conn net.Conn, limit int) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		t.Errorf("command = %q, %v", command, err)
+		return
+	}
+
+	var stream bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			t.Errorf("reading chunk size: %v", err)
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if size > 32<<10 {
+			t.Errorf("chunk of %d bytes", size)
+		}
+		if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+			t.Errorf("reading chunk: %v", err)
+			return
+		}
+		if stream.Len() > limit {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+	}
+
+	reply := "stream: OK\x00"
+	if bytes.Contains(stream.Bytes(), []byte(eicar)) {
+		reply = "stream: Eicar-Test-Signature FOUND\x00"
+	}
+	conn.Write([]byte(reply))
+}
+
+func TestClamdScanner(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeClamd(t, l, 1<<20)
+	scanner := NewClamdScanner(l.Addr().String())
+	ctx := context.Background()
+
+	// Larger than one chunk, so the stream is split.
+	clean := strings.Repeat("evidence ", 10_000)
+	if result, err := scanner.Scan(ctx, strings.NewReader(clean)); err != nil || !result.Clean {
+		t.Errorf("clean file: %+v, %v", result, err)
+	}
+
+	infected := clean + eicar
+	result, err := scanner.Scan(ctx, strings.NewReader(infected))
+	if err != nil || result.Clean || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("infected file: %+v, %v", result, err)
+	}
+
+	if result, err := scanner.Scan(ctx, strings.NewReader("")); err != nil || !result.Clean {
+		t.Errorf("empty file: %+v, %v", result, err)
+	}
+
+	if _, err := scanner.Scan(ctx, strings.NewReader(strings.Repeat("x", 2<<20))); err == nil {
+		t.Error("oversized stream was not reported as an error")
+	}
+}
+
+func TestClamdScannerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	fakeClamd(t, l, 1<<20)
+
+	scanner := NewClamdScanner(socket)
+	if scanner.network != "unix" {
+		t.Fatalf("network = %q", scanner.network)
+	}
+	result, err := scanner.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("infected file: %+v, %v", result, err)
+	}
+}
+
+func TestClamdScannerUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	if _, err := NewClamdScanner(addr).Scan(context.Background(), strings.NewReader("x")); err == nil {
+		t.Error("scan succeeded without a daemon")
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	for reply, want := range map[string]ScanResult{
+		"stream: OK":                         {Clean: true},
+		"stream: Win.Test.EICAR_HDB-1 FOUND": {Signature: "Win.Test.EICAR_HDB-1"},
+	} {
+		if got, err := parseClamdReply(reply); err != nil || got != want {
+			t.Errorf("parseClamdReply(%q) = %+v, %v", reply, got, err)
+		}
+	}
+	for _, reply := range []string{"INSTREAM size limit exceeded. ERROR", "stream: lstat() failed ERROR", ""} {
+		if _, err := parseClamdReply(reply); err == nil {
+			t.Errorf("parseClamdReply(%q) succeeded", reply)
+		}
+	}
+}
+
+func TestHTTPScanner(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/octet-stream" {
+			t.Errorf("request %s with %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case string(body) == "broken":
+			http.Error(w, "scanner unavailable", http.StatusServiceUnavailable)
+		case bytes.Contains(body, []byte(eicar)):
+			json.NewEncoder(w).Encode(ScanResult{Signature: "Eicar-Test-Signature"})
+		default:
+			json.NewEncoder(w).Encode(ScanResult{Clean: true})
+		}
+	}))
+	defer srv.Close()
+
+	scanner := NewHTTPScanner(srv.URL)
+	ctx := context.Background()
+	if result, err := scanner.Scan(ctx, strings.NewReader("evidence")); err != nil || !result.Clean {
+		t.Errorf("clean file: %+v, %v", result, err)
+	}
+	if result, err := scanner.Scan(ctx, strings.NewReader(eicar)); err != nil || result.Clean || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("infected file: %+v, %v", result, err)
+	}
+	if _, err := scanner.Scan(ctx, strings.NewReader("broken")); err == nil {
+		t.Error("scanner failure was not reported")
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// webhookEvents are the event types projects can subscribe webhooks to.
+var webhookEvents = []string{
+	EventMilestoneCreated,
+	EventMilestoneOverdue,
+	EventTaskAssigned,
+	EventTaskStatusChanged,
+	EventTaskReviewed,
+	EventTaskOverdue,
+	EventEvidenceUploaded,
+}
+
+const (
+	// webhookMaxAttempts is how many times a delivery is tried before it
+	// is marked failed.
+	webhookMaxAttempts = 8
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = time.Hour
+	// webhookClaim is how long a new delivery is left to its first
+	// attempt before the retry job picks it up, in case the replica that
+	// queued it stopped.
+	webhookClaim = 5 * time.Minute
+)
+
+// webhookBackoff is the wait after the given number of failed attempts:
+// 30s, doubling up to an hour.
+func webhookBackoff(attempts int) time.Duration {
+	wait := webhookRetryBase
+	for i := 1; i < attempts && wait < webhookRetryMax; i++ {
+		wait *= 2
+	}
+	if wait > webhookRetryMax {
+		wait = webhookRetryMax
+	}
+	return wait
+}
+
+// newWebhookSecret returns a random signing secret.
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// signWebhook returns the X-Imara-Signature header for a payload sent at
+// t: the HMAC-SHA256 of "<unix time>.<body>" keyed with the secret.
+// Receivers should recompute it and reject stale timestamps.
+func signWebhook(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmacSHA256([]byte(secret), ts+"."+string(body))
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac)
+}
+
+// WebhookDispatcher delivers domain events to project webhooks. New
+// events are queued and sent by a background worker; failed deliveries
+// stay pending and are retried with exponential backoff by RetryDue.
+type WebhookDispatcher struct {
+	webhooks   WebhookRepository
+	deliveries WebhookDeliveryRepository
+	client     *http.Client
+	queue      chan DomainEvent
+
+	// allowHTTP accepts plain http webhook URLs, for local development.
+	allowHTTP bool
+}
+
+func NewWebhookDispatcher(repos Repositories, allowHTTP bool) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhooks:   repos.Webhooks,
+		deliveries: repos.WebhookDeliveries,
+		client:     newWebhookClient(),
+		queue:      make(chan DomainEvent, 256),
+		allowHTTP:  allowHTTP,
+	}
+}
+
+// Enqueue queues an event for delivery. It is an EventHandler. When the
+// queue is full the event is dropped rather than blocking the request.
+func (d *WebhookDispatcher) Enqueue(ctx context.Context, event DomainEvent) {
+	select {
+	case d.queue <- event:
+	default:
+		fmt.Printf("Error queueing webhooks for event %s: queue is full\n", event.ID)
+	}
+}
+
+// Run sends queued events until ctx is done.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-d.queue:
+			if err := d.dispatch(ctx, event); err != nil {
+				fmt.Printf("Error dispatching webhooks for event %s: %v\n", event.ID, err)
+			}
+		}
+	}
+}
+
+// dispatch records a delivery for every webhook subscribed to the event
+// and makes the first attempt.
+func (d *WebhookDispatcher) dispatch(ctx context.Context, event DomainEvent) error {
+	webhooks, err := d.webhooks.ListForEvent(ctx, event.ProjectID, event.Type)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		delivery, err := d.deliveries.Create(ctx, WebhookDelivery{
+			WebhookID:     webhook.ID,
+			ProjectID:     event.ProjectID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        DeliveryPending,
+			NextAttemptAt: time.Now().Add(webhookClaim).UTC().Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := d.attempt(ctx, webhook, delivery); err != nil {
+			fmt.Printf("Error recording webhook delivery %s: %v\n", delivery.ID, err)
+		}
+	}
+	return nil
+}
+
+// Replay sends a past delivery's payload again as a new delivery.
+func (d *WebhookDispatcher) Replay(ctx context.Context, webhook Webhook, original WebhookDelivery) (WebhookDelivery, error) {
+	delivery, err := d.deliveries.Create(ctx, WebhookDelivery{
+		WebhookID:     webhook.ID,
+		ProjectID:     original.ProjectID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: time.Now().Add(webhookClaim).UTC().Format(time.RFC3339Nano),
+		ReplayOf:      original.ID,
+	})
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	return d.attempt(ctx, webhook, delivery)
+}
+
+// RetryDue retries the pending deliveries whose next attempt is due. It
+// runs as a scheduled job.
+func (d *WebhookDispatcher) RetryDue(ctx context.Context) error {
+	due, err := d.deliveries.ListDue(ctx, time.Now(), 100)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range due {
+		webhook, err := d.webhooks.Get(ctx, delivery.WebhookID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !webhook.Active {
+			_, err := d.deliveries.Update(ctx, delivery.ID, map[string]interface{}{
+				"status":          DeliveryFailed,
+				"next_attempt_at": nil,
+				"last_error":      "webhook is disabled",
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := d.attempt(ctx, webhook, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt sends a delivery once and records the outcome, scheduling the
+// next attempt after a failure until webhookMaxAttempts is reached.
+func (d *WebhookDispatcher) attempt(ctx context.Context, webhook Webhook, delivery WebhookDelivery) (WebhookDelivery, error) {
+	status, sendErr := d.send(ctx, webhook, delivery)
+
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_attempt_at": now(),
+		"response_status": status,
+		"last_error":      "",
+		"next_attempt_at": nil,
+	}
+	switch {
+	case sendErr == nil:
+		updates["status"] = DeliverySucceeded
+	case attempts >= webhookMaxAttempts:
+		updates["status"] = DeliveryFailed
+		updates["last_error"] = sendErr.Error()
+	default:
+		updates["status"] = DeliveryPending
+		updates["last_error"] = sendErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(webhookBackoff(attempts)).UTC().Format(time.RFC3339Nano)
+	}
+	return d.deliveries.Update(ctx, delivery.ID, updates)
+}
+
+// send posts the delivery's payload, returning the response status and
+// an error unless the webhook answered with a 2xx status.
+func (d *WebhookDispatcher) send(ctx context.Context, webhook Webhook, delivery WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Imara-Webhooks/1.0")
+	req.Header.Set("X-Imara-Event", delivery.EventType)
+	req.Header.Set("X-Imara-Delivery", delivery.ID)
+	req.Header.Set("X-Imara-Signature", signWebhook(webhook.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// The body is not recorded: deliveries are readable by project
+		// leads, and it is the receiver's to show
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// secretBox encrypts webhook signing secrets before they are stored, so
+// reading the table is not enough to forge deliveries. Sealed values are
+// "v1." followed by the base64 of an AES-GCM nonce and ciphertext.
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+const sealedSecretPrefix = "v1."
+
+// newSecretBox returns a box keyed by the SHA-256 of key.
+func newSecretBox(key string) (*secretBox, error) {
+	if key == "" {
+		return nil, errors.New("a webhook secret key is required")
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretBox{aead: aead}, nil
+}
+
+// seal encrypts secret.
+func (b *secretBox) seal(secret string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return sealedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value made by seal.
+func (b *secretBox) open(sealed string) (string, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedSecretPrefix))
+	if err != nil || !strings.HasPrefix(sealed, sealedSecretPrefix) || len(raw) < b.aead.NonceSize() {
+		return "", errors.New("webhook secret is not sealed")
+	}
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	secret, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("webhook secret does not open with the configured key")
+	}
+	return string(secret), nil
+}
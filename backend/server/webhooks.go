@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// validateWebhookEvents checks that events is a non-empty list of known
+// event types, returning it without duplicates.
+func validateWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("events must list at least one event type")
+	}
+	unique := []string{}
+	for _, event := range events {
+		if !contains(webhookEvents, event) {
+			return nil, fmt.Errorf("Unknown event type %q", event)
+		}
+		if !contains(unique, event) {
+			unique = append(unique, event)
+		}
+	}
+	return unique, nil
+}
+
+// authorizeWebhook loads a webhook and checks that the caller leads its
+// project.
+func (s *server) authorizeWebhook(w http.ResponseWriter, r *http.Request, webhookID string) (Webhook, bool) {
+	webhook, err := s.webhooks.Get(r.Context(), webhookID)
+	if err != nil {
+		fmt.Printf("Error fetching webhook: %v\n", err)
+		writeRepoError(w, err, "Webhook not found")
+		return Webhook{}, false
+	}
+	_, ok := s.authorizeProject(w, r, webhook.ProjectID, leadOnly)
+	return webhook, ok
+}
+
+// List webhooks endpoint
+func (s *server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+	if _, ok := s.authorizeProject(w, r, projectId, leadOnly); !ok {
+		return
+	}
+
+	webhooks, err := s.webhooks.ListByProject(r.Context(), projectId)
+	if err != nil {
+		fmt.Printf("Error fetching webhooks: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	// Secrets are only shown when a webhook is created
+	for i := range webhooks {
+		webhooks[i] = webhooks[i].withoutSecret()
+	}
+	if webhooks == nil {
+		webhooks = []Webhook{}
+	}
+	writeJSON(w, webhooks)
+}
+
+// Create webhook endpoint
+func (s *server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectId := mux.Vars(r)["projectId"]
+	access, ok := s.authorizeProject(w, r, projectId, leadOnly)
+	if !ok {
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if err := validateWebhookURL(r.Context(), req.URL, s.webhookDispatcher.allowHTTP); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events, err := validateWebhookEvents(req.Events)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+
+	webhook, err := s.webhooks.Create(r.Context(), Webhook{
+		ProjectID: projectId,
+		URL:       req.URL,
+		Events:    events,
+		Secret:    secret,
+		Active:    active,
+		CreatedBy: access.UserID,
+	})
+	if err != nil {
+		fmt.Printf("Error creating webhook: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+	recordAudit(r.Context(), "webhook", webhook.ID, nil, webhook.withoutSecret())
+
+	writeJSONStatus(w, http.StatusCreated, webhook)
+}
+
+// Update webhook endpoint
+func (s *server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhookId := mux.Vars(r)["webhookId"]
+	existing, ok := s.authorizeWebhook(w, r, webhookId)
+	if !ok {
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != nil {
+		if err := validateWebhookURL(r.Context(), *req.URL, s.webhookDispatcher.allowHTTP); err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updates["url"] = *req.URL
+	}
+	if req.Events != nil {
+		events, err := validateWebhookEvents(req.Events)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updates["events"] = events
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	if len(updates) == 0 {
+		writeError(w, "No fields to update", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := s.webhooks.Update(r.Context(), webhookId, updates)
+	if err != nil {
+		fmt.Printf("Error updating webhook: %v\n", err)
+		writeRepoError(w, err, "Webhook not found")
+		return
+	}
+	recordAudit(r.Context(), "webhook", webhookId, existing.withoutSecret(), webhook.withoutSecret())
+
+	writeJSON(w, webhook.withoutSecret())
+}
+
+// Delete webhook endpoint
+func (s *server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhookId := mux.Vars(r)["webhookId"]
+	webhook, ok := s.authorizeWebhook(w, r, webhookId)
+	if !ok {
+		return
+	}
+
+	if err := s.webhooks.Delete(r.Context(), webhookId); err != nil {
+		fmt.Printf("Error deleting webhook: %v\n", err)
+		writeRepoError(w, err, "Webhook not found")
+		return
+	}
+	recordAudit(r.Context(), "webhook", webhookId, webhook.withoutSecret(), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List webhook deliveries endpoint
+func (s *server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhookId := mux.Vars(r)["webhookId"]
+	if _, ok := s.authorizeWebhook(w, r, webhookId); !ok {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxDeliveryLimit {
+			writeError(w, fmt.Sprintf("limit must be between 1 and %d", maxDeliveryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := s.webhookDeliveries.ListByWebhook(r.Context(), webhookId, limit)
+	if err != nil {
+		fmt.Printf("Error fetching webhook deliveries: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []WebhookDelivery{}
+	}
+	writeJSON(w, deliveries)
+}
+
+// Replay webhook delivery endpoint. The payload is sent again as a new
+// delivery, which is retried like any other if it fails.
+func (s *server) handleReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vars := mux.Vars(r)
+	webhookId := vars["webhookId"]
+	webhook, ok := s.authorizeWebhook(w, r, webhookId)
+	if !ok {
+		return
+	}
+
+	original, err := s.webhookDeliveries.Get(r.Context(), vars["deliveryId"])
+	if err != nil {
+		fmt.Printf("Error fetching webhook delivery: %v\n", err)
+		writeRepoError(w, err, "Delivery not found")
+		return
+	}
+	if original.WebhookID != webhookId {
+		writeError(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if !webhook.Active {
+		writeError(w, "Webhook is disabled", http.StatusConflict)
+		return
+	}
+
+	delivery, err := s.webhookDispatcher.Replay(r.Context(), webhook, original)
+	if err != nil {
+		fmt.Printf("Error replaying webhook delivery: %v\n", err)
+		writeServerError(w, err)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, delivery)
+}
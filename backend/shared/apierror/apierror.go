@@ -0,0 +1,130 @@
+// Package apierror is the error model shared by the Imara API servers.
+// Every error response is a JSON envelope:
+//
+//	{"code": "not_found", "message": "Task not found", "request_id": "..."}
+//
+// Codes are stable and meant for programs; messages are for people and
+// may change.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error codes.
+const (
+	CodeInvalidBody          = "invalid_body"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnprocessable        = "unprocessable"
+	CodeInternal             = "internal_error"
+	CodeUpstreamError        = "upstream_error"
+	CodeUpstreamUnavailable  = "upstream_unavailable"
+)
+
+// RequestIDHeader carries the ID of the request an error belongs to.
+const RequestIDHeader = "X-Request-ID"
+
+// Error is an API error response.
+type Error struct {
+	// Status is the HTTP status the error is sent with.
+	Status    int         `json:"-"`
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// CodeOf returns the code of the Error in err's chain, or "" if there is
+// none.
+func CodeOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// FieldError describes one invalid field of a request. Validation errors
+// list them in Details.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New returns an error with the given status, code and message.
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithDetails returns a copy of e carrying details.
+func (e *Error) WithDetails(details interface{}) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// CodeForStatus is the code used for an HTTP status when no more specific
+// one applies.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeValidationFailed
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusBadGateway:
+		return CodeUpstreamError
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUpstreamUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeValidationFailed
+}
+
+// Write sends e as the response. The request ID is taken from the
+// X-Request-ID response header when e does not carry one.
+func Write(w http.ResponseWriter, e *Error) {
+	resp := *e
+	if resp.RequestID == "" {
+		resp.RequestID = w.Header().Get(RequestIDHeader)
+	}
+	if resp.Status == 0 {
+		resp.Status = http.StatusInternalServerError
+	}
+	if resp.Code == "" {
+		resp.Code = CodeForStatus(resp.Status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
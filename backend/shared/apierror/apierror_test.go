@@ -0,0 +1,127 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPostgREST(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		code   string
+	}{
+		"23505":    {http.StatusConflict, CodeConflict},
+		"23503":    {http.StatusConflict, CodeConflict},
+		"23502":    {http.StatusBadRequest, CodeValidationFailed},
+		"23514":    {http.StatusBadRequest, CodeValidationFailed},
+		"22P02":    {http.StatusBadRequest, CodeValidationFailed},
+		"22007":    {http.StatusBadRequest, CodeValidationFailed},
+		"42501":    {http.StatusForbidden, CodeForbidden},
+		"PGRST116": {http.StatusNotFound, CodeNotFound},
+		"40001":    {http.StatusServiceUnavailable, CodeUpstreamUnavailable},
+		"40P01":    {http.StatusServiceUnavailable, CodeUpstreamUnavailable},
+		"PGRST001": {http.StatusServiceUnavailable, CodeUpstreamUnavailable},
+		"08006":    {http.StatusServiceUnavailable, CodeUpstreamUnavailable},
+		"53300":    {http.StatusServiceUnavailable, CodeUpstreamUnavailable},
+		"57014":    {http.StatusServiceUnavailable, CodeUpstreamUnavailable},
+		"PGRST301": {http.StatusBadGateway, CodeUpstreamError},
+		"42P01":    {http.StatusInternalServerError, CodeInternal},
+		"PGRST204": {http.StatusInternalServerError, CodeInternal},
+		"":         {http.StatusInternalServerError, CodeInternal},
+	}
+	for code, want := range cases {
+		got := PostgREST(code)
+		if got.Status != want.status || got.Code != want.code || got.Message == "" {
+			t.Errorf("PostgREST(%q) = %d %s %q, want %d %s", code, got.Status, got.Code, got.Message, want.status, want.code)
+		}
+	}
+}
+
+func TestWriteKeepsDatabaseMessagesOut(t *testing.T) {
+	// What PostgREST reported; only the code reaches the envelope
+	dbMessage := `duplicate key value violates unique constraint "milestone_tasks_pkey"`
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "req-1")
+	Write(w, PostgREST("23505"))
+
+	if w.Code != http.StatusConflict || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, leak := range []string{dbMessage, "milestone_tasks", "constraint", "23505"} {
+		if strings.Contains(body, leak) {
+			t.Errorf("envelope %s contains %q", body, leak)
+		}
+	}
+	var envelope map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope["code"] != CodeConflict || envelope["request_id"] != "req-1" || envelope["message"] == "" {
+		t.Errorf("envelope = %v", envelope)
+	}
+}
+
+func TestWriteFillsDefaults(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, &Error{Message: "boom"})
+	var got Error
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != http.StatusInternalServerError || got.Code != CodeInternal {
+		t.Errorf("status %d, code %q", w.Code, got.Code)
+	}
+
+	w = httptest.NewRecorder()
+	details := []FieldError{{Field: "title", Message: "is required"}}
+	Write(w, New(http.StatusBadRequest, "", "Invalid").WithDetails(details))
+	if !strings.Contains(w.Body.String(), `"details":[{"field":"title","message":"is required"}]`) ||
+		!strings.Contains(w.Body.String(), `"code":"validation_failed"`) {
+		t.Errorf("body = %s", w.Body)
+	}
+}
+
+func TestUpstream(t *testing.T) {
+	urlErr := &url.Error{Op: "Get", URL: "https://db.internal/rest/v1/tasks", Err: errors.New("connection refused")}
+	cases := map[string]struct {
+		err    error
+		status int
+	}{
+		"url error":         {urlErr, http.StatusServiceUnavailable},
+		"wrapped url error": {fmt.Errorf("listing tasks: %w", urlErr), http.StatusServiceUnavailable},
+		"net error":         {&net.OpError{Op: "dial", Err: errors.New("timeout")}, http.StatusServiceUnavailable},
+		"deadline":          {context.DeadlineExceeded, http.StatusServiceUnavailable},
+		"other":             {errors.New("decoding response"), http.StatusInternalServerError},
+	}
+	for name, c := range cases {
+		got := Upstream(c.err)
+		if got.Status != c.status || strings.Contains(got.Message, "db.internal") {
+			t.Errorf("%s: %d %q", name, got.Status, got.Message)
+		}
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	err := fmt.Errorf("creating task: %w", New(http.StatusConflict, CodeConflict, "Exists"))
+	if CodeOf(err) != CodeConflict || CodeOf(errors.New("plain")) != "" {
+		t.Errorf("CodeOf = %q", CodeOf(err))
+	}
+	for status, code := range map[int]string{
+		http.StatusBadRequest:          CodeValidationFailed,
+		http.StatusNotFound:            CodeNotFound,
+		http.StatusGatewayTimeout:      CodeUpstreamUnavailable,
+		http.StatusInternalServerError: CodeInternal,
+		http.StatusTeapot:              CodeValidationFailed,
+	} {
+		if got := CodeForStatus(status); got != code {
+			t.Errorf("CodeForStatus(%d) = %q, want %q", status, got, code)
+		}
+	}
+}
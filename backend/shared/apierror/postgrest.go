@@ -0,0 +1,52 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PostgREST maps an error code reported by PostgREST, either a PostgreSQL
+// SQLSTATE or one of PostgREST's own PGRST codes, to an API error. The
+// database's message is never passed on: it can name tables, columns and
+// constraints.
+func PostgREST(code string) *Error {
+	switch {
+	case code == "23505":
+		return New(http.StatusConflict, CodeConflict, "The record already exists")
+	case code == "23503":
+		return New(http.StatusConflict, CodeConflict, "The record refers to, or is referred to by, another record")
+	case code == "23502":
+		return New(http.StatusBadRequest, CodeValidationFailed, "A required field is missing")
+	case code == "23514", strings.HasPrefix(code, "22"):
+		return New(http.StatusBadRequest, CodeValidationFailed, "A field has an invalid value")
+	case code == "42501":
+		return New(http.StatusForbidden, CodeForbidden, "Permission denied")
+	case code == "PGRST116":
+		return New(http.StatusNotFound, CodeNotFound, "Not found")
+	case code == "40001", code == "40P01":
+		return New(http.StatusServiceUnavailable, CodeUpstreamUnavailable, "The database is busy, try again")
+	case strings.HasPrefix(code, "PGRST0"), strings.HasPrefix(code, "08"),
+		strings.HasPrefix(code, "53"), strings.HasPrefix(code, "57"):
+		// Connection and resource errors
+		return New(http.StatusServiceUnavailable, CodeUpstreamUnavailable, "The database is unavailable, try again later")
+	case strings.HasPrefix(code, "PGRST3"):
+		// The server's own credentials were refused
+		return New(http.StatusBadGateway, CodeUpstreamError, "The database rejected the request")
+	}
+	return New(http.StatusInternalServerError, CodeInternal, "Internal server error")
+}
+
+// Upstream maps a failure to reach an upstream service: network errors
+// and timeouts become upstream_unavailable, anything else internal_error.
+func Upstream(err error) *Error {
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return New(http.StatusServiceUnavailable, CodeUpstreamUnavailable, "An upstream service is unavailable, try again later")
+	}
+	return New(http.StatusInternalServerError, CodeInternal, "Internal server error")
+}
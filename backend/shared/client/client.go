@@ -0,0 +1,301 @@
+// Package client is a Go client for the Imara API.
+//
+//	c, err := client.New("https://api.imarahub.xyz", client.WithToken(accessToken))
+//	milestone, err := c.CreateMilestone(ctx, client.CreateMilestoneRequest{
+//		ProjectID: projectID,
+//		Title:     "Testnet launch",
+//		DueDate:   "2027-03-01",
+//	})
+//
+// Errors returned by the API are *apierror.Error values carrying the
+// response's code, message and request ID. Idempotent requests (GET, PUT,
+// DELETE) are retried when the server or the network is temporarily
+// unavailable.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"imara-shared/apierror"
+)
+
+const (
+	defaultMaxRetries = 3
+	retryBase         = 250 * time.Millisecond
+	retryMax          = 4 * time.Second
+)
+
+// TokenSource returns the access token to send with a request. It is
+// called for every attempt, so it can refresh expired tokens.
+type TokenSource func(ctx context.Context) (string, error)
+
+// Client calls the Imara API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	token      TokenSource
+	userAgent  string
+	maxRetries int
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithToken sends a fixed Supabase access token.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = func(context.Context) (string, error) { return token, nil }
+	}
+}
+
+// WithTokenSource sends the token returned by src.
+func WithTokenSource(src TokenSource) Option {
+	return func(c *Client) { c.token = src }
+}
+
+// WithHTTPClient replaces the default HTTP client, which times out after
+// 30 seconds.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithMaxRetries sets how many times an idempotent request is retried
+// after a network error or a 429, 502, 503 or 504 response. Zero
+// disables retries.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) { c.maxRetries = n }
+}
+
+// WithUserAgent sets the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New returns a client for the API at baseURL, such as
+// "http://localhost:8000".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		userAgent:  "imara-go-client/1.0",
+		maxRetries: defaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// request describes one API call. body is sent as is with contentType;
+// use jsonRequest for JSON bodies.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+}
+
+func jsonRequest(method, path string, v interface{}) (request, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return request{}, err
+	}
+	return request{method: method, path: path, body: raw, contentType: "application/json"}, nil
+}
+
+// do sends req, retrying when it is safe to, and decodes a successful
+// JSON response into out unless out is nil.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send returns the response to req once it succeeds. The caller closes
+// its body.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	retries := 0
+	if idempotent(req.method) {
+		retries = c.maxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if err == nil {
+			err = readError(resp)
+		}
+		if attempt >= retries || !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req request) (*http.Response, error) {
+	// req.path is already escaped
+	target := c.baseURL.String() + req.path
+	if query := req.query.Encode(); query != "" {
+		target += "?" + query
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting access token: %w", err)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(httpReq)
+}
+
+// readError decodes the error envelope of a failed response, falling back
+// to a generic error for the status when there is none.
+func readError(resp *http.Response) *apierror.Error {
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e apierror.Error
+	if err := json.Unmarshal(raw, &e); err != nil || e.Code == "" {
+		e = apierror.Error{Code: apierror.CodeForStatus(resp.StatusCode), Message: http.StatusText(resp.StatusCode)}
+	}
+	// Validation errors list the invalid fields
+	var fields struct {
+		Details []apierror.FieldError `json:"details"`
+	}
+	if e.Code == apierror.CodeValidationFailed && json.Unmarshal(raw, &fields) == nil && fields.Details != nil {
+		e.Details = fields.Details
+	}
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get(apierror.RequestIDHeader)
+	}
+	e.Status = resp.StatusCode
+	return &e
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether err may go away if the request is repeated.
+func retryable(err error) bool {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff is the wait before retry attempt+1: 250ms, doubling up to 4s.
+func backoff(attempt int) time.Duration {
+	wait := retryBase << attempt
+	if wait > retryMax || wait <= 0 {
+		wait = retryMax
+	}
+	return wait
+}
+
+// pathf builds a path from a format, escaping each argument as a path
+// segment.
+func pathf(format string, args ...string) string {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		escaped[i] = url.PathEscape(arg)
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	if len(o.Statuses) > 0 {
+		v.Set("status", strings.Join(o.Statuses, ","))
+	}
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	set("due_before", o.DueBefore)
+	set("due_after", o.DueAfter)
+	set("q", o.Search)
+	set("sort", o.Sort)
+	set("cursor", o.Cursor)
+	set("assignee", o.Assignee)
+	if o.Desc {
+		v.Set("order", "desc")
+	}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Reviewed != nil {
+		v.Set("reviewed", strconv.FormatBool(*o.Reviewed))
+	}
+	return v
+}
+
+// list fetches a listing, which the server returns as a plain array
+// unless a limit or cursor asks for a page.
+func list[T any](ctx context.Context, c *Client, path string, opts ListOptions) (Page[T], error) {
+	var raw json.RawMessage
+	if err := c.do(ctx, request{method: http.MethodGet, path: path, query: opts.values()}, &raw); err != nil {
+		return Page[T]{}, err
+	}
+	var page Page[T]
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		err := json.Unmarshal(raw, &page.Items)
+		return page, err
+	}
+	err := json.Unmarshal(raw, &page)
+	return page, err
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"imara-shared/apierror"
+)
+
+// newTestClient returns a client for an API served by handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL+"/", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientSendsToken(t *testing.T) {
+	var got http.Header
+	var path string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		got, path = r.Header.Clone(), r.URL.EscapedPath()
+		w.Write([]byte(`{"id":"m1","title":"Alpha"}`))
+	}
+	ctx := context.Background()
+
+	c := newTestClient(t, handler, WithToken("tok-1"), WithUserAgent("tests/1.0"))
+	milestone, err := c.CreateMilestone(ctx, CreateMilestoneRequest{ProjectID: "p1", Title: "Alpha", DueDate: "2030-01-31"})
+	if err != nil || milestone.ID != "m1" {
+		t.Fatalf("CreateMilestone = %+v, %v", milestone, err)
+	}
+	if got.Get("Authorization") != "Bearer tok-1" || got.Get("User-Agent") != "tests/1.0" ||
+		got.Get("Content-Type") != "application/json" || got.Get("Accept") != "application/json" {
+		t.Errorf("headers = %v", got)
+	}
+
+	// A token source is asked on every request
+	var calls int32
+	src := func(context.Context) (string, error) {
+		return fmt.Sprintf("tok-%d", atomic.AddInt32(&calls, 1)), nil
+	}
+	c = newTestClient(t, handler, WithTokenSource(src))
+	for _, want := range []string{"Bearer tok-1", "Bearer tok-2"} {
+		if err := c.DeleteMilestone(ctx, "m/1", DeleteMilestoneOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if got.Get("Authorization") != want {
+			t.Errorf("Authorization = %q, want %q", got.Get("Authorization"), want)
+		}
+	}
+	if path != "/api/milestones/m%2F1" {
+		t.Errorf("path = %s", path)
+	}
+
+	// The request is not sent without a token
+	failing := errors.New("refresh failed")
+	var sent bool
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) { sent = true },
+		WithTokenSource(func(context.Context) (string, error) { return "", failing }))
+	if err := c.DeleteTask(ctx, "t1"); !errors.Is(err, failing) || sent {
+		t.Errorf("token error: %v, request sent %v", err, sent)
+	}
+
+	// Without a token there is no Authorization header
+	c = newTestClient(t, handler)
+	if _, err := c.ListMilestones(ctx, "p1", ListOptions{}); err != nil || got.Get("Authorization") != "" {
+		t.Errorf("anonymous: %v, Authorization %q", err, got.Get("Authorization"))
+	}
+}
+
+func TestClientDecodesErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		header string
+		body   string
+		want   apierror.Error
+	}{
+		{
+			name:   "envelope",
+			status: http.StatusForbidden,
+			body:   `{"code":"forbidden","message":"Only the project lead can do this","request_id":"req-1"}`,
+			want:   apierror.Error{Status: http.StatusForbidden, Code: apierror.CodeForbidden, Message: "Only the project lead can do this", RequestID: "req-1"},
+		},
+		{
+			name:   "validation details",
+			status: http.StatusBadRequest,
+			header: "req-2",
+			body:   `{"code":"validation_failed","message":"Invalid request","details":[{"field":"title","message":"is required"}]}`,
+			want: apierror.Error{Status: http.StatusBadRequest, Code: apierror.CodeValidationFailed, Message: "Invalid request", RequestID: "req-2",
+				Details: []apierror.FieldError{{Field: "title", Message: "is required"}}},
+		},
+		{
+			name:   "no envelope",
+			status: http.StatusNotFound,
+			header: "req-3",
+			body:   `404 page not found`,
+			want:   apierror.Error{Status: http.StatusNotFound, Code: apierror.CodeNotFound, Message: "Not Found", RequestID: "req-3"},
+		},
+	}
+	for _, c := range cases {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if c.header != "" {
+				w.Header().Set(apierror.RequestIDHeader, c.header)
+			}
+			w.WriteHeader(c.status)
+			w.Write([]byte(c.body))
+		})
+		_, err := client.CreateTask(context.Background(), "m1", CreateTaskRequest{Title: "Spec"})
+		var got *apierror.Error
+		if !errors.As(err, &got) {
+			t.Errorf("%s: error %T %v, want *apierror.Error", c.name, err, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, c.want) {
+			t.Errorf("%s: error = %+v\nwant %+v", c.name, *got, c.want)
+		}
+		if apierror.CodeOf(err) != c.want.Code {
+			t.Errorf("%s: CodeOf = %q", c.name, apierror.CodeOf(err))
+		}
+	}
+}
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	var attempts int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[]`))
+	})
+	if _, err := c.ListTasks(context.Background(), "m1", ListOptions{}); err != nil || attempts != 2 {
+		t.Errorf("GET: %v after %d attempts", err, attempts)
+	}
+
+	// Creating twice is not safe
+	atomic.StoreInt32(&attempts, 0)
+	_, err := c.CreateTask(context.Background(), "m1", CreateTaskRequest{Title: "Spec"})
+	if apierror.CodeOf(err) != apierror.CodeUpstreamUnavailable || attempts != 1 {
+		t.Errorf("POST: %v after %d attempts", err, attempts)
+	}
+}
+
+func TestClientStopsWhenCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var attempts int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	// Cancelled while waiting for the response
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.ListTasks(ctx, "m1", ListOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("%d attempts after cancelling", n)
+	}
+
+	// Cancelled while waiting to retry
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithMaxRetries(5))
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	if _, err := c.ListTasks(ctx, "m1", ListOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > retryBase*2 {
+		t.Errorf("returned after %v, during the backoff", elapsed)
+	}
+}
+
+func TestNewRejectsBadBaseURLs(t *testing.T) {
+	for _, raw := range []string{"", "localhost:8000", "ftp://api.example", "http://", "://bad"} {
+		if _, err := New(raw); err == nil {
+			t.Errorf("New(%q) succeeded", raw)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListEvidence returns a task's evidence. A round above zero only returns
+// the evidence submitted in that round.
+func (c *Client) ListEvidence(ctx context.Context, taskID string, round int) ([]Evidence, error) {
+	query := url.Values{}
+	if round > 0 {
+		query.Set("submission_round", strconv.Itoa(round))
+	}
+	var out []Evidence
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/tasks/%s/evidence", taskID), query: query}, &out)
+	return out, err
+}
+
+// UploadEvidence uploads a file as evidence for a task. Only the task's
+// assignee can add evidence. The file is read into memory before it is
+// sent.
+func (c *Client) UploadEvidence(ctx context.Context, taskID, fileName string, file io.Reader) (Evidence, error) {
+	var out Evidence
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return out, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return out, err
+	}
+	if err := form.Close(); err != nil {
+		return out, err
+	}
+
+	err = c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        pathf("/api/tasks/%s/evidence", taskID),
+		body:        body.Bytes(),
+		contentType: form.FormDataContentType(),
+	}, &out)
+	return out, err
+}
+
+// AddEvidenceLink adds a link as evidence for a task.
+func (c *Client) AddEvidenceLink(ctx context.Context, taskID, link string) (Evidence, error) {
+	return c.addEvidence(ctx, taskID, map[string]string{"kind": EvidenceLink, "url": link})
+}
+
+// AddEvidenceNote adds a text note as evidence for a task.
+func (c *Client) AddEvidenceNote(ctx context.Context, taskID, text string) (Evidence, error) {
+	return c.addEvidence(ctx, taskID, map[string]string{"kind": EvidenceText, "body": text})
+}
+
+func (c *Client) addEvidence(ctx context.Context, taskID string, note map[string]string) (Evidence, error) {
+	var out Evidence
+	req, err := jsonRequest(http.MethodPost, pathf("/api/tasks/%s/evidence", taskID), note)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// DeleteEvidence deletes evidence that has not been submitted for review.
+func (c *Client) DeleteEvidence(ctx context.Context, evidenceID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: pathf("/api/evidence/%s", evidenceID)}, nil)
+}
+
+// DownloadEvidence returns an evidence item's content. The caller closes
+// it.
+func (c *Client) DownloadEvidence(ctx context.Context, evidenceID string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: pathf("/api/evidence/%s/download", evidenceID)})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// EvidenceDownloadURL returns a signed link to an evidence item that works
+// without a token. An expiresIn of zero uses the server default.
+func (c *Client) EvidenceDownloadURL(ctx context.Context, evidenceID string, expiresIn int) (DownloadURL, error) {
+	query := url.Values{}
+	if expiresIn > 0 {
+		query.Set("expires_in", strconv.Itoa(expiresIn))
+	}
+	var out DownloadURL
+	err := c.do(ctx, request{method: http.MethodPost, path: pathf("/api/evidence/%s/download-url", evidenceID), query: query}, &out)
+	return out, err
+}
+
+// GetUploadPolicy returns a project's effective upload policy and usage.
+func (c *Client) GetUploadPolicy(ctx context.Context, projectID string) (UploadPolicy, error) {
+	var out UploadPolicy
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/projects/%s/upload-policy", projectID)}, &out)
+	return out, err
+}
+
+// UpdateUploadPolicy sets a project's upload policy. It requires the lead
+// role.
+func (c *Client) UpdateUploadPolicy(ctx context.Context, projectID string, policy UploadPolicy) (UploadPolicy, error) {
+	var out UploadPolicy
+	policy.UsedBytes = 0
+	req, err := jsonRequest(http.MethodPut, pathf("/api/projects/%s/upload-policy", projectID), policy)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListMilestones returns a project's milestones with their tasks.
+func (c *Client) ListMilestones(ctx context.Context, projectID string, opts ListOptions) (Page[Milestone], error) {
+	return list[Milestone](ctx, c, pathf("/api/projects/%s/milestones", projectID), opts)
+}
+
+// CreateMilestone creates a milestone. It requires the lead role.
+func (c *Client) CreateMilestone(ctx context.Context, milestone CreateMilestoneRequest) (Milestone, error) {
+	var out Milestone
+	req, err := jsonRequest(http.MethodPost, "/api/milestones", milestone)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// UpdateMilestone changes the fields set in update.
+func (c *Client) UpdateMilestone(ctx context.Context, milestoneID string, update UpdateMilestoneRequest) (Milestone, error) {
+	var out Milestone
+	req, err := jsonRequest(http.MethodPatch, pathf("/api/milestones/%s", milestoneID), update)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// DeleteMilestone deletes a milestone and, depending on opts, its tasks.
+func (c *Client) DeleteMilestone(ctx context.Context, milestoneID string, opts DeleteMilestoneOptions) error {
+	query := url.Values{}
+	if opts.OnTasks != "" {
+		query.Set("on_tasks", opts.OnTasks)
+	}
+	if opts.TargetMilestoneID != "" {
+		query.Set("target_milestone_id", opts.TargetMilestoneID)
+	}
+	return c.do(ctx, request{method: http.MethodDelete, path: pathf("/api/milestones/%s", milestoneID), query: query}, nil)
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// ReviewTask records a verdict on a submitted task. It requires the lead
+// role.
+func (c *Client) ReviewTask(ctx context.Context, taskID string, review ReviewTaskRequest) (ReviewResponse, error) {
+	var out ReviewResponse
+	req, err := jsonRequest(http.MethodPost, pathf("/api/tasks/%s/review", taskID), review)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// ListReviews returns a task's reviews, oldest first.
+func (c *Client) ListReviews(ctx context.Context, taskID string) ([]TaskReview, error) {
+	var out []TaskReview
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/tasks/%s/reviews", taskID)}, &out)
+	return out, err
+}
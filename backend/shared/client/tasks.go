@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListTasks returns a milestone's tasks.
+func (c *Client) ListTasks(ctx context.Context, milestoneID string, opts ListOptions) (Page[Task], error) {
+	return list[Task](ctx, c, pathf("/api/milestones/%s/tasks", milestoneID), opts)
+}
+
+// CreateTask adds a task to a milestone.
+func (c *Client) CreateTask(ctx context.Context, milestoneID string, task CreateTaskRequest) (Task, error) {
+	var out Task
+	req, err := jsonRequest(http.MethodPost, pathf("/api/milestones/%s/tasks", milestoneID), task)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// UpdateTask changes the fields set in update. Use ReassignTask and
+// MoveTask to change the assignee or milestone.
+func (c *Client) UpdateTask(ctx context.Context, taskID string, update UpdateTaskRequest) (Task, error) {
+	var out Task
+	req, err := jsonRequest(http.MethodPut, pathf("/api/tasks/%s", taskID), update)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// DeleteTask deletes a task.
+func (c *Client) DeleteTask(ctx context.Context, taskID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: pathf("/api/tasks/%s", taskID)}, nil)
+}
+
+// ReassignTask assigns a task to another project member.
+func (c *Client) ReassignTask(ctx context.Context, taskID, assigneeID string) (Task, error) {
+	var out Task
+	req, err := jsonRequest(http.MethodPost, pathf("/api/tasks/%s/reassign", taskID), map[string]string{"assignee_id": assigneeID})
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// MoveTask moves a task to another milestone of the same project.
+func (c *Client) MoveTask(ctx context.Context, taskID, milestoneID string) (Task, error) {
+	var out Task
+	req, err := jsonRequest(http.MethodPost, pathf("/api/tasks/%s/move", taskID), map[string]string{"milestone_id": milestoneID})
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// TransitionTask moves a task to another status in its lifecycle, with
+// an optional note for the history.
+func (c *Client) TransitionTask(ctx context.Context, taskID, status, note string) (Task, error) {
+	var out Task
+	req, err := jsonRequest(http.MethodPost, pathf("/api/tasks/%s/status", taskID), map[string]string{"status": status, "note": note})
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// TaskHistory returns a task's status changes, oldest first.
+func (c *Client) TaskHistory(ctx context.Context, taskID string) ([]TaskTransition, error) {
+	var out []TaskTransition
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/tasks/%s/history", taskID)}, &out)
+	return out, err
+}
+
+// MyTasks returns the tasks assigned to the caller across projects.
+func (c *Client) MyTasks(ctx context.Context, opts AssignedTasksOptions) (AssignedTasks, error) {
+	var out AssignedTasks
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/me/tasks", query: opts.values()}, &out)
+	return out, err
+}
+
+// UserTasks returns the tasks assigned to a user in the projects the
+// caller leads.
+func (c *Client) UserTasks(ctx context.Context, userID string, opts AssignedTasksOptions) (AssignedTasks, error) {
+	var out AssignedTasks
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/users/%s/tasks", userID), query: opts.values()}, &out)
+	return out, err
+}
+
+func (o AssignedTasksOptions) values() url.Values {
+	v := url.Values{}
+	if o.DueSoonDays > 0 {
+		v.Set("due_soon_days", strconv.Itoa(o.DueSoonDays))
+	}
+	if o.IncludeDone {
+		v.Set("include_done", "true")
+	}
+	return v
+}
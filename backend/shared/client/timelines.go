@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// GetTimeline returns a project's timeline. Phases is empty when the
+// project has none.
+func (c *Client) GetTimeline(ctx context.Context, projectID string) (TimelineResponse, error) {
+	var out TimelineResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/projects/%s/timeline", projectID)}, &out)
+	return out, err
+}
+
+// ListPhases returns a project's timeline phases in order.
+func (c *Client) ListPhases(ctx context.Context, projectID string) ([]Timeline, error) {
+	var out []Timeline
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/projects/%s/phases", projectID)}, &out)
+	return out, err
+}
+
+// CreatePhase adds a phase at the end of a project's timeline.
+func (c *Client) CreatePhase(ctx context.Context, projectID string, phase CreatePhaseRequest) (Timeline, error) {
+	var out Timeline
+	req, err := jsonRequest(http.MethodPost, pathf("/api/projects/%s/phases", projectID), phase)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// UpdateTimeline replaces a timeline phase's fields.
+func (c *Client) UpdateTimeline(ctx context.Context, timelineID string, update UpdateTimelineRequest) (Timeline, error) {
+	var out Timeline
+	req, err := jsonRequest(http.MethodPut, pathf("/api/timeline/%s", timelineID), update)
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// ReorderPhases puts a project's phases in the given order. It must list
+// every phase exactly once.
+func (c *Client) ReorderPhases(ctx context.Context, projectID string, phaseIDs []string) ([]Timeline, error) {
+	var out []Timeline
+	req, err := jsonRequest(http.MethodPut, pathf("/api/projects/%s/phases/order", projectID), map[string][]string{"phase_ids": phaseIDs})
+	if err != nil {
+		return out, err
+	}
+	err = c.do(ctx, req, &out)
+	return out, err
+}
+
+// DeletePhase deletes a timeline phase.
+func (c *Client) DeletePhase(ctx context.Context, phaseID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: pathf("/api/phases/%s", phaseID)}, nil)
+}
+
+// ValidateSchedule checks a project's phases, milestones and tasks for
+// dates that do not fit together.
+func (c *Client) ValidateSchedule(ctx context.Context, projectID string) (ScheduleReport, error) {
+	var out ScheduleReport
+	err := c.do(ctx, request{method: http.MethodGet, path: pathf("/api/projects/%s/schedule/validate", projectID)}, &out)
+	return out, err
+}
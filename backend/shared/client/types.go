@@ -0,0 +1,327 @@
+package client
+
+// Dates are strings in the API's format: "YYYY-MM-DD" or an RFC 3339
+// timestamp with a time zone. An empty date is unset. Timestamps such as
+// CreatedAt are RFC 3339.
+
+// Timeline is one phase of a project's timeline, ordered by Position.
+type Timeline struct {
+	ID          string `json:"id"`
+	ProjectID   string `json:"project_id"`
+	Name        string `json:"name"`
+	Position    int    `json:"position"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// TimelineResponse is a project's timeline: the first phase's fields at
+// the top level plus every phase.
+type TimelineResponse struct {
+	Timeline
+	Phases []Timeline `json:"phases"`
+}
+
+// CreatePhaseRequest adds a phase at the end of a project's timeline.
+type CreatePhaseRequest struct {
+	Name        string `json:"name"`
+	StartDate   string `json:"start_date,omitempty"`
+	EndDate     string `json:"end_date,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// UpdateTimelineRequest replaces a phase's dates and description. An
+// empty Name keeps the current one.
+type UpdateTimelineRequest struct {
+	Name        string `json:"name,omitempty"`
+	StartDate   string `json:"start_date,omitempty"`
+	EndDate     string `json:"end_date,omitempty"`
+	Description string `json:"description"`
+}
+
+// ScheduleIssue is one inconsistency in a project's schedule.
+type ScheduleIssue struct {
+	Kind        string `json:"kind"`
+	Message     string `json:"message"`
+	PhaseID     string `json:"phase_id,omitempty"`
+	MilestoneID string `json:"milestone_id,omitempty"`
+	TaskID      string `json:"task_id,omitempty"`
+}
+
+// ScheduleReport lists every schedule issue found in a project.
+type ScheduleReport struct {
+	Valid  bool            `json:"valid"`
+	Issues []ScheduleIssue `json:"issues"`
+}
+
+// Milestone statuses.
+const (
+	MilestonePending    = "pending"
+	MilestoneInProgress = "in_progress"
+	MilestoneCompleted  = "completed"
+)
+
+type Milestone struct {
+	ID           string             `json:"id"`
+	ProjectID    string             `json:"project_id"`
+	PhaseID      string             `json:"phase_id,omitempty"`
+	Title        string             `json:"title"`
+	Description  string             `json:"description"`
+	DueDate      string             `json:"due_date"`
+	Status       string             `json:"status"`
+	CreatedBy    string             `json:"created_by"`
+	CreatedAt    string             `json:"created_at"`
+	UpdatedAt    string             `json:"updated_at"`
+	Tasks        []Task             `json:"milestone_tasks"`
+	OverdueSince string             `json:"overdue_since,omitempty"`
+	Progress     *MilestoneProgress `json:"progress,omitempty"`
+}
+
+// MilestoneProgress is how much of a milestone's work is done, weighted
+// by task effort.
+type MilestoneProgress struct {
+	Percent     float64 `json:"percent"`
+	DoneTasks   int     `json:"done_tasks"`
+	TotalTasks  int     `json:"total_tasks"`
+	DoneEffort  int     `json:"done_effort"`
+	TotalEffort int     `json:"total_effort"`
+}
+
+type CreateMilestoneRequest struct {
+	ProjectID   string `json:"project_id"`
+	PhaseID     string `json:"phase_id,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	DueDate     string `json:"due_date"`
+}
+
+// UpdateMilestoneRequest changes the fields that are not nil. Setting
+// PhaseID to "" unassigns the milestone from its phase.
+type UpdateMilestoneRequest struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	DueDate     *string `json:"due_date,omitempty"`
+	Status      *string `json:"status,omitempty"`
+	PhaseID     *string `json:"phase_id,omitempty"`
+}
+
+// Milestone deletion modes.
+const (
+	OnTasksBlock   = "block"
+	OnTasksCascade = "cascade"
+	OnTasksMove    = "move"
+)
+
+// DeleteMilestoneOptions decide what happens to a milestone's tasks. By
+// default a milestone with tasks is not deleted.
+type DeleteMilestoneOptions struct {
+	OnTasks string
+	// TargetMilestoneID receives the tasks when OnTasks is OnTasksMove.
+	TargetMilestoneID string
+}
+
+// Task statuses.
+const (
+	TaskPending          = "pending"
+	TaskInProgress       = "in_progress"
+	TaskSubmitted        = "submitted"
+	TaskChangesRequested = "changes_requested"
+	TaskApproved         = "approved"
+	TaskClosed           = "closed"
+)
+
+type Task struct {
+	ID                 string `json:"id"`
+	MilestoneID        string `json:"milestone_id"`
+	Title              string `json:"title"`
+	Description        string `json:"description"`
+	AssigneeID         string `json:"assignee_id"`
+	DueDate            string `json:"due_date"`
+	Status             string `json:"status"`
+	Reviewed           bool   `json:"reviewed"`
+	CreatedBy          string `json:"created_by"`
+	CreatedAt          string `json:"created_at"`
+	UpdatedAt          string `json:"updated_at"`
+	PreviousAssigneeID string `json:"previous_assignee_id,omitempty"`
+	ReassignedAt       string `json:"reassigned_at,omitempty"`
+	SubmissionRound    int    `json:"submission_round"`
+	Effort             int    `json:"effort"`
+	OverdueSince       string `json:"overdue_since,omitempty"`
+}
+
+type CreateTaskRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	AssigneeID  string `json:"assignee_id,omitempty"`
+	DueDate     string `json:"due_date,omitempty"`
+	// Effort defaults to 1.
+	Effort int `json:"effort,omitempty"`
+}
+
+// UpdateTaskRequest changes the fields that are not nil. A status change
+// goes through the task lifecycle like TransitionTask.
+type UpdateTaskRequest struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Status      *string `json:"status,omitempty"`
+	Effort      *int    `json:"effort,omitempty"`
+	DueDate     *string `json:"due_date,omitempty"`
+}
+
+// TaskTransition is one entry in a task's status history.
+type TaskTransition struct {
+	ID         string `json:"id"`
+	TaskID     string `json:"task_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	ChangedBy  string `json:"changed_by"`
+	Note       string `json:"note"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// AssignedTask is a task listed outside its milestone.
+type AssignedTask struct {
+	Task
+	ProjectID        string `json:"project_id"`
+	MilestoneTitle   string `json:"milestone_title"`
+	MilestoneDueDate string `json:"milestone_due_date"`
+	Bucket           string `json:"bucket,omitempty"`
+}
+
+// AssignedMilestoneTasks groups a user's tasks in one milestone.
+type AssignedMilestoneTasks struct {
+	MilestoneID string         `json:"milestone_id"`
+	Title       string         `json:"title"`
+	DueDate     string         `json:"due_date"`
+	Tasks       []AssignedTask `json:"tasks"`
+}
+
+// AssignedProjectTasks groups a user's tasks in one project.
+type AssignedProjectTasks struct {
+	ProjectID  string                   `json:"project_id"`
+	Milestones []AssignedMilestoneTasks `json:"milestones"`
+}
+
+// AssignedTasks is everything assigned to one user across projects, with
+// the overdue and due-soon tasks also listed on their own.
+type AssignedTasks struct {
+	AssigneeID string                 `json:"assignee_id"`
+	Total      int                    `json:"total"`
+	Overdue    []AssignedTask         `json:"overdue"`
+	DueSoon    []AssignedTask         `json:"due_soon"`
+	Projects   []AssignedProjectTasks `json:"projects"`
+}
+
+// AssignedTasksOptions filter the assigned tasks listings.
+type AssignedTasksOptions struct {
+	// DueSoonDays is how far ahead a task counts as due soon; the server
+	// default is 7.
+	DueSoonDays int
+	IncludeDone bool
+}
+
+// Review verdicts.
+const (
+	VerdictApprove        = "approve"
+	VerdictRequestChanges = "request_changes"
+	VerdictReject         = "reject"
+)
+
+// TaskReview is a reviewer's verdict on one submission round of a task.
+type TaskReview struct {
+	ID              string `json:"id"`
+	TaskID          string `json:"task_id"`
+	ReviewerID      string `json:"reviewer_id"`
+	Verdict         string `json:"verdict"`
+	Comments        string `json:"comments"`
+	SubmissionRound int    `json:"submission_round"`
+	CreatedAt       string `json:"created_at"`
+
+	// EvidenceIDs and EvidenceSHA256 identify the evidence that was
+	// reviewed.
+	EvidenceIDs    []string `json:"evidence_ids"`
+	EvidenceSHA256 []string `json:"evidence_sha256"`
+}
+
+type ReviewTaskRequest struct {
+	Verdict string `json:"verdict"`
+	// Comments are required unless the verdict is VerdictApprove.
+	Comments string `json:"comments,omitempty"`
+	// SubmissionRound, when set, must be the task's current round.
+	SubmissionRound *int `json:"submission_round,omitempty"`
+}
+
+// ReviewResponse is the recorded review and the task it moved.
+type ReviewResponse struct {
+	Review TaskReview `json:"review"`
+	Task   Task       `json:"task"`
+}
+
+// Evidence kinds.
+const (
+	EvidenceFile = "file"
+	EvidenceLink = "link"
+	EvidenceText = "text"
+)
+
+// Evidence is one item submitted for a task.
+type Evidence struct {
+	ID              string `json:"id"`
+	TaskID          string `json:"task_id"`
+	ProjectID       string `json:"project_id"`
+	Kind            string `json:"kind"`
+	FileName        string `json:"file_name,omitempty"`
+	URL             string `json:"url,omitempty"`
+	Body            string `json:"body,omitempty"`
+	MimeType        string `json:"mime_type,omitempty"`
+	Size            int64  `json:"size"`
+	SHA256          string `json:"sha256,omitempty"`
+	SubmissionRound int    `json:"submission_round"`
+	UploadedBy      string `json:"uploaded_by"`
+	CreatedAt       string `json:"created_at"`
+}
+
+// DownloadURL is a signed evidence link that works without a token until
+// ExpiresAt.
+type DownloadURL struct {
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// UploadPolicy narrows the server's upload limits for one project. Empty
+// or zero fields fall back to the server defaults.
+type UploadPolicy struct {
+	ProjectID        string   `json:"project_id,omitempty"`
+	AllowedMimeTypes []string `json:"allowed_mime_types"`
+	MaxFileBytes     int64    `json:"max_file_bytes"`
+	QuotaBytes       int64    `json:"quota_bytes"`
+	UpdatedAt        string   `json:"updated_at,omitempty"`
+	// UsedBytes is how much of the quota is used; it is ignored on update.
+	UsedBytes int64 `json:"used_bytes,omitempty"`
+}
+
+// ListOptions filter, sort and page a listing. When Limit and Cursor are
+// both unset the whole listing is returned in one page.
+type ListOptions struct {
+	Statuses  []string
+	DueBefore string
+	DueAfter  string
+	// Search matches title or description, case-insensitively.
+	Search string
+	Sort   string
+	Desc   bool
+	Limit  int
+	Cursor string
+
+	// Assignee and Reviewed apply to task listings only.
+	Assignee string
+	Reviewed *bool
+}
+
+// Page is one page of a listing. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
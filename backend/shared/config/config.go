@@ -0,0 +1,641 @@
+// Package config loads the settings shared by the Imara servers.
+//
+// Settings come from, in increasing order of precedence, the defaults, an
+// optional TOML file, environment variables and command line flags:
+//
+//	defaults := config.Default()
+//	defaults.Addr = ":8000"
+//	cfg, err := config.Load("server", defaults, os.Args[1:])
+//
+// The file is named by the -config flag or IMARA_CONFIG. Allowed CORS
+// origins are listed per environment, and the environment is picked with
+// -env or IMARA_ENV.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Environments with built-in origin lists.
+const (
+	Development = "development"
+	Production  = "production"
+)
+
+// Storage backends.
+const (
+	StorageSupabase = "supabase"
+	StorageMemory   = "memory"
+)
+
+// Evidence stores.
+const (
+	StoreLocal = "local"
+	StoreS3    = "s3"
+	StoreIPFS  = "ipfs"
+)
+
+// Virus scanners.
+const (
+	ScannerNone  = "none"
+	ScannerClamd = "clamd"
+	ScannerHTTP  = "http"
+)
+
+// ErrHelp is returned by Load when -h or -help was given. The usage has
+// already been printed, so callers should exit cleanly.
+var ErrHelp = flag.ErrHelp
+
+// Config is everything a server needs to know before it starts.
+type Config struct {
+	// Environment selects the allowed origins in OriginsByEnv.
+	Environment string `toml:"environment"`
+	// Addr is the address the server listens on, such as ":8000".
+	Addr string `toml:"addr"`
+
+	// AllowedOrigins overrides the origins of the selected environment.
+	// Use Origins to read the effective list.
+	AllowedOrigins []string `toml:"allowed_origins"`
+	// OriginsByEnv lists the origins allowed to call the API from a
+	// browser in each environment. "*" allows any origin.
+	OriginsByEnv map[string][]string `toml:"origins"`
+
+	// Storage is StorageSupabase or StorageMemory. Only backend/server
+	// supports the memory backend.
+	Storage  string   `toml:"storage"`
+	Supabase Supabase `toml:"supabase"`
+	// MemoryMemberships seeds project roles for the memory backend, as
+	// "project:user:role" entries.
+	MemoryMemberships []string `toml:"memory_memberships"`
+
+	Auth          Auth          `toml:"auth"`
+	Uploads       Uploads       `toml:"uploads"`
+	Notifications Notifications `toml:"notifications"`
+	Webhooks      Webhooks      `toml:"webhooks"`
+	Scheduler     Scheduler     `toml:"scheduler"`
+	Timeouts      Timeouts      `toml:"timeouts"`
+}
+
+// Supabase holds the project URL and keys. Both servers write with the
+// service key, since the tables they own are closed to the public key by
+// row level security.
+type Supabase struct {
+	URL        string `toml:"url"`
+	PublicKey  string `toml:"public_key"`
+	ServiceKey string `toml:"service_key"`
+}
+
+// Auth configures how the API server verifies Supabase access tokens:
+// with the project's JWT secret or with the keys in a JWKS file.
+type Auth struct {
+	JWTSecret string `toml:"jwt_secret"`
+	JWKSFile  string `toml:"jwks_file"`
+	Audience  string `toml:"audience"`
+	// Issuer is checked when set.
+	Issuer string `toml:"issuer"`
+}
+
+// Uploads are the server-wide evidence settings. Project upload policies
+// may narrow the limits but never widen them.
+type Uploads struct {
+	// Store is StoreLocal, StoreS3 or StoreIPFS.
+	Store string `toml:"store"`
+	// Dir is where the local evidence store keeps files.
+	Dir string `toml:"dir"`
+	S3  S3     `toml:"s3"`
+	// IPFSAPIURL is the Kubo RPC API of the IPFS store.
+	IPFSAPIURL string `toml:"ipfs_api_url"`
+
+	// URLSecret keys signed download links. Every replica needs the same
+	// secret for links to work across them and across restarts.
+	URLSecret string  `toml:"url_secret"`
+	Scanner   Scanner `toml:"scanner"`
+
+	// AllowedTypes are MIME types, with "image/*" wildcards allowed. Empty
+	// means the server's built-in list.
+	AllowedTypes []string `toml:"allowed_types"`
+	MaxFileBytes int64    `toml:"max_file_bytes"`
+	// QuotaBytes caps the evidence stored per project.
+	QuotaBytes int64 `toml:"quota_bytes"`
+	// MemoryBytes is how much of an upload is buffered in memory before
+	// the rest spills to a temporary file.
+	MemoryBytes int64 `toml:"memory_bytes"`
+}
+
+// S3 locates the bucket of the S3 evidence store, which also works with
+// MinIO and other S3-compatible services.
+type S3 struct {
+	Endpoint        string `toml:"endpoint"`
+	Region          string `toml:"region"`
+	Bucket          string `toml:"bucket"`
+	AccessKeyID     string `toml:"access_key_id"`
+	SecretAccessKey string `toml:"secret_access_key"`
+}
+
+// Scanner picks the virus scanner run on uploaded evidence.
+type Scanner struct {
+	// Kind is ScannerNone, ScannerClamd or ScannerHTTP.
+	Kind      string `toml:"kind"`
+	ClamdAddr string `toml:"clamd_addr"`
+	URL       string `toml:"url"`
+}
+
+// Notifications configures the email and webhook channels of the
+// notifier. Email is off when SMTP.Host is empty.
+type Notifications struct {
+	SMTP SMTP `toml:"smtp"`
+	// AllowHTTPWebhooks lets users' notification webhooks use plain http.
+	AllowHTTPWebhooks bool `toml:"allow_http_webhooks"`
+}
+
+// SMTP is the mail server notification emails are sent through.
+type SMTP struct {
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	From     string `toml:"from"`
+}
+
+// Webhooks configures project webhooks.
+type Webhooks struct {
+	// AllowHTTP lets project webhooks use plain http.
+	AllowHTTP bool `toml:"allow_http"`
+	// SecretKey encrypts the webhooks' signing secrets in the database.
+	// Changing it makes the stored secrets unreadable.
+	SecretKey string `toml:"secret_key"`
+}
+
+// Scheduler configures the background jobs.
+type Scheduler struct {
+	// Disabled turns the jobs off on this replica.
+	Disabled       bool          `toml:"disabled"`
+	OverdueSweeps  time.Duration `toml:"overdue_sweep_interval"`
+	WebhookRetries time.Duration `toml:"webhook_retry_interval"`
+	// EvidenceGC is how often stored evidence files no longer referenced
+	// by any evidence item are deleted.
+	EvidenceGC time.Duration `toml:"evidence_gc_interval"`
+}
+
+// Timeouts bound how long the HTTP server waits on clients. Zero read,
+// write and idle timeouts mean no limit, as in http.Server.
+type Timeouts struct {
+	ReadHeader time.Duration `toml:"read_header"`
+	// Read covers the whole request, including uploaded files.
+	Read time.Duration `toml:"read"`
+	// Write covers the handler and the response.
+	Write time.Duration `toml:"write"`
+	Idle  time.Duration `toml:"idle"`
+	// Transfer replaces Read and Write for evidence uploads and downloads,
+	// which move whole files and can take longer than other requests.
+	Transfer time.Duration `toml:"transfer"`
+	// Shutdown is how long in-flight requests and WebSocket clients get
+	// to finish after SIGTERM before their connections are closed.
+	Shutdown time.Duration `toml:"shutdown"`
+}
+
+// Default returns the settings used when nothing else is given. Addr is
+// left for each server to set.
+func Default() Config {
+	return Config{
+		Environment: Production,
+		OriginsByEnv: map[string][]string{
+			Development: {"http://localhost:3000", "http://localhost:5173"},
+			Production:  {"https://www.imarahub.xyz", "https://imarahub.xyz"},
+		},
+		Storage: StorageSupabase,
+		Auth:    Auth{Audience: "authenticated"},
+		Uploads: Uploads{
+			Store:        StoreLocal,
+			Dir:          "./uploads",
+			Scanner:      Scanner{Kind: ScannerNone, ClamdAddr: "127.0.0.1:3310"},
+			MaxFileBytes: 10 << 20,
+			QuotaBytes:   1 << 30,
+			MemoryBytes:  10 << 20,
+		},
+		Notifications: Notifications{SMTP: SMTP{Port: 587}},
+		Scheduler: Scheduler{
+			OverdueSweeps:  5 * time.Minute,
+			WebhookRetries: 30 * time.Second,
+			EvidenceGC:     time.Hour,
+		},
+		Timeouts: Timeouts{
+			ReadHeader: 10 * time.Second,
+			Read:       2 * time.Minute,
+			Write:      2 * time.Minute,
+			Idle:       2 * time.Minute,
+			Transfer:   time.Hour,
+			Shutdown:   30 * time.Second,
+		},
+	}
+}
+
+// Origins returns the origins allowed in the selected environment.
+func (c *Config) Origins() []string {
+	if len(c.AllowedOrigins) > 0 {
+		return c.AllowedOrigins
+	}
+	return c.OriginsByEnv[c.Environment]
+}
+
+// AllowOrigin reports whether a browser at origin may call the server.
+func (c *Config) AllowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range c.Origins() {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setting is one value that can be set from the environment and, when
+// flag is not empty, a flag. The file sets the same fields through the
+// toml tags on Config.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func text(dst func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*dst(c) = v
+		return nil
+	}
+}
+
+func list(dst func(c *Config) *[]string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*dst(c) = splitList(v)
+		return nil
+	}
+}
+
+func boolean(dst func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*dst(c) = b
+		return nil
+	}
+}
+
+func bytes(dst func(c *Config) *int64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.ParseInt(strings.ReplaceAll(v, "_", ""), 10, 64)
+		if err != nil {
+			return errors.New("must be a number of bytes")
+		}
+		*dst(c) = n
+		return nil
+	}
+}
+
+func duration(dst func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 2m")
+		}
+		*dst(c) = d
+		return nil
+	}
+}
+
+var settings = []setting{
+	{"IMARA_ENV", "env", "environment whose allowed origins are used", text(func(c *Config) *string { return &c.Environment })},
+	{"ADDR", "addr", "address to listen on", text(func(c *Config) *string { return &c.Addr })},
+	{"ALLOWED_ORIGINS", "allowed-origins", "comma separated origins, replacing the environment's list", list(func(c *Config) *[]string { return &c.AllowedOrigins })},
+	{"STORAGE_BACKEND", "storage", "storage backend: supabase or memory", text(func(c *Config) *string { return &c.Storage })},
+	{"SUPABASE_URL", "supabase-url", "Supabase project URL", text(func(c *Config) *string { return &c.Supabase.URL })},
+	{"SUPABASE_PUBLIC_KEY", "", "", text(func(c *Config) *string { return &c.Supabase.PublicKey })},
+	{"SUPABASE_SERVICE_KEY", "", "", text(func(c *Config) *string { return &c.Supabase.ServiceKey })},
+	{"MEMORY_MEMBERSHIPS", "", "", list(func(c *Config) *[]string { return &c.MemoryMemberships })},
+
+	{"SUPABASE_JWT_SECRET", "", "", text(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"SUPABASE_JWKS_FILE", "", "", text(func(c *Config) *string { return &c.Auth.JWKSFile })},
+	{"SUPABASE_JWT_AUDIENCE", "", "", text(func(c *Config) *string { return &c.Auth.Audience })},
+	{"SUPABASE_JWT_ISSUER", "", "", text(func(c *Config) *string { return &c.Auth.Issuer })},
+
+	{"EVIDENCE_STORE", "evidence-store", "evidence store: local, s3 or ipfs", text(func(c *Config) *string { return &c.Uploads.Store })},
+	{"EVIDENCE_DIR", "uploads-dir", "directory for locally stored evidence", text(func(c *Config) *string { return &c.Uploads.Dir })},
+	{"S3_ENDPOINT", "", "", text(func(c *Config) *string { return &c.Uploads.S3.Endpoint })},
+	{"S3_REGION", "", "", text(func(c *Config) *string { return &c.Uploads.S3.Region })},
+	{"S3_BUCKET", "", "", text(func(c *Config) *string { return &c.Uploads.S3.Bucket })},
+	{"S3_ACCESS_KEY_ID", "", "", text(func(c *Config) *string { return &c.Uploads.S3.AccessKeyID })},
+	{"S3_SECRET_ACCESS_KEY", "", "", text(func(c *Config) *string { return &c.Uploads.S3.SecretAccessKey })},
+	{"IPFS_API_URL", "", "", text(func(c *Config) *string { return &c.Uploads.IPFSAPIURL })},
+	{"EVIDENCE_URL_SECRET", "", "", text(func(c *Config) *string { return &c.Uploads.URLSecret })},
+	{"EVIDENCE_SCANNER", "", "", text(func(c *Config) *string { return &c.Uploads.Scanner.Kind })},
+	{"CLAMD_ADDR", "", "", text(func(c *Config) *string { return &c.Uploads.Scanner.ClamdAddr })},
+	{"SCANNER_URL", "", "", text(func(c *Config) *string { return &c.Uploads.Scanner.URL })},
+	{"EVIDENCE_ALLOWED_TYPES", "", "", list(func(c *Config) *[]string { return &c.Uploads.AllowedTypes })},
+	{"EVIDENCE_MAX_FILE_BYTES", "max-upload-bytes", "largest evidence file accepted", bytes(func(c *Config) *int64 { return &c.Uploads.MaxFileBytes })},
+	{"EVIDENCE_QUOTA_BYTES", "", "", bytes(func(c *Config) *int64 { return &c.Uploads.QuotaBytes })},
+	{"EVIDENCE_MEMORY_BYTES", "", "", bytes(func(c *Config) *int64 { return &c.Uploads.MemoryBytes })},
+
+	{"SMTP_HOST", "", "", text(func(c *Config) *string { return &c.Notifications.SMTP.Host })},
+	{"SMTP_PORT", "", "", func(c *Config, v string) error {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.New("must be a port number")
+		}
+		c.Notifications.SMTP.Port = port
+		return nil
+	}},
+	{"SMTP_USERNAME", "", "", text(func(c *Config) *string { return &c.Notifications.SMTP.Username })},
+	{"SMTP_PASSWORD", "", "", text(func(c *Config) *string { return &c.Notifications.SMTP.Password })},
+	{"SMTP_FROM", "", "", text(func(c *Config) *string { return &c.Notifications.SMTP.From })},
+	{"NOTIFICATION_WEBHOOK_ALLOW_HTTP", "", "", boolean(func(c *Config) *bool { return &c.Notifications.AllowHTTPWebhooks })},
+	{"WEBHOOK_ALLOW_HTTP", "", "", boolean(func(c *Config) *bool { return &c.Webhooks.AllowHTTP })},
+	{"WEBHOOK_SECRET_KEY", "", "", text(func(c *Config) *string { return &c.Webhooks.SecretKey })},
+
+	{"SCHEDULER_DISABLED", "no-scheduler", "do not run background jobs on this replica", boolean(func(c *Config) *bool { return &c.Scheduler.Disabled })},
+	{"OVERDUE_SWEEP_INTERVAL", "", "", duration(func(c *Config) *time.Duration { return &c.Scheduler.OverdueSweeps })},
+	{"WEBHOOK_RETRY_INTERVAL", "", "", duration(func(c *Config) *time.Duration { return &c.Scheduler.WebhookRetries })},
+	{"EVIDENCE_GC_INTERVAL", "", "", duration(func(c *Config) *time.Duration { return &c.Scheduler.EvidenceGC })},
+
+	{"HTTP_READ_HEADER_TIMEOUT", "", "", duration(func(c *Config) *time.Duration { return &c.Timeouts.ReadHeader })},
+	{"HTTP_READ_TIMEOUT", "read-timeout", "longest time to read a request, such as 2m", duration(func(c *Config) *time.Duration { return &c.Timeouts.Read })},
+	{"HTTP_WRITE_TIMEOUT", "write-timeout", "longest time to handle a request and write the response", duration(func(c *Config) *time.Duration { return &c.Timeouts.Write })},
+	{"HTTP_IDLE_TIMEOUT", "idle-timeout", "how long idle keep-alive connections are kept", duration(func(c *Config) *time.Duration { return &c.Timeouts.Idle })},
+	{"HTTP_TRANSFER_TIMEOUT", "", "", duration(func(c *Config) *time.Duration { return &c.Timeouts.Transfer })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long to drain requests on shutdown", duration(func(c *Config) *time.Duration { return &c.Timeouts.Shutdown })},
+}
+
+// Load reads the settings over defaults and validates them. args are the
+// command line arguments without the program name; name is used in the
+// usage. It returns ErrHelp after printing the usage for -h.
+func Load(name string, defaults Config, args []string) (Config, error) {
+	cfg := defaults
+	cfg.OriginsByEnv = make(map[string][]string, len(defaults.OriginsByEnv))
+	for env, origins := range defaults.OriginsByEnv {
+		cfg.OriginsByEnv[env] = origins
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("IMARA_CONFIG"), "path to a TOML config file (IMARA_CONFIG)")
+	flags := map[string]*string{}
+	for _, s := range settings {
+		if s.flag != "" {
+			flags[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (%s)", s.usage, s.env))
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	// The startup message used to ask for SUPABASE_KEY, so accept it for
+	// deployments that followed it.
+	if cfg.Supabase.PublicKey == "" {
+		cfg.Supabase.PublicKey = os.Getenv("SUPABASE_KEY")
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && err == nil {
+				if setErr := s.set(&cfg, *flags[s.flag]); setErr != nil {
+					err = fmt.Errorf("-%s: %w", s.flag, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	for i, t := range cfg.Uploads.AllowedTypes {
+		cfg.Uploads.AllowedTypes[i] = strings.ToLower(strings.TrimSpace(t))
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// loadFile applies the settings in a TOML file. Keys the file sets
+// replace the current values; unknown keys are an error so typos do not
+// go unnoticed.
+func (c *Config) loadFile(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("config file %s: unknown settings %s", path, strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once. Settings a server
+// requires, such as its Supabase key, are checked by that server.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Environment == "" {
+		errs = append(errs, errors.New("environment must not be empty"))
+	}
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr %q is not a host:port address", c.Addr))
+	}
+
+	origins := c.Origins()
+	if len(origins) == 0 {
+		errs = append(errs, fmt.Errorf("no allowed origins for environment %q", c.Environment))
+	}
+	for _, origin := range origins {
+		if err := checkOrigin(origin); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch c.Storage {
+	case StorageSupabase, StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage backend %q", c.Storage))
+	}
+	if c.Supabase.URL != "" {
+		errs = append(errs, checkURL("supabase url", c.Supabase.URL))
+	}
+	for _, entry := range c.MemoryMemberships {
+		errs = append(errs, checkMembership(entry))
+	}
+	if c.Auth.Audience == "" {
+		errs = append(errs, errors.New("auth audience must not be empty"))
+	}
+
+	errs = append(errs, c.Uploads.validate()...)
+
+	smtp := c.Notifications.SMTP
+	if smtp.Port < 1 || smtp.Port > 65535 {
+		errs = append(errs, fmt.Errorf("smtp port %d is out of range", smtp.Port))
+	}
+	if smtp.Host != "" && smtp.From == "" {
+		errs = append(errs, errors.New("smtp from is required when smtp host is set"))
+	}
+
+	if c.Webhooks.SecretKey != "" && len(c.Webhooks.SecretKey) < 32 {
+		errs = append(errs, errors.New("webhooks secret_key must be at least 32 characters"))
+	}
+
+	if c.Scheduler.OverdueSweeps < time.Second {
+		errs = append(errs, errors.New("scheduler overdue_sweep_interval must be at least 1s"))
+	}
+	if c.Scheduler.WebhookRetries < time.Second {
+		errs = append(errs, errors.New("scheduler webhook_retry_interval must be at least 1s"))
+	}
+	if c.Scheduler.EvidenceGC < time.Second {
+		errs = append(errs, errors.New("scheduler evidence_gc_interval must be at least 1s"))
+	}
+
+	if c.Timeouts.ReadHeader <= 0 {
+		errs = append(errs, errors.New("timeouts read_header must be positive"))
+	}
+	if c.Timeouts.Read < 0 || c.Timeouts.Write < 0 || c.Timeouts.Idle < 0 || c.Timeouts.Transfer < 0 {
+		errs = append(errs, errors.New("timeouts must not be negative"))
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		errs = append(errs, errors.New("timeouts shutdown must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (u *Uploads) validate() []error {
+	var errs []error
+	switch u.Store {
+	case StoreLocal:
+		if u.Dir == "" {
+			errs = append(errs, errors.New("uploads dir must not be empty"))
+		}
+	case StoreS3:
+		s3 := u.S3
+		if s3.Endpoint == "" || s3.Region == "" || s3.Bucket == "" || s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+			errs = append(errs, errors.New("the s3 evidence store needs an endpoint, region, bucket, access key id and secret access key"))
+		} else {
+			errs = append(errs, checkURL("s3 endpoint", s3.Endpoint))
+		}
+	case StoreIPFS:
+		if u.IPFSAPIURL == "" {
+			errs = append(errs, errors.New("the ipfs evidence store needs ipfs_api_url"))
+		} else {
+			errs = append(errs, checkURL("ipfs_api_url", u.IPFSAPIURL))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown evidence store %q", u.Store))
+	}
+
+	if u.URLSecret != "" && len(u.URLSecret) < 32 {
+		errs = append(errs, errors.New("uploads url_secret must be at least 32 characters"))
+	}
+
+	switch u.Scanner.Kind {
+	case ScannerNone:
+	case ScannerClamd:
+		if _, _, err := net.SplitHostPort(u.Scanner.ClamdAddr); err != nil {
+			errs = append(errs, fmt.Errorf("clamd_addr %q is not a host:port address", u.Scanner.ClamdAddr))
+		}
+	case ScannerHTTP:
+		if u.Scanner.URL == "" {
+			errs = append(errs, errors.New("the http scanner needs a url"))
+		} else {
+			errs = append(errs, checkURL("scanner url", u.Scanner.URL))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown virus scanner %q", u.Scanner.Kind))
+	}
+
+	for name, n := range map[string]int64{
+		"uploads max_file_bytes": u.MaxFileBytes,
+		"uploads quota_bytes":    u.QuotaBytes,
+		"uploads memory_bytes":   u.MemoryBytes,
+	} {
+		if n <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if u.QuotaBytes > 0 && u.MaxFileBytes > u.QuotaBytes {
+		errs = append(errs, errors.New("uploads max_file_bytes must not exceed quota_bytes"))
+	}
+	return errs
+}
+
+// checkOrigin accepts "*" or a scheme and host with no path, as browsers
+// send in the Origin header.
+func checkOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("allowed origin %q must look like https://example.com", origin)
+	}
+	if u.Path == "/" {
+		return fmt.Errorf("allowed origin %q must not end with a slash", origin)
+	}
+	return nil
+}
+
+func checkURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s %q must be an http or https URL", name, raw)
+	}
+	return nil
+}
+
+// checkMembership accepts a "project:user:role" seed entry.
+func checkMembership(entry string) error {
+	parts := strings.Split(entry, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("memory membership %q is not project:user:role", entry)
+	}
+	switch parts[2] {
+	case "lead", "contributor", "investor", "viewer":
+		return nil
+	default:
+		return fmt.Errorf("memory membership %q has unknown role %q", entry, parts[2])
+	}
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
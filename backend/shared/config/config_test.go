@@ -0,0 +1,314 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cleanEnv unsets every variable Load reads, so the tests do not pick up
+// the settings of the machine they run on.
+func cleanEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"IMARA_CONFIG", "SUPABASE_KEY"} {
+		t.Setenv(name, "")
+	}
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+}
+
+// writeFile writes a TOML config file and returns its path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "imara.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testDefaults() Config {
+	defaults := Default()
+	defaults.Addr = ":8000"
+	return defaults
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, `
+addr = ":7000"
+environment = "development"
+
+[timeouts]
+read = "5m"
+shutdown = "20s"
+
+[uploads]
+max_file_bytes = 2_000_000
+
+[scheduler]
+evidence_gc_interval = "2h"
+`)
+	cases := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		check func(c Config) bool
+	}{
+		{
+			name: "defaults",
+			check: func(c Config) bool {
+				return c.Addr == ":8000" && c.Environment == Production && c.Timeouts.Read == 2*time.Minute
+			},
+		},
+		{
+			name: "file over defaults",
+			args: []string{"-config", file},
+			check: func(c Config) bool {
+				return c.Addr == ":7000" && c.Environment == Development && c.Timeouts.Read == 5*time.Minute &&
+					c.Uploads.MaxFileBytes == 2_000_000 && c.Scheduler.EvidenceGC == 2*time.Hour &&
+					// Keys the file leaves out keep their defaults
+					c.Timeouts.Write == 2*time.Minute && c.Uploads.QuotaBytes == 1<<30
+			},
+		},
+		{
+			name:  "file named by IMARA_CONFIG",
+			env:   map[string]string{"IMARA_CONFIG": file},
+			check: func(c Config) bool { return c.Addr == ":7000" },
+		},
+		{
+			name: "env over file",
+			env:  map[string]string{"ADDR": ":6000", "HTTP_READ_TIMEOUT": "1m", "EVIDENCE_MAX_FILE_BYTES": "1_000_000"},
+			args: []string{"-config", file},
+			check: func(c Config) bool {
+				return c.Addr == ":6000" && c.Timeouts.Read == time.Minute && c.Uploads.MaxFileBytes == 1_000_000 &&
+					c.Timeouts.Shutdown == 20*time.Second
+			},
+		},
+		{
+			name: "flags over env",
+			env:  map[string]string{"ADDR": ":6000", "HTTP_READ_TIMEOUT": "1m", "IMARA_ENV": Production},
+			args: []string{"-config", file, "-addr", ":5000", "-read-timeout", "30s"},
+			check: func(c Config) bool {
+				return c.Addr == ":5000" && c.Timeouts.Read == 30*time.Second && c.Environment == Production
+			},
+		},
+		{
+			name:  "empty env is unset",
+			env:   map[string]string{"ADDR": ""},
+			args:  []string{"-config", file},
+			check: func(c Config) bool { return c.Addr == ":7000" },
+		},
+		{
+			name: "lists and flags without env",
+			env:  map[string]string{"ALLOWED_ORIGINS": " https://a.example, ,https://b.example", "EVIDENCE_ALLOWED_TYPES": " Image/PNG ,application/pdf"},
+			args: []string{"-no-scheduler", "true"},
+			check: func(c Config) bool {
+				return reflect.DeepEqual(c.Origins(), []string{"https://a.example", "https://b.example"}) &&
+					reflect.DeepEqual(c.Uploads.AllowedTypes, []string{"image/png", "application/pdf"}) &&
+					c.Scheduler.Disabled
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cleanEnv(t)
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := Load("server", testDefaults(), c.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.check(cfg) {
+				t.Errorf("config = %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadLeavesDefaultsAlone(t *testing.T) {
+	cleanEnv(t)
+	file := writeFile(t, "[origins]\ndevelopment = [\"http://localhost:4000\"]\n")
+	defaults := testDefaults()
+	if _, err := Load("server", defaults, []string{"-config", file, "-env", Development}); err != nil {
+		t.Fatal(err)
+	}
+	if got := defaults.OriginsByEnv[Development]; !reflect.DeepEqual(got, Default().OriginsByEnv[Development]) {
+		t.Errorf("defaults changed to %v", got)
+	}
+}
+
+func TestLoadLegacySupabaseKey(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{"legacy only", map[string]string{"SUPABASE_KEY": "legacy"}, "", "legacy"},
+		{"public key wins", map[string]string{"SUPABASE_KEY": "legacy", "SUPABASE_PUBLIC_KEY": "public"}, "", "public"},
+		{"file wins", map[string]string{"SUPABASE_KEY": "legacy"}, "[supabase]\npublic_key = \"from-file\"\n", "from-file"},
+		{"neither", nil, "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cleanEnv(t)
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			var args []string
+			if c.file != "" {
+				args = []string{"-config", writeFile(t, c.file)}
+			}
+			cfg, err := Load("server", testDefaults(), args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Supabase.PublicKey != c.want {
+				t.Errorf("public key = %q, want %q", cfg.Supabase.PublicKey, c.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsBadInput(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		file string
+		args []string
+		want string
+	}{
+		{name: "unknown file key", file: "adr = \":7000\"\n", want: "unknown settings adr"},
+		{name: "bad toml", file: "addr = \n", want: "config file"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/imara.toml"}, want: "config file"},
+		{name: "bad env duration", env: map[string]string{"HTTP_READ_TIMEOUT": "soon"}, want: "HTTP_READ_TIMEOUT: must be a duration"},
+		{name: "bad env bool", env: map[string]string{"WEBHOOK_ALLOW_HTTP": "yes please"}, want: "WEBHOOK_ALLOW_HTTP: must be true or false"},
+		{name: "bad env port", env: map[string]string{"SMTP_PORT": "smtp"}, want: "SMTP_PORT: must be a port number"},
+		{name: "bad flag bytes", args: []string{"-max-upload-bytes", "10MB"}, want: "-max-upload-bytes: must be a number of bytes"},
+		{name: "unknown flag", args: []string{"-verbose"}, want: "not defined"},
+		{name: "invalid result", args: []string{"-addr", "8000"}, want: `addr "8000"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cleanEnv(t)
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			args := c.args
+			if c.file != "" {
+				args = append([]string{"-config", writeFile(t, c.file)}, args...)
+			}
+			_, err := Load("server", testDefaults(), args)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("err = %v, want %q", err, c.want)
+			}
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	cleanEnv(t)
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	defer func() { os.Stderr = stderr }()
+	if _, err := Load("server", testDefaults(), []string{"-h"}); !errors.Is(err, ErrHelp) {
+		t.Errorf("err = %v, want ErrHelp", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if cfg := testDefaults(); cfg.Validate() != nil {
+		t.Fatalf("defaults are invalid: %v", cfg.Validate())
+	}
+
+	secret := strings.Repeat("s", 32)
+	cases := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"empty environment", func(c *Config) { c.Environment = "" }, "environment must not be empty"},
+		{"addr", func(c *Config) { c.Addr = "8000" }, `addr "8000" is not a host:port address`},
+		{"no origins", func(c *Config) { c.Environment = "staging" }, `no allowed origins for environment "staging"`},
+		{"origin path", func(c *Config) { c.AllowedOrigins = []string{"https://a.example/app"} }, "must look like https://example.com"},
+		{"origin scheme", func(c *Config) { c.AllowedOrigins = []string{"a.example"} }, "must look like https://example.com"},
+		{"origin slash", func(c *Config) { c.AllowedOrigins = []string{"https://a.example/"} }, "must not end with a slash"},
+		{"storage", func(c *Config) { c.Storage = "postgres" }, `unknown storage backend "postgres"`},
+		{"supabase url", func(c *Config) { c.Supabase.URL = "db.example" }, `supabase url "db.example" must be an http or https URL`},
+		{"membership shape", func(c *Config) { c.MemoryMemberships = []string{"p1:lead"} }, "is not project:user:role"},
+		{"membership role", func(c *Config) { c.MemoryMemberships = []string{"p1:u1:owner"} }, `unknown role "owner"`},
+		{"audience", func(c *Config) { c.Auth.Audience = "" }, "auth audience must not be empty"},
+		{"uploads dir", func(c *Config) { c.Uploads.Dir = "" }, "uploads dir must not be empty"},
+		{"s3 settings", func(c *Config) { c.Uploads.Store = StoreS3; c.Uploads.S3.Bucket = "evidence" }, "the s3 evidence store needs"},
+		{"s3 endpoint", func(c *Config) {
+			c.Uploads.Store = StoreS3
+			c.Uploads.S3 = S3{Endpoint: "s3.example", Region: "r", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "key"}
+		}, `s3 endpoint "s3.example"`},
+		{"ipfs url missing", func(c *Config) { c.Uploads.Store = StoreIPFS }, "needs ipfs_api_url"},
+		{"ipfs url", func(c *Config) { c.Uploads.Store = StoreIPFS; c.Uploads.IPFSAPIURL = "localhost:5001" }, `ipfs_api_url "localhost:5001"`},
+		{"evidence store", func(c *Config) { c.Uploads.Store = "ftp" }, `unknown evidence store "ftp"`},
+		{"url secret", func(c *Config) { c.Uploads.URLSecret = secret[1:] }, "url_secret must be at least 32 characters"},
+		{"clamd addr", func(c *Config) { c.Uploads.Scanner = Scanner{Kind: ScannerClamd, ClamdAddr: "clamd"} }, `clamd_addr "clamd"`},
+		{"scanner url missing", func(c *Config) { c.Uploads.Scanner.Kind = ScannerHTTP }, "the http scanner needs a url"},
+		{"scanner url", func(c *Config) { c.Uploads.Scanner = Scanner{Kind: ScannerHTTP, URL: "scan"} }, `scanner url "scan"`},
+		{"scanner", func(c *Config) { c.Uploads.Scanner.Kind = "antivirus" }, `unknown virus scanner "antivirus"`},
+		{"max file bytes", func(c *Config) { c.Uploads.MaxFileBytes = 0 }, "uploads max_file_bytes must be positive"},
+		{"quota bytes", func(c *Config) { c.Uploads.QuotaBytes = -1 }, "uploads quota_bytes must be positive"},
+		{"memory bytes", func(c *Config) { c.Uploads.MemoryBytes = 0 }, "uploads memory_bytes must be positive"},
+		{"file over quota", func(c *Config) { c.Uploads.QuotaBytes = c.Uploads.MaxFileBytes - 1 }, "must not exceed quota_bytes"},
+		{"smtp port", func(c *Config) { c.Notifications.SMTP.Port = 70000 }, "smtp port 70000 is out of range"},
+		{"smtp from", func(c *Config) { c.Notifications.SMTP.Host = "smtp.example" }, "smtp from is required"},
+		{"webhook secret key", func(c *Config) { c.Webhooks.SecretKey = "short" }, "secret_key must be at least 32 characters"},
+		{"overdue sweeps", func(c *Config) { c.Scheduler.OverdueSweeps = time.Millisecond }, "overdue_sweep_interval must be at least 1s"},
+		{"webhook retries", func(c *Config) { c.Scheduler.WebhookRetries = 0 }, "webhook_retry_interval must be at least 1s"},
+		{"evidence gc", func(c *Config) { c.Scheduler.EvidenceGC = 999 * time.Millisecond }, "evidence_gc_interval must be at least 1s"},
+		{"read header", func(c *Config) { c.Timeouts.ReadHeader = 0 }, "read_header must be positive"},
+		{"negative timeout", func(c *Config) { c.Timeouts.Transfer = -time.Second }, "timeouts must not be negative"},
+		{"shutdown", func(c *Config) { c.Timeouts.Shutdown = 0 }, "timeouts shutdown must be positive"},
+	}
+	for _, c := range cases {
+		cfg := testDefaults()
+		c.change(&cfg)
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want %q", c.name, err, c.want)
+		}
+	}
+
+	// Every problem is reported, and valid optional settings pass
+	cfg := testDefaults()
+	cfg.Addr, cfg.Storage = "", "postgres"
+	if err := cfg.Validate(); err == nil || strings.Count(err.Error(), "\n") != 1 {
+		t.Errorf("two problems reported as %v", err)
+	}
+	cfg = testDefaults()
+	cfg.Uploads.URLSecret, cfg.Webhooks.SecretKey = secret, secret
+	cfg.AllowedOrigins = []string{"*"}
+	cfg.Uploads.Scanner = Scanner{Kind: ScannerClamd, ClamdAddr: "127.0.0.1:3310"}
+	cfg.MemoryMemberships = []string{"p1:u1:viewer"}
+	cfg.Timeouts.Read, cfg.Timeouts.Write, cfg.Timeouts.Idle = 0, 0, 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid settings: %v", err)
+	}
+}
+
+func TestAllowOrigin(t *testing.T) {
+	cfg := testDefaults()
+	if !cfg.AllowOrigin("https://IMARAHUB.xyz") || cfg.AllowOrigin("http://localhost:3000") || cfg.AllowOrigin("") {
+		t.Error("production origins")
+	}
+	cfg.Environment = Development
+	if !cfg.AllowOrigin("http://localhost:3000") {
+		t.Error("development origins")
+	}
+	cfg.AllowedOrigins = []string{"*"}
+	if !cfg.AllowOrigin("https://anything.example") || cfg.AllowOrigin("") {
+		t.Error("wildcard")
+	}
+}
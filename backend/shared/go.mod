@@ -0,0 +1,5 @@
+module imara-shared
+
+go 1.23.4
+
+require github.com/BurntSushi/toml v1.6.0
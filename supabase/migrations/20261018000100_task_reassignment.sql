@@ -0,0 +1,43 @@
+-- Track the outgoing assignee when a task is reassigned
+alter table milestone_tasks add column if not exists previous_assignee_id uuid references auth.users(id) on delete set null;
+alter table milestone_tasks add column if not exists reassigned_at timestamp with time zone;
+
+-- Delete a milestone and deal with its tasks in one transaction. on_tasks
+-- is block (refuse while the milestone has tasks), cascade (delete them) or
+-- move (to another milestone of the same project). Locking the milestone
+-- row also holds off tasks being added to it meanwhile.
+create or replace function delete_milestone(p_milestone_id uuid, p_on_tasks text, p_target_milestone_id uuid default null)
+returns void
+language plpgsql
+as $$
+declare
+  v_project_id milestones.project_id%type;
+begin
+  select project_id into v_project_id from milestones where id = p_milestone_id for update;
+  if not found then
+    raise exception 'milestone not found' using errcode = 'P0002';
+  end if;
+
+  if p_on_tasks = 'block' then
+    if exists (select 1 from milestone_tasks where milestone_id = p_milestone_id) then
+      raise exception 'milestone has tasks' using errcode = '23503';
+    end if;
+  elsif p_on_tasks = 'cascade' then
+    delete from milestone_tasks where milestone_id = p_milestone_id;
+  elsif p_on_tasks = 'move' then
+    perform 1 from milestones
+      where id = p_target_milestone_id and id <> p_milestone_id and project_id = v_project_id
+      for share;
+    if not found then
+      raise exception 'target milestone must be another milestone of the same project' using errcode = '22023';
+    end if;
+    update milestone_tasks set milestone_id = p_target_milestone_id where milestone_id = p_milestone_id;
+  else
+    raise exception 'on_tasks must be block, cascade or move' using errcode = '22023';
+  end if;
+
+  delete from milestones where id = p_milestone_id;
+end;
+$$;
+
+revoke execute on function delete_milestone(uuid, text, uuid) from public, anon, authenticated;
@@ -0,0 +1,67 @@
+-- Move tasks onto the enforced lifecycle:
+-- pending -> in_progress -> submitted -> changes_requested/approved -> closed
+-- Completed tasks that were already reviewed count as approved; the rest
+-- wait for a review.
+update milestone_tasks set status = 'approved' where status = 'completed' and reviewed;
+update milestone_tasks set status = 'submitted', reviewed = false where status = 'completed';
+
+alter table milestone_tasks drop constraint if exists milestone_tasks_status_check;
+alter table milestone_tasks add constraint milestone_tasks_status_check
+  check (status in ('pending', 'in_progress', 'submitted', 'changes_requested', 'approved', 'closed'));
+
+-- Append-only log of task status transitions
+create table if not exists task_status_history (
+  id uuid default uuid_generate_v4() primary key,
+  task_id uuid references milestone_tasks(id) on delete cascade not null,
+  from_status text,
+  to_status text not null,
+  changed_by uuid references auth.users(id) on delete set null,
+  note text,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+create index if not exists task_status_history_task_id_idx on task_status_history(task_id, created_at);
+
+-- Membership checks for row level security. They run as their owner so
+-- policies work whatever the policies on ideas, milestones and tasks are.
+-- The lead is the idea's creator; contributors and investors count once
+-- they have applied or been approved, as in the API.
+create or replace function is_project_member(p_project_id uuid)
+returns boolean
+language sql
+stable
+security definer
+set search_path = public
+as $$
+  select exists (
+    select 1 from ideas
+    where ideas.id = p_project_id and ideas.uid = auth.uid()
+  ) or exists (
+    select 1 from idea_contributors
+    where idea_contributors.idea_id = p_project_id
+    and idea_contributors.user_id = auth.uid()
+    and idea_contributors.approved_status in ('pending', 'approved')
+  );
+$$;
+
+create or replace function is_task_member(p_task_id uuid)
+returns boolean
+language sql
+stable
+security definer
+set search_path = public
+as $$
+  select exists (
+    select 1 from milestone_tasks
+    join milestones on milestones.id = milestone_tasks.milestone_id
+    where milestone_tasks.id = p_task_id and is_project_member(milestones.project_id)
+  );
+$$;
+
+-- Set up Row Level Security (RLS) policies. The API writes the history
+-- with the service key; project members may read it.
+alter table task_status_history enable row level security;
+
+create policy "Project members can view task status history"
+  on task_status_history for select
+  using (is_task_member(task_status_history.task_id));
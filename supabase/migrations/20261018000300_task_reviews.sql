@@ -0,0 +1,24 @@
+-- Count submissions so reviews can be tied to a specific round
+alter table milestone_tasks add column if not exists submission_round integer default 0 not null;
+
+-- Reviewer verdicts on task submissions
+create table if not exists task_reviews (
+  id uuid default uuid_generate_v4() primary key,
+  task_id uuid references milestone_tasks(id) on delete cascade not null,
+  reviewer_id uuid references auth.users(id) on delete set null,
+  verdict text not null check (verdict in ('approve', 'request_changes', 'reject')),
+  comments text,
+  submission_round integer not null,
+  evidence_url text,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+create index if not exists task_reviews_task_id_idx on task_reviews(task_id, created_at);
+
+-- Set up Row Level Security (RLS) policies. Reviews are written by the API
+-- with the service key; project members may read them.
+alter table task_reviews enable row level security;
+
+create policy "Project members can view task reviews"
+  on task_reviews for select
+  using (is_task_member(task_reviews.task_id));
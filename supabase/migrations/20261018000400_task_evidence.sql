@@ -0,0 +1,49 @@
+-- Evidence items submitted for a task: uploaded files, links and text notes
+create table if not exists task_evidence (
+  id uuid default uuid_generate_v4() primary key,
+  task_id uuid references milestone_tasks(id) on delete cascade not null,
+  kind text not null check (kind in ('file', 'link', 'text')),
+  file_name text,
+  url text,
+  body text,
+  mime_type text,
+  size bigint default 0 not null,
+  sha256 text check (sha256 ~ '^[0-9a-f]{64}$'),
+  submission_round integer not null,
+  uploaded_by uuid references auth.users(id) on delete set null,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+create index if not exists task_evidence_task_id_idx on task_evidence(task_id, created_at);
+create index if not exists task_evidence_sha256_idx on task_evidence(sha256);
+
+-- Set up Row Level Security (RLS) policies. Evidence is added and removed
+-- through the API, which checks roles and limits and uses the service key;
+-- project members may read it.
+alter table task_evidence enable row level security;
+
+create policy "Project members can view task evidence"
+  on task_evidence for select
+  using (is_task_member(task_evidence.task_id));
+
+-- Reviews are tied to the evidence of their submission round instead of a
+-- single URL: the items' ids, and the hashes of the files as reviewed
+alter table task_reviews drop column if exists evidence_url;
+alter table task_reviews add column if not exists evidence_ids uuid[] default '{}' not null;
+alter table task_reviews add column if not exists evidence_sha256 text[] default '{}' not null;
+
+-- Carry over the single evidence URL previously stored on tasks as a link
+do $$
+begin
+  if exists (
+    select 1 from information_schema.columns
+    where table_name = 'milestone_tasks' and column_name = 'evidence'
+  ) then
+    insert into task_evidence (task_id, kind, url, mime_type, submission_round, uploaded_by)
+    select id, 'link', evidence, 'text/uri-list', greatest(submission_round, 1), assignee_id
+    from milestone_tasks
+    where evidence is not null and evidence <> '';
+
+    alter table milestone_tasks drop column evidence;
+  end if;
+end $$;
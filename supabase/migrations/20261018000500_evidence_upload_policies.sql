@@ -0,0 +1,30 @@
+-- Record the project on each evidence item so quotas can be enforced
+alter table task_evidence add column if not exists project_id uuid references ideas(id) on delete cascade;
+
+update task_evidence e
+set project_id = m.project_id
+from milestone_tasks t
+join milestones m on m.id = t.milestone_id
+where e.task_id = t.id and e.project_id is null;
+
+alter table task_evidence alter column project_id set not null;
+
+create index if not exists task_evidence_project_id_idx on task_evidence(project_id, kind);
+
+-- Per-project narrowing of the server's evidence upload limits; empty or
+-- zero values fall back to the server defaults
+create table if not exists project_upload_policies (
+  project_id uuid references ideas(id) on delete cascade primary key,
+  allowed_mime_types text[] default '{}' not null,
+  max_file_bytes bigint default 0 not null check (max_file_bytes >= 0),
+  quota_bytes bigint default 0 not null check (quota_bytes >= 0),
+  updated_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+-- Set up Row Level Security (RLS) policies. Policies are changed by the
+-- project lead through the API; project members may read them.
+alter table project_upload_policies enable row level security;
+
+create policy "Project members can view upload policies"
+  on project_upload_policies for select
+  using (is_project_member(project_upload_policies.project_id));
@@ -0,0 +1,22 @@
+-- Weight tasks in their milestone's progress
+alter table milestone_tasks add column if not exists effort integer default 1 not null check (effort > 0);
+
+-- Milestone status now follows task progress; bring existing rows in line.
+-- Rejected tasks (closed without approval) are not counted.
+with counts as (
+  select
+    milestone_id,
+    count(*) filter (where not (status = 'closed' and not reviewed)) as total,
+    count(*) filter (where status = 'approved' or (status = 'closed' and reviewed)) as done,
+    count(*) filter (where not (status = 'closed' and not reviewed) and status <> 'pending') as started
+  from milestone_tasks
+  group by milestone_id
+)
+update milestones m
+set status = case
+  when c.done = c.total then 'completed'
+  when c.started > 0 then 'in_progress'
+  else 'pending'
+end
+from counts c
+where c.milestone_id = m.id and c.total > 0;
@@ -0,0 +1,18 @@
+-- A project's timeline is an ordered set of named phases
+alter table project_timelines add column if not exists name text default '' not null;
+alter table project_timelines add column if not exists position integer default 0 not null;
+
+with ordered as (
+  select id, row_number() over (partition by project_id order by created_at, id) - 1 as position
+  from project_timelines
+)
+update project_timelines t
+set position = o.position,
+    name = case when t.name = '' then 'Phase ' || (o.position + 1) else t.name end
+from ordered o
+where o.id = t.id;
+
+create index if not exists project_timelines_project_position_idx on project_timelines(project_id, position);
+
+-- Milestones can be grouped under a phase
+alter table milestones add column if not exists phase_id uuid references project_timelines(id) on delete set null;
@@ -0,0 +1,26 @@
+-- Schedule dates are calendar dates or zoned instants; timestamps stored
+-- without a zone are taken as UTC
+do $$
+declare
+  col record;
+begin
+  for col in
+    select table_name, column_name
+    from information_schema.columns
+    where table_schema = 'public'
+      and data_type = 'timestamp without time zone'
+      and (table_name, column_name) in (
+        ('project_timelines', 'start_date'),
+        ('project_timelines', 'end_date'),
+        ('milestones', 'due_date'),
+        ('milestone_tasks', 'due_date'))
+  loop
+    execute format('alter table %I alter column %I type timestamptz using %I at time zone ''UTC''',
+      col.table_name, col.column_name, col.column_name);
+  end loop;
+end $$;
+
+-- Existing rows are left for the schedule report to flag
+alter table project_timelines drop constraint if exists project_timelines_dates_check;
+alter table project_timelines add constraint project_timelines_dates_check
+  check (end_date >= start_date) not valid;
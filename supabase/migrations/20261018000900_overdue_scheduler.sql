@@ -0,0 +1,19 @@
+-- Set by the background overdue sweep
+alter table milestones add column if not exists overdue_since timestamp with time zone;
+alter table milestone_tasks add column if not exists overdue_since timestamp with time zone;
+
+create index if not exists milestones_overdue_idx on milestones(overdue_since) where overdue_since is not null;
+create index if not exists milestone_tasks_overdue_idx on milestone_tasks(overdue_since) where overdue_since is not null;
+create index if not exists milestone_tasks_due_date_idx on milestone_tasks(due_date);
+
+-- Leader locks so only one replica runs each scheduled job
+create table if not exists scheduler_locks (
+  name text primary key,
+  holder text not null,
+  expires_at timestamp with time zone not null
+);
+
+-- Set up Row Level Security (RLS). Only the servers, with the service key,
+-- use the locks, so there are no policies and clients get no access.
+alter table scheduler_locks enable row level security;
+revoke all on scheduler_locks from anon, authenticated;
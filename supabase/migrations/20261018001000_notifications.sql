@@ -0,0 +1,44 @@
+-- In-app inbox, one row per recipient of a domain event. milestone_id and
+-- task_id are not foreign keys: a notification can be about a milestone or
+-- task that has since been deleted.
+create table if not exists notifications (
+  id uuid default uuid_generate_v4() primary key,
+  user_id uuid references auth.users(id) on delete cascade not null,
+  project_id uuid references ideas(id) on delete cascade not null,
+  type text not null,
+  title text not null,
+  body text default '' not null,
+  milestone_id uuid,
+  task_id uuid,
+  event_id text not null,
+  read_at timestamp with time zone,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+create index if not exists notifications_user_created_idx on notifications(user_id, created_at desc);
+create index if not exists notifications_user_unread_idx on notifications(user_id) where read_at is null;
+
+-- How each user is notified besides the inbox
+create table if not exists notification_preferences (
+  user_id uuid references auth.users(id) on delete cascade primary key,
+  email text default '' not null,
+  email_enabled boolean default false not null,
+  webhook_url text default '' not null,
+  webhook_enabled boolean default false not null,
+  muted_types text[] default '{}' not null,
+  updated_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+-- Set up Row Level Security (RLS) policies. The notifier and the API write
+-- with the service key, which also validates webhook URLs; users may read
+-- their own inbox and preferences.
+alter table notifications enable row level security;
+alter table notification_preferences enable row level security;
+
+create policy "Users can view their own notifications"
+  on notifications for select
+  using (auth.uid() = user_id);
+
+create policy "Users can view their own notification preferences"
+  on notification_preferences for select
+  using (auth.uid() = user_id);
@@ -0,0 +1,45 @@
+-- Per-project webhook subscriptions. The signing secret is stored encrypted
+-- with the server's webhook secret key and only shown when a webhook is
+-- created.
+create table if not exists project_webhooks (
+  id uuid default uuid_generate_v4() primary key,
+  project_id uuid references ideas(id) on delete cascade not null,
+  url text not null,
+  events text[] not null,
+  secret_encrypted text not null,
+  active boolean default true not null,
+  created_by uuid references auth.users(id) on delete set null,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null,
+  updated_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+create index if not exists project_webhooks_project_idx on project_webhooks(project_id);
+
+-- One row per event sent to a webhook; pending rows are retried at next_attempt_at
+create table if not exists webhook_deliveries (
+  id uuid default uuid_generate_v4() primary key,
+  webhook_id uuid references project_webhooks(id) on delete cascade not null,
+  project_id uuid references ideas(id) on delete cascade not null,
+  event_id text not null,
+  event_type text not null,
+  payload jsonb not null,
+  status text default 'pending' not null check (status in ('pending', 'succeeded', 'failed')),
+  attempts integer default 0 not null,
+  next_attempt_at timestamp with time zone,
+  last_attempt_at timestamp with time zone,
+  response_status integer default 0 not null,
+  last_error text default '' not null,
+  replay_of uuid references webhook_deliveries(id) on delete set null,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+create index if not exists webhook_deliveries_webhook_created_idx on webhook_deliveries(webhook_id, created_at desc);
+create index if not exists webhook_deliveries_due_idx on webhook_deliveries(next_attempt_at) where status = 'pending';
+
+-- Set up Row Level Security (RLS). Webhooks are managed through the API,
+-- which checks the project lead and uses the service key, so clients get no
+-- access to the secrets, targets or delivery payloads.
+alter table project_webhooks enable row level security;
+alter table webhook_deliveries enable row level security;
+revoke all on project_webhooks from anon, authenticated;
+revoke all on webhook_deliveries from anon, authenticated;
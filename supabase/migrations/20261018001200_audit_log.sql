@@ -0,0 +1,47 @@
+-- Append-only record of every change made through the API. actor_id and
+-- project_id are not foreign keys: entries outlive the users and projects
+-- they mention, and the triggers below would refuse the updates and
+-- deletes a cascade makes. entity_id is text because it holds ids of
+-- several tables.
+create table if not exists audit_log (
+  id uuid default uuid_generate_v4() primary key,
+  request_id text not null,
+  actor_id uuid not null,
+  method text not null,
+  endpoint text not null,
+  path text not null,
+  status integer not null,
+  project_id uuid,
+  entity_type text not null,
+  entity_id text default '' not null,
+  action text not null,
+  changes jsonb,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+create index if not exists audit_log_project_created_idx on audit_log(project_id, created_at desc, id desc);
+create index if not exists audit_log_entity_idx on audit_log(entity_type, entity_id);
+create index if not exists audit_log_actor_idx on audit_log(actor_id, created_at desc);
+
+-- Entries can be added but never changed or removed
+create or replace function audit_log_immutable() returns trigger as $$
+begin
+  raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+drop trigger if exists audit_log_immutable on audit_log;
+create trigger audit_log_immutable
+  before update or delete on audit_log
+  for each row execute function audit_log_immutable();
+
+drop trigger if exists audit_log_no_truncate on audit_log;
+create trigger audit_log_no_truncate
+  before truncate on audit_log
+  for each statement execute function audit_log_immutable();
+
+-- Set up Row Level Security (RLS). The API writes and reads the log with
+-- the service key, checking project roles itself, so clients get no access
+-- at all.
+alter table audit_log enable row level security;
+revoke all on audit_log from anon, authenticated;
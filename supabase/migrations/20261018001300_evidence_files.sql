@@ -0,0 +1,109 @@
+-- Stored evidence files, keyed by SHA-256 and shared by every evidence item
+-- with that content. An upload claims its file before storing it; the
+-- evidence collector only deletes files that have gone unclaimed and
+-- unreferenced for a grace period, marking them 'collecting' first so no
+-- upload can reuse a file while it is being deleted.
+create table if not exists evidence_files (
+  sha256 text primary key check (sha256 ~ '^[0-9a-f]{64}$'),
+  state text default 'live' not null check (state in ('live', 'collecting')),
+  last_used_at timestamp with time zone default timezone('utc'::text, now()) not null,
+  created_at timestamp with time zone default timezone('utc'::text, now()) not null
+);
+
+insert into evidence_files (sha256)
+select distinct sha256 from task_evidence
+where kind = 'file' and sha256 is not null
+on conflict (sha256) do nothing;
+
+-- Set up Row Level Security (RLS). Only the API server, with the service
+-- key, tracks stored files, so there are no policies and clients get no
+-- access.
+alter table evidence_files enable row level security;
+revoke all on evidence_files from anon, authenticated;
+
+-- Marks a file as in use before it is stored; refused while the file is
+-- being collected
+create or replace function claim_evidence_file(p_sha256 text)
+returns void
+language plpgsql
+as $$
+begin
+  insert into evidence_files (sha256) values (p_sha256)
+  on conflict (sha256) do update set last_used_at = now()
+    where evidence_files.state = 'live';
+  if not found then
+    raise exception 'evidence file is being deleted' using errcode = '55000';
+  end if;
+end;
+$$;
+
+-- Records an evidence item. For files, uploads to the same project are
+-- serialized so that together they cannot pass the quota, and the stored
+-- file must still be live; updating its row keeps the collector from
+-- taking it until this transaction ends.
+create or replace function create_task_evidence(
+  p_task_id uuid,
+  p_project_id uuid,
+  p_kind text,
+  p_file_name text,
+  p_url text,
+  p_body text,
+  p_mime_type text,
+  p_size bigint,
+  p_sha256 text,
+  p_submission_round integer,
+  p_uploaded_by uuid,
+  p_quota_bytes bigint
+)
+returns setof task_evidence
+language plpgsql
+as $$
+declare
+  v_used bigint;
+begin
+  if p_kind = 'file' then
+    perform pg_advisory_xact_lock(hashtext('task_evidence_quota'), hashtext(p_project_id::text));
+
+    select coalesce(sum(size), 0) into v_used
+    from task_evidence
+    where project_id = p_project_id and kind = 'file';
+    if v_used + p_size > p_quota_bytes then
+      raise exception 'project storage quota exceeded (% of % bytes used)', v_used, p_quota_bytes
+        using errcode = '53400';
+    end if;
+
+    update evidence_files set last_used_at = now()
+    where sha256 = p_sha256 and state = 'live';
+    if not found then
+      raise exception 'evidence file is not stored' using errcode = '55000';
+    end if;
+  end if;
+
+  return query
+  insert into task_evidence (
+    task_id, project_id, kind, file_name, url, body, mime_type, size, sha256, submission_round, uploaded_by
+  ) values (
+    p_task_id, p_project_id, p_kind, p_file_name, p_url, p_body, p_mime_type, p_size, p_sha256, p_submission_round, p_uploaded_by
+  )
+  returning *;
+end;
+$$;
+
+-- Marks the files unused for p_unused_seconds as being collected and
+-- returns them, with any left over from an unfinished collection. A
+-- concurrent claim or create_task_evidence holds the row and moves
+-- last_used_at, so the file is skipped once it is released.
+create or replace function collect_evidence_files(p_unused_seconds integer)
+returns setof text
+language sql
+as $$
+  update evidence_files f set state = 'collecting'
+  where f.state = 'collecting'
+     or (f.last_used_at < now() - make_interval(secs => p_unused_seconds)
+         and not exists (select 1 from task_evidence e where e.sha256 = f.sha256 and e.kind = 'file'))
+  returning f.sha256;
+$$;
+
+revoke execute on function claim_evidence_file(text) from public, anon, authenticated;
+revoke execute on function create_task_evidence(uuid, uuid, text, text, text, text, text, bigint, text, integer, uuid, bigint) from public, anon, authenticated;
+revoke execute on function collect_evidence_files(integer) from public, anon, authenticated;